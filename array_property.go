@@ -94,6 +94,24 @@ func (p *ArrayProperty) Size() uint64 {
 	return uint64(len(p.Data)) + 13
 }
 
+// ElementSize returns how many bytes a single element of the array takes up
+// once uncompressed
+func (p *ArrayProperty) ElementSize() uint64 {
+	switch p.TypeCode {
+	case 'd', 'l':
+		return 8
+	case 'f', 'i':
+		return 4
+	}
+	return 1
+}
+
+// UncompressedSize returns how many bytes the array's data takes up once
+// uncompressed
+func (p *ArrayProperty) UncompressedSize() uint64 {
+	return uint64(p.ArrayLength) * p.ElementSize()
+}
+
 func (p ArrayProperty) Write(w io.Writer) error {
 	_, err := w.Write([]byte{p.TypeCode})
 	if err != nil {
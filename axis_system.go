@@ -0,0 +1,104 @@
+package main
+
+// AxisSystem describes which axes of an FBX file point up, forward, and to
+// the side, along with how many centimeters make up a single unit. It's read
+// from the GlobalSettings section of the file.
+//
+// The canonical frame used for specifying planes, cell sizes and bounds is in
+// meters, with +Y pointing up, +Z pointing forward and +X to the side, which
+// is the FBX default axis system.
+type AxisSystem struct {
+	UpAxis          int
+	UpAxisSign      int
+	FrontAxis       int
+	FrontAxisSign   int
+	CoordAxis       int
+	CoordAxisSign   int
+	UnitScaleFactor float64
+}
+
+// DefaultAxisSystem is Y up and in centimeters, which is what FBX assumes
+// when GlobalSettings doesn't say otherwise
+func DefaultAxisSystem() AxisSystem {
+	return AxisSystem{
+		UpAxis:          1,
+		UpAxisSign:      1,
+		FrontAxis:       2,
+		FrontAxisSign:   1,
+		CoordAxis:       0,
+		CoordAxisSign:   1,
+		UnitScaleFactor: 1,
+	}
+}
+
+// NewAxisSystem reads the axis system out of the FBX's GlobalSettings,
+// falling back to the defaults for anything missing or invalid
+func NewAxisSystem(fbx *FBX) AxisSystem {
+	axis := DefaultAxisSystem()
+
+	settings := fbx.GetNodes("GlobalSettings")
+	if len(settings) == 0 {
+		return axis
+	}
+	props := NewProperties70(settings[0])
+
+	readAxis := func(name string, axisValue *int, signName string, sign *int) {
+		if v, ok := props.Get(name); ok && len(v.Numbers()) > 0 && v.Int() >= 0 && v.Int() <= 2 {
+			*axisValue = int(v.Int())
+		}
+		if v := props.Float64Or(signName, 0); v != 0 {
+			*sign = 1
+			if v < 0 {
+				*sign = -1
+			}
+		}
+	}
+
+	readAxis("UpAxis", &axis.UpAxis, "UpAxisSign", &axis.UpAxisSign)
+	readAxis("FrontAxis", &axis.FrontAxis, "FrontAxisSign", &axis.FrontAxisSign)
+	readAxis("CoordAxis", &axis.CoordAxis, "CoordAxisSign", &axis.CoordAxisSign)
+
+	if v := props.Float64Or("UnitScaleFactor", 0); v > 0 {
+		axis.UnitScaleFactor = v
+	}
+
+	// Each axis needs to be used exactly once to make a valid basis
+	if axis.UpAxis == axis.FrontAxis || axis.UpAxis == axis.CoordAxis || axis.FrontAxis == axis.CoordAxis {
+		def := DefaultAxisSystem()
+		axis.UpAxis, axis.UpAxisSign = def.UpAxis, def.UpAxisSign
+		axis.FrontAxis, axis.FrontAxisSign = def.FrontAxis, def.FrontAxisSign
+		axis.CoordAxis, axis.CoordAxisSign = def.CoordAxis, def.CoordAxisSign
+	}
+
+	return axis
+}
+
+// CanonicalToFile builds a matrix that takes points in meters with Y up and
+// converts them into the units and axes of the file
+func (a AxisSystem) CanonicalToFile() Matrix4 {
+	scale := 100.0 / a.UnitScaleFactor
+
+	var m Matrix4
+	m[a.CoordAxis*4+0] = float64(a.CoordAxisSign) * scale
+	m[a.UpAxis*4+1] = float64(a.UpAxisSign) * scale
+	m[a.FrontAxis*4+2] = float64(a.FrontAxisSign) * scale
+	m[15] = 1
+	return m
+}
+
+// FileToCanonical builds a matrix that takes points in the units and axes of
+// the file and converts them into meters with Y up
+func (a AxisSystem) FileToCanonical() Matrix4 {
+	m, _ := a.CanonicalToFile().Inverse()
+	return m
+}
+
+// PlaneToFile converts a plane in the canonical frame into file space
+func (a AxisSystem) PlaneToFile(p Plane) Plane {
+	m := a.CanonicalToFile()
+	inverse, _ := m.Inverse()
+	return NewPlane(
+		m.MultiplyPoint(p.origin),
+		inverse.Transpose().MultiplyDirection(p.normal),
+	)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAxisSystemDefaultsWithoutGlobalSettings(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := &FBX{Top: NewNodeParent("FBXHeaderExtension")}
+
+	// ******************************** ACT ***********************************
+	axis := NewAxisSystem(fbx)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, DefaultAxisSystem(), axis)
+	plane := axis.PlaneToFile(NewPlane(vector.NewVector3(1, 2, 3), vector.Vector3Up()))
+	assertVectorInDelta(t, vector.NewVector3(100, 200, 300), plane.origin)
+}
+
+func TestAxisSystemConvertsCanonicalIntoZUpMeters(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := &FBX{
+		Top: NewNodeParent("FBXHeaderExtension"),
+		Nodes: []*Node{
+			NewNodeParent(
+				"GlobalSettings",
+				NewNodeInt32("Version", 1000),
+				NewNodeParent(
+					"Properties70",
+					newIntPNode("UpAxis", 2),
+					newIntPNode("UpAxisSign", 1),
+					newIntPNode("FrontAxis", 1),
+					newIntPNode("FrontAxisSign", -1),
+					newIntPNode("CoordAxis", 0),
+					newIntPNode("CoordAxisSign", 1),
+					newDoublePNode("UnitScaleFactor", 100),
+				),
+			),
+		},
+	}
+
+	// ******************************** ACT ***********************************
+	axis := NewAxisSystem(fbx)
+	plane := axis.PlaneToFile(NewPlane(vector.NewVector3(0, 1.5, 0), vector.Vector3Up()))
+
+	// ******************************* ASSERT *********************************
+	assertVectorInDelta(t, vector.NewVector3(0, 0, 1.5), plane.origin)
+	assertVectorInDelta(t, vector.NewVector3(0, 0, 1), plane.normal)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultBatchSize is how many bytes worth of nodes are collected before
+// being sent off as a single job when nothing is known about the file or the
+// workers
+const defaultBatchSize = 1000000
+
+const (
+	// minBatchSize keeps jobs from getting so small the workers spend more
+	// time communicating than splitting
+	minBatchSize = 64 * 1024
+
+	// maxBatchSize keeps a single job from holding too much of a huge file
+	maxBatchSize = 64 * 1000000
+
+	// batchesPerWorker is how many jobs each worker should get out of a file
+	// at the very least, so no worker sits idle while another has the rest
+	// of the file
+	batchesPerWorker = 4
+
+	// targetBatchDuration is about how long a worker should spend on a
+	// single job once it's known how fast the workers are
+	targetBatchDuration = 50 * time.Millisecond
+)
+
+// JobBatcher decides how many bytes worth of nodes go into each job sent to
+// the workers. Batches start out sized so every worker gets a share of the
+// file, and shrink if the workers turn out to be slow enough that a batch
+// would keep one busy too long. It's safe to use from multiple goroutines,
+// and a nil batcher always uses the default size.
+type JobBatcher struct {
+	mutex         sync.Mutex
+	fileShare     int64
+	observedBytes int64
+	observedTime  time.Duration
+}
+
+// NewJobBatcher creates a batcher for a file of the given size being split
+// by the number of workers. A file size of 0 means it's unknown.
+func NewJobBatcher(fileSize int64, workers int) *JobBatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	share := int64(defaultBatchSize)
+	if fileSize > 0 {
+		share = fileSize / int64(workers*batchesPerWorker)
+	}
+
+	return &JobBatcher{fileShare: share}
+}
+
+// Size is how many bytes worth of nodes to collect before sending them off
+func (b *JobBatcher) Size() int64 {
+	if b == nil {
+		return defaultBatchSize
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	size := b.fileShare
+	if b.observedTime > 0 {
+		throughput := float64(b.observedBytes) / b.observedTime.Seconds()
+		if target := int64(throughput * targetBatchDuration.Seconds()); target < size {
+			size = target
+		}
+	}
+
+	if size < minBatchSize {
+		return minBatchSize
+	}
+	if size > maxBatchSize {
+		return maxBatchSize
+	}
+	return size
+}
+
+// Observe records how long a worker took to get through a job of the given
+// number of bytes
+func (b *JobBatcher) Observe(bytes int64, took time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	b.observedBytes += bytes
+	b.observedTime += took
+	b.mutex.Unlock()
+}
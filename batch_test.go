@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobBatcherSize(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	var unknown *JobBatcher
+	small := NewJobBatcher(1000, 4)
+	medium := NewJobBatcher(160000000, 4)
+	huge := NewJobBatcher(100000000000, 4)
+	slow := NewJobBatcher(160000000, 4)
+
+	// ******************************** ACT ***********************************
+	slow.Observe(2000000, time.Second)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, int64(defaultBatchSize), unknown.Size())
+	assert.Equal(t, int64(minBatchSize), small.Size())
+	assert.Equal(t, int64(10000000), medium.Size())
+	assert.Equal(t, int64(maxBatchSize), huge.Size())
+
+	// 2MB/s means a batch should be 100KB to take 50ms
+	assert.Equal(t, int64(100000), slow.Size())
+}
+
+func TestReaderSendsEveryBatch(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	config := DefaultGeneratorConfig()
+	config.Compress = false
+	file := new(bytes.Buffer)
+	assert.NoError(t, GenerateFBX(file, config))
+
+	results := make(chan []*Node, 100)
+	reader := NewReaderWithFilters(MatchMeshGeometry(), results)
+	reader.Batcher = NewJobBatcher(int64(file.Len()), 2)
+
+	// ******************************** ACT ***********************************
+	reader.ReadFrom(bytes.NewReader(file.Bytes()))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, reader.Error)
+
+	batches := 0
+	geometry := 0
+	for batch := range results {
+		batches++
+		geometry += len(batch)
+	}
+	assert.True(t, batches > 1)
+	assert.Equal(t, config.Geometry, geometry)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/EliCDavis/vector"
+)
+
+// BenchmarkCase is a generated file to measure every stage of splitting
+// against
+type BenchmarkCase struct {
+	Name   string          `json:"name"`
+	Config GeneratorConfig `json:"config"`
+}
+
+// DefaultBenchmarkCases are the two extremes that have historically behaved
+// very differently, a single huge geometry node and a large number of tiny
+// ones, each with roughly the same number of triangles. Scale multiplies the
+// number of triangles in both.
+func DefaultBenchmarkCases(scale float64) []BenchmarkCase {
+	scaled := func(n int) int {
+		if s := int(float64(n) * scale); s > 0 {
+			return s
+		}
+		return 1
+	}
+
+	single := DefaultGeneratorConfig()
+	single.Geometry = 1
+	single.TrianglesPerGeometry = scaled(50000)
+
+	many := DefaultGeneratorConfig()
+	many.Geometry = scaled(2500)
+	many.TrianglesPerGeometry = 20
+
+	return []BenchmarkCase{
+		{Name: "small-single-node", Config: single},
+		{Name: "many-tiny-node", Config: many},
+	}
+}
+
+// StageResult is how a single stage performed, taken from the fastest of all
+// iterations
+type StageResult struct {
+	Stage              string  `json:"stage"`
+	Seconds            float64 `json:"seconds"`
+	MBPerSecond        float64 `json:"mbPerSecond"`
+	TrianglesPerSecond float64 `json:"trianglesPerSecond"`
+	Allocs             uint64  `json:"allocs"`
+	AllocBytes         uint64  `json:"allocBytes"`
+}
+
+// CaseResult is how every stage performed against a single benchmark case
+type CaseResult struct {
+	Name      string        `json:"name"`
+	Bytes     int           `json:"bytes"`
+	Triangles int           `json:"triangles"`
+	Stages    []StageResult `json:"stages"`
+}
+
+// BenchmarkReport is the results of running every benchmark case
+type BenchmarkReport struct {
+	Workers    int          `json:"workers"`
+	Iterations int          `json:"iterations"`
+	GoVersion  string       `json:"goVersion"`
+	Cases      []CaseResult `json:"cases"`
+}
+
+// WriteJSON writes the report out as indented JSON
+func (r BenchmarkReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// ReadBenchmarkReport reads a report previously written with WriteJSON
+func ReadBenchmarkReport(r io.Reader) (*BenchmarkReport, error) {
+	report := &BenchmarkReport{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// measure runs the stage, recording how long it took and how much it
+// allocated
+func measure(stage string, bytes, triangles int, run func() error) (StageResult, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	err := run()
+	seconds := time.Since(start).Seconds()
+
+	runtime.ReadMemStats(&after)
+
+	result := StageResult{
+		Stage:      stage,
+		Seconds:    seconds,
+		Allocs:     after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+	}
+	if seconds > 0 {
+		result.MBPerSecond = float64(bytes) / 1000000 / seconds
+		result.TrianglesPerSecond = float64(triangles) / seconds
+	}
+	return result, err
+}
+
+// writeStage writes both chunks out to files through FBX chunk writers the
+// same way split does, either in the single pass shared between them or each
+// in parallel
+func writeStage(fbx *FBX, retained, clipped []Diff, parallel bool) error {
+	dir, err := ioutil.TempDir("", "bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	chunks := []Chunk{{Diffs: retained}, {Diffs: clipped}}
+	for i := range chunks {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d.fbx", i)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w := NewFBXChunkWriter(f)
+		w.Parallel = parallel
+		chunks[i].Writer = w
+	}
+
+	for _, err := range WriteChunks(fbx, chunks) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBenchmarkIteration splits the file once, measuring each stage on it's
+// own so the pipeline's overlap of reading and splitting doesn't hide where
+// time goes. Output is written to files both ways split can write it so the
+// two can be compared.
+func runBenchmarkIteration(file []byte, triangles, workers int) ([]StageResult, error) {
+	stages := make([]StageResult, 0, 5)
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0, 0))
+
+	var fbx *FBX
+	geometry := make([]*Node, 0)
+	read, err := measure("read", len(file), triangles, func() error {
+		jobs := make(chan []*Node, 10000)
+		done := make(chan bool)
+		go func() {
+			for batch := range jobs {
+				geometry = append(geometry, batch...)
+			}
+			done <- true
+		}()
+
+		reader := NewReaderWithFilters(MatchMeshGeometry(), jobs)
+		reader.ReadFrom(bytes.NewReader(file))
+		<-done
+		fbx = reader.FBX
+		return reader.Error
+	})
+	stages = append(stages, read)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := NewConnectionGraph(fbx)
+	transforms := NewSceneTransforms(fbx, graph).GeometryTransforms()
+
+	workerResults := make([]WorkerResult, 0, len(geometry))
+	split, _ := measure("split", len(file), triangles, func() error {
+		jobs := make(chan splitJob, len(geometry))
+		for i, n := range geometry {
+			jobs <- splitJob{index: i, nodes: []*Node{n}}
+		}
+		close(jobs)
+
+		results := make(chan WorkerResult, len(geometry))
+		for w := 0; w < workers; w++ {
+			go worker(w, workers, plane, transforms, nil, jobs, results)
+		}
+		for range geometry {
+			workerResults = append(workerResults, <-results)
+		}
+		return nil
+	})
+	stages = append(stages, split)
+
+	var retained, clipped []Diff
+	merge, _ := measure("merge", len(file), triangles, func() error {
+		merger := newDiffMerger()
+		for _, r := range workerResults {
+			merger.add(r)
+		}
+		retained = UpdateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, merger.retained))
+		clipped = UpdateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, merger.clipped))
+		return nil
+	})
+	stages = append(stages, merge)
+
+	write, err := measure("write", len(file), triangles, func() error {
+		return writeStage(fbx, retained, clipped, false)
+	})
+	stages = append(stages, write)
+	if err != nil {
+		return nil, err
+	}
+
+	writeParallel, err := measure("write-parallel", len(file), triangles, func() error {
+		return writeStage(fbx, retained, clipped, true)
+	})
+	stages = append(stages, writeParallel)
+	return stages, err
+}
+
+// RunBenchmarks generates each case's file and splits it the given number of
+// times, keeping the fastest run of every stage
+func RunBenchmarks(cases []BenchmarkCase, workers, iterations int) (*BenchmarkReport, error) {
+	if workers < 1 || iterations < 1 {
+		return nil, fmt.Errorf("benchmarks need at least one worker and iteration")
+	}
+
+	report := &BenchmarkReport{
+		Workers:    workers,
+		Iterations: iterations,
+		GoVersion:  runtime.Version(),
+		Cases:      make([]CaseResult, 0, len(cases)),
+	}
+
+	for _, c := range cases {
+		file := new(bytes.Buffer)
+		if err := GenerateFBX(file, c.Config); err != nil {
+			return nil, fmt.Errorf("%s: %s", c.Name, err.Error())
+		}
+
+		result := CaseResult{
+			Name:      c.Name,
+			Bytes:     file.Len(),
+			Triangles: c.Config.Geometry * c.Config.TrianglesPerGeometry,
+		}
+
+		for i := 0; i < iterations; i++ {
+			stages, err := runBenchmarkIteration(file.Bytes(), result.Triangles, workers)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", c.Name, err.Error())
+			}
+			if result.Stages == nil {
+				result.Stages = stages
+				continue
+			}
+			for s, stage := range stages {
+				if stage.Seconds < result.Stages[s].Seconds {
+					result.Stages[s] = stage
+				}
+			}
+		}
+
+		report.Cases = append(report.Cases, result)
+	}
+
+	return report, nil
+}
+
+// CompareBenchmarks describes every stage that's regressed from the baseline,
+// either by it's throughput dropping or it's allocations growing by more than
+// the tolerance, given as a fraction. Cases and stages missing from either
+// report are ignored. Reports run with a different number of workers split
+// and allocate differently, so they aren't compared at all and the mismatch
+// is all that's described.
+func CompareBenchmarks(baseline, current *BenchmarkReport, tolerance float64) []string {
+	if baseline.Workers != current.Workers {
+		return []string{fmt.Sprintf(
+			"baseline was run with %d workers and can't be compared against a run with %d",
+			baseline.Workers, current.Workers,
+		)}
+	}
+
+	regressions := make([]string, 0)
+
+	baselineStages := make(map[string]StageResult)
+	for _, c := range baseline.Cases {
+		for _, stage := range c.Stages {
+			baselineStages[c.Name+"/"+stage.Stage] = stage
+		}
+	}
+
+	for _, c := range current.Cases {
+		for _, stage := range c.Stages {
+			name := c.Name + "/" + stage.Stage
+			before, ok := baselineStages[name]
+			if ok == false {
+				continue
+			}
+
+			if stage.MBPerSecond < before.MBPerSecond*(1-tolerance) {
+				regressions = append(regressions, fmt.Sprintf(
+					"%s: throughput dropped from %.2f MB/s to %.2f MB/s",
+					name, before.MBPerSecond, stage.MBPerSecond,
+				))
+			}
+			if float64(stage.Allocs) > float64(before.Allocs)*(1+tolerance) {
+				regressions = append(regressions, fmt.Sprintf(
+					"%s: allocations grew from %d to %d",
+					name, before.Allocs, stage.Allocs,
+				))
+			}
+		}
+	}
+
+	return regressions
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBenchmarks(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	cases := DefaultBenchmarkCases(0.004)
+
+	// ******************************** ACT ***********************************
+	report, err := RunBenchmarks(cases, 2, 1)
+
+	// ******************************* ASSERT *********************************
+	if assert.NoError(t, err) == false {
+		return
+	}
+	if assert.Len(t, report.Cases, 2) == false {
+		return
+	}
+
+	assert.Equal(t, "small-single-node", report.Cases[0].Name)
+	assert.Equal(t, 200, report.Cases[0].Triangles)
+	assert.Equal(t, "many-tiny-node", report.Cases[1].Name)
+	assert.Equal(t, 200, report.Cases[1].Triangles)
+
+	for _, c := range report.Cases {
+		assert.True(t, c.Bytes > 0)
+		if assert.Len(t, c.Stages, 5) {
+			assert.Equal(t, "read", c.Stages[0].Stage)
+			assert.Equal(t, "split", c.Stages[1].Stage)
+			assert.Equal(t, "merge", c.Stages[2].Stage)
+			assert.Equal(t, "write", c.Stages[3].Stage)
+			assert.Equal(t, "write-parallel", c.Stages[4].Stage)
+		}
+		for _, stage := range c.Stages {
+			assert.True(t, stage.Allocs > 0, stage.Stage)
+		}
+	}
+
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, report.WriteJSON(buffer))
+	readBack, err := ReadBenchmarkReport(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, report, readBack)
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	baseline := &BenchmarkReport{Cases: []CaseResult{{
+		Name: "many-tiny-node",
+		Stages: []StageResult{
+			{Stage: "read", MBPerSecond: 100, Allocs: 1000},
+			{Stage: "write", MBPerSecond: 100, Allocs: 1000},
+		},
+	}}}
+	current := &BenchmarkReport{Cases: []CaseResult{{
+		Name: "many-tiny-node",
+		Stages: []StageResult{
+			{Stage: "read", MBPerSecond: 95, Allocs: 1050},
+			{Stage: "write", MBPerSecond: 80, Allocs: 1200},
+			{Stage: "split", MBPerSecond: 1, Allocs: 1},
+		},
+	}}}
+
+	// ******************************** ACT ***********************************
+	regressions := CompareBenchmarks(baseline, current, 0.1)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, []string{
+		"many-tiny-node/write: throughput dropped from 100.00 MB/s to 80.00 MB/s",
+		"many-tiny-node/write: allocations grew from 1000 to 1200",
+	}, regressions)
+}
+
+func TestBenchmarkBaselineMatchesDefaultCases(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	f, err := os.Open("testdata/bench_baseline.json")
+	if assert.NoError(t, err) == false {
+		return
+	}
+	defer f.Close()
+
+	// ******************************** ACT ***********************************
+	baseline, err := ReadBenchmarkReport(f)
+
+	// ******************************* ASSERT *********************************
+	if assert.NoError(t, err) == false {
+		return
+	}
+
+	// The baseline was recorded at a scale of 0.1, and has to be recorded
+	// again whenever the cases or the files generated for them change
+	cases := DefaultBenchmarkCases(0.1)
+	if assert.Len(t, baseline.Cases, len(cases)) == false {
+		return
+	}
+	for i, c := range cases {
+		file := new(bytes.Buffer)
+		assert.NoError(t, GenerateFBX(file, c.Config))
+		assert.Equal(t, c.Name, baseline.Cases[i].Name)
+		assert.Equal(t, file.Len(), baseline.Cases[i].Bytes, c.Name)
+		assert.Equal(t, c.Config.Geometry*c.Config.TrianglesPerGeometry, baseline.Cases[i].Triangles, c.Name)
+		assert.Len(t, baseline.Cases[i].Stages, 5, c.Name)
+	}
+	assert.Empty(t, CompareBenchmarks(baseline, baseline, 0))
+}
+
+func TestCompareBenchmarksRefusesDifferentWorkers(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	baseline := &BenchmarkReport{Workers: 3, Cases: []CaseResult{{
+		Name:   "many-tiny-node",
+		Stages: []StageResult{{Stage: "split", MBPerSecond: 100, Allocs: 1000}},
+	}}}
+	current := &BenchmarkReport{Workers: 8, Cases: []CaseResult{{
+		Name:   "many-tiny-node",
+		Stages: []StageResult{{Stage: "split", MBPerSecond: 200, Allocs: 1000}},
+	}}}
+
+	// ******************************** ACT ***********************************
+	regressions := CompareBenchmarks(baseline, current, 0.1)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, []string{
+		"baseline was run with 3 workers and can't be compared against a run with 8",
+	}, regressions)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"io"
+	"runtime"
+	"strings"
+)
+
+// ChunkWriter writes out a single chunk produced by splitting, which is the
+// original FBX with a set of sorted diffs applied to it
+type ChunkWriter interface {
+	WriteChunk(fbx *FBX, diffs []Diff) error
+}
+
+// FBXChunkWriter writes chunks out as binary FBX
+type FBXChunkWriter struct {
+	w io.Writer
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing with an OffsetError on the first one that's off
+	Verify bool
+
+	// Parallel has every part of the FBX encoded and written at it's offset
+	// at the same time when the writer can be written to at any offset, like
+	// a file, instead of going through the single pass shared with every
+	// other FBX output
+	Parallel bool
+
+	// Stream has the FBX written out while geometry is still being split when
+	// the writer can also be written to at any offset, like a file. Only
+	// splitting pays attention to it, see StreamPatchWriter.
+	Stream bool
+}
+
+// NewFBXChunkWriter creates a chunk writer that patches the original FBX
+func NewFBXChunkWriter(w io.Writer) *FBXChunkWriter {
+	return &FBXChunkWriter{w: w}
+}
+
+// parallelWriter is the writer to write to at any offset, if the chunk
+// writer has been asked to write in parallel and it's writer supports it
+func (cw FBXChunkWriter) parallelWriter() (io.WriterAt, bool) {
+	if cw.Parallel == false {
+		return nil, false
+	}
+	w, ok := cw.w.(io.WriterAt)
+	return w, ok
+}
+
+// WriteChunk writes the patched FBX to the underlying writer, in parallel if
+// it's been asked to and the writer can be written to at any offset
+func (cw FBXChunkWriter) WriteChunk(fbx *FBX, diffs []Diff) error {
+	if w, ok := cw.parallelWriter(); ok {
+		pw := NewParallelPatchWriter(fbx, diffs, runtime.NumCPU())
+		pw.Verify = cw.Verify
+		_, err := pw.WriteAt(w)
+		return err
+	}
+
+	pw := NewPatchWriter(fbx, diffs, func(int, error) {})
+	pw.Verify = cw.Verify
+	_, err := pw.Write(cw.w)
+	return err
+}
+
+// MultiChunkWriter writes every chunk out to all of it's writers, one after
+// another, stopping at the first error
+type MultiChunkWriter []ChunkWriter
+
+// WriteChunk passes the chunk along to every writer
+func (mw MultiChunkWriter) WriteChunk(fbx *FBX, diffs []Diff) error {
+	for _, w := range mw {
+		if w == nil {
+			continue
+		}
+		if err := w.WriteChunk(fbx, diffs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamOutput is the writer to stream to, if the chunk writer has been asked
+// to stream and it's writer supports it
+func (cw FBXChunkWriter) streamOutput() (streamOutput, bool) {
+	if cw.Stream == false {
+		return nil, false
+	}
+	w, ok := cw.w.(streamOutput)
+	return w, ok
+}
+
+// streamedOutputs pulls every FBX chunk writer asked to stream out from the
+// writer, including ones inside of multi chunk writers, returning what's left
+// over
+func streamedOutputs(w ChunkWriter) ([]*FBXChunkWriter, ChunkWriter) {
+	switch cw := w.(type) {
+	case *FBXChunkWriter:
+		if _, ok := cw.streamOutput(); ok {
+			return []*FBXChunkWriter{cw}, nil
+		}
+	case MultiChunkWriter:
+		streamed := make([]*FBXChunkWriter, 0)
+		rest := MultiChunkWriter{}
+		for _, inner := range cw {
+			s, left := streamedOutputs(inner)
+			streamed = append(streamed, s...)
+			if left != nil {
+				rest = append(rest, left)
+			}
+		}
+		return streamed, rest
+	}
+	return nil, w
+}
+
+// Chunk is a set of sorted diffs to be written out by a chunk writer
+type Chunk struct {
+	Writer ChunkWriter
+	Diffs  []Diff
+}
+
+// fbxOutputs pulls every FBX chunk writer out from the writer, including ones
+// inside of multi chunk writers, returning what's left over. FBX chunk writers
+// that write in parallel are left over too, since they write on their own.
+func fbxOutputs(w ChunkWriter, diffs []Diff, outputs []PatchOutput) ([]PatchOutput, []ChunkWriter) {
+	switch cw := w.(type) {
+	case *FBXChunkWriter:
+		if _, ok := cw.parallelWriter(); ok {
+			return outputs, []ChunkWriter{w}
+		}
+		return append(outputs, PatchOutput{Writer: cw.w, Diffs: diffs, Verify: cw.Verify}), nil
+	case MultiChunkWriter:
+		rest := make([]ChunkWriter, 0)
+		for _, inner := range cw {
+			var left []ChunkWriter
+			outputs, left = fbxOutputs(inner, diffs, outputs)
+			rest = append(rest, left...)
+		}
+		return outputs, rest
+	case nil:
+		return outputs, nil
+	}
+	return outputs, []ChunkWriter{w}
+}
+
+// WriteChunks writes out every chunk, returning the first error each chunk's
+// writer ran into. FBX output is written in a single pass over the FBX with a
+// MultiPatchWriter, while FBX output asked to be written in parallel and every
+// other kind of output is written in it's own goroutine.
+func WriteChunks(fbx *FBX, chunks []Chunk) []error {
+	outputs := make([]PatchOutput, 0, len(chunks))
+	outputChunk := make([]int, 0, len(chunks))
+	others := make([]chan error, len(chunks))
+
+	for i, chunk := range chunks {
+		var rest []ChunkWriter
+		before := len(outputs)
+		outputs, rest = fbxOutputs(chunk.Writer, chunk.Diffs, outputs)
+		for range outputs[before:] {
+			outputChunk = append(outputChunk, i)
+		}
+
+		if len(rest) == 0 {
+			continue
+		}
+		others[i] = make(chan error, 1)
+		go func(w MultiChunkWriter, diffs []Diff, result chan<- error) {
+			result <- w.WriteChunk(fbx, diffs)
+		}(MultiChunkWriter(rest), chunk.Diffs, others[i])
+	}
+
+	errs := make([]error, len(chunks))
+	if len(outputs) > 0 {
+		for o, err := range NewMultiPatchWriter(fbx, outputs...).Write() {
+			if err != nil && errs[outputChunk[o]] == nil {
+				errs[outputChunk[o]] = err
+			}
+		}
+	}
+
+	for i, result := range others {
+		if result == nil {
+			continue
+		}
+		if err := <-result; err != nil && errs[i] == nil {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// sceneMaterial is everything exporters care about for a Material
+type sceneMaterial struct {
+	uid        int64
+	name       string
+	diffuse    [3]float64
+	opacity    float64
+	diffuseMap string
+	normalMap  string
+}
+
+// sceneMesh is a piece of mesh geometry attached to a model. The geometry
+// itself is left encoded until an exporter gets around to it, so only a
+// single piece of geometry needs to be decoded at any one time.
+type sceneMesh struct {
+	uid       int64
+	name      string
+	node      *Node
+	world     Matrix4
+	materials []*sceneMaterial
+}
+
+func textureFileName(texture *Node) string {
+	for _, name := range []string{"RelativeFilename", "FileName"} {
+		for _, n := range texture.GetNodes(name) {
+			if s, ok := n.StringProperty(); ok && s != "" {
+				return strings.Replace(s, "\\", "/", -1)
+			}
+		}
+	}
+	return ""
+}
+
+func readSceneMaterial(uid int64, material *Node, objects map[int64]*Node, graph *ConnectionGraph) *sceneMaterial {
+	props := NewProperties70(material)
+	m := &sceneMaterial{
+		uid:     uid,
+		name:    objectName(material),
+		diffuse: [3]float64{0.8, 0.8, 0.8},
+		opacity: 1,
+	}
+
+	for _, name := range []string{"DiffuseColor", "Diffuse"} {
+		if p, ok := props.Get(name); ok {
+			if c, ok := p.Color(); ok {
+				m.diffuse = c
+				break
+			}
+		}
+	}
+
+	if v, ok := props.Numbers("Opacity"); ok && len(v) > 0 {
+		m.opacity = v[0]
+	} else if v, ok := props.Numbers("TransparencyFactor"); ok && len(v) > 0 {
+		m.opacity = 1 - v[0]
+	}
+
+	textureFor := func(property string) string {
+		for _, textureID := range graph.ChildIDs(uid, ConnectionType("OP"), ConnectionProperty(property)) {
+			if texture, ok := objects[textureID]; ok && texture.Name == "Texture" {
+				return textureFileName(texture)
+			}
+		}
+		return ""
+	}
+	m.diffuseMap = textureFor("DiffuseColor")
+	m.normalMap = textureFor("NormalMap")
+	if m.normalMap == "" {
+		m.normalMap = textureFor("Bump")
+	}
+
+	return m
+}
+
+// sceneMeshes pulls out every model's mesh geometry from the FBX along with
+// the materials applied to it, in the order they're found in Objects
+func sceneMeshes(fbx *FBX) []sceneMesh {
+	objects := fbx.ObjectNodes()
+	graph := NewConnectionGraph(fbx)
+	transforms := NewSceneTransforms(fbx, graph)
+	materials := make(map[int64]*sceneMaterial)
+
+	meshes := make([]sceneMesh, 0)
+	for _, objectsNode := range fbx.GetNodes("Objects") {
+		for _, geometryNode := range objectsNode.NestedNodes {
+			if geometryNode == nil || geometryNode.Name != "Geometry" || len(geometryNode.Properties) == 0 {
+				continue
+			}
+			if (Geometry{Object{node: geometryNode}}).IsMesh() == false {
+				continue
+			}
+
+			indexNodes := geometryNode.GetNodes("PolygonVertexIndex")
+			if len(indexNodes) == 0 || len(indexNodes[0].ArrayProperties) == 0 || indexNodes[0].ArrayProperties[0].ArrayLength == 0 {
+				continue
+			}
+
+			uid := geometryNode.Properties[0].AsInt64()
+
+			world, _ := transforms.GeometryWorld(uid)
+			mesh := sceneMesh{
+				uid:       uid,
+				name:      objectName(geometryNode),
+				node:      geometryNode,
+				world:     world,
+				materials: make([]*sceneMaterial, 0),
+			}
+
+			for _, modelID := range graph.ParentIDs(uid, ConnectionType("OO")) {
+				model, ok := objects[modelID]
+				if ok == false || model.Name != "Model" {
+					continue
+				}
+				if name := objectName(model); name != "" {
+					mesh.name = name
+				}
+				for _, materialID := range graph.ChildIDs(modelID, ConnectionType("OO")) {
+					materialNode, ok := objects[materialID]
+					if ok == false || materialNode.Name != "Material" {
+						continue
+					}
+					if _, ok := materials[materialID]; ok == false {
+						materials[materialID] = readSceneMaterial(materialID, materialNode, objects, graph)
+					}
+					mesh.materials = append(mesh.materials, materials[materialID])
+				}
+				break
+			}
+
+			meshes = append(meshes, mesh)
+		}
+	}
+
+	return meshes
+}
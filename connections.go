@@ -0,0 +1,139 @@
+package main
+
+// Connection represents a single `C` entry found within the Connections
+// section of an FBX, linking a child object to a parent object (or to a
+// property of the parent object)
+type Connection struct {
+	Type     string // "OO" for object to object, "OP" for object to property
+	Child    int64
+	Parent   int64
+	Property string // only set for "OP" connections
+	nodeID   uint64
+}
+
+// ConnectionFilter returns true if a connection should be kept
+type ConnectionFilter func(Connection) bool
+
+// ConnectionType returns true if the connection is of the type passed in
+// ("OO" or "OP")
+func ConnectionType(connectionType string) ConnectionFilter {
+	return func(c Connection) bool {
+		return c.Type == connectionType
+	}
+}
+
+// ConnectionProperty returns true if the connection targets the property
+// passed in
+func ConnectionProperty(name string) ConnectionFilter {
+	return func(c Connection) bool {
+		return c.Property == name
+	}
+}
+
+// ConnectionGraph indexes all connections found within an FBX by both their
+// child and parent object IDs
+type ConnectionGraph struct {
+	Connections []Connection
+	byChild     map[int64][]int
+	byParent    map[int64][]int
+}
+
+// NewConnectionGraph builds a connection graph from the Connections section
+// of the FBX. Entries that can not be interpreted are skipped.
+func NewConnectionGraph(fbx *FBX) *ConnectionGraph {
+	graph := &ConnectionGraph{
+		Connections: make([]Connection, 0),
+		byChild:     make(map[int64][]int),
+		byParent:    make(map[int64][]int),
+	}
+
+	for _, c := range fbx.GetNodes("Connections", "C") {
+		connection, ok := parseConnection(c)
+		if ok == false {
+			continue
+		}
+		graph.add(connection)
+	}
+
+	return graph
+}
+
+func parseConnection(n *Node) (Connection, bool) {
+	if len(n.Properties) < 3 {
+		return Connection{}, false
+	}
+
+	if n.Properties[0].TypeCode != 'S' || n.Properties[1].TypeCode != 'L' || n.Properties[2].TypeCode != 'L' {
+		return Connection{}, false
+	}
+
+	connection := Connection{
+		Type:   n.Properties[0].AsString(),
+		Child:  n.Properties[1].AsInt64(),
+		Parent: n.Properties[2].AsInt64(),
+		nodeID: n.id,
+	}
+
+	if len(n.Properties) > 3 && n.Properties[3].TypeCode == 'S' {
+		connection.Property = n.Properties[3].AsString()
+	}
+
+	return connection, true
+}
+
+func (g *ConnectionGraph) add(c Connection) {
+	g.Connections = append(g.Connections, c)
+	g.byChild[c.Child] = append(g.byChild[c.Child], len(g.Connections)-1)
+	g.byParent[c.Parent] = append(g.byParent[c.Parent], len(g.Connections)-1)
+}
+
+func (g ConnectionGraph) collect(indexes []int, filters []ConnectionFilter) []Connection {
+	connections := make([]Connection, 0, len(indexes))
+	for _, i := range indexes {
+		keep := true
+		for _, filter := range filters {
+			if filter(g.Connections[i]) == false {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			connections = append(connections, g.Connections[i])
+		}
+	}
+	return connections
+}
+
+// Parents returns all connections where the object is the child and that
+// pass every filter
+func (g ConnectionGraph) Parents(uid int64, filters ...ConnectionFilter) []Connection {
+	return g.collect(g.byChild[uid], filters)
+}
+
+// Children returns all connections where the object is the parent and that
+// pass every filter
+func (g ConnectionGraph) Children(uid int64, filters ...ConnectionFilter) []Connection {
+	return g.collect(g.byParent[uid], filters)
+}
+
+// ParentIDs returns the IDs of all parents of the object that are connected
+// through connections passing every filter
+func (g ConnectionGraph) ParentIDs(uid int64, filters ...ConnectionFilter) []int64 {
+	connections := g.Parents(uid, filters...)
+	ids := make([]int64, len(connections))
+	for i, c := range connections {
+		ids[i] = c.Parent
+	}
+	return ids
+}
+
+// ChildIDs returns the IDs of all children of the object that are connected
+// through connections passing every filter
+func (g ConnectionGraph) ChildIDs(uid int64, filters ...ConnectionFilter) []int64 {
+	connections := g.Children(uid, filters...)
+	ids := make([]int64, len(connections))
+	for i, c := range connections {
+		ids[i] = c.Child
+	}
+	return ids
+}
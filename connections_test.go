@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionGraphParentsAndChildren(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := &FBX{
+		Top: NewNodeParent("FBXHeaderExtension"),
+		Nodes: []*Node{
+			NewNodeParent(
+				"Connections",
+				newConnectionNode("OO", 10, 0),
+				newConnectionNode("OO", 20, 10),
+				newConnectionNode("OO", 30, 10),
+				newConnectionNode("OP", 40, 30, "DiffuseColor"),
+				newConnectionNode("OP", 50, 30, "NormalMap"),
+			),
+		},
+	}
+
+	// ******************************** ACT ***********************************
+	graph := NewConnectionGraph(fbx)
+
+	// ******************************* ASSERT *********************************
+	assert.Len(t, graph.Connections, 5)
+	assert.Equal(t, []int64{0}, graph.ParentIDs(10))
+	assert.Equal(t, []int64{20, 30}, graph.ChildIDs(10))
+	assert.Equal(t, []int64{40, 50}, graph.ChildIDs(30, ConnectionType("OP")))
+	assert.Len(t, graph.ChildIDs(30, ConnectionType("OO")), 0)
+	assert.Equal(t, []int64{40}, graph.ChildIDs(30, ConnectionProperty("DiffuseColor")))
+
+	parents := graph.Parents(50)
+	if assert.Len(t, parents, 1) {
+		assert.Equal(t, "OP", parents[0].Type)
+		assert.Equal(t, int64(30), parents[0].Parent)
+		assert.Equal(t, "NormalMap", parents[0].Property)
+	}
+}
+
+func TestConnectionGraphSkipsMalformedEntries(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := &FBX{
+		Top: NewNodeParent("FBXHeaderExtension"),
+		Nodes: []*Node{
+			NewNodeParent(
+				"Connections",
+				NewNodeString("C", "OO"),
+				newConnectionNode("OO", 1, 2),
+			),
+		},
+	}
+
+	// ******************************** ACT ***********************************
+	graph := NewConnectionGraph(fbx)
+
+	// ******************************* ASSERT *********************************
+	assert.Len(t, graph.Connections, 1)
+	assert.Equal(t, []int64{2}, graph.ParentIDs(1))
+}
@@ -0,0 +1,93 @@
+package main
+
+import "sort"
+
+func countObjectClasses(objects *Node) map[string]int32 {
+	counts := make(map[string]int32)
+	if objects == nil {
+		return counts
+	}
+	for _, n := range objects.NestedNodes {
+		if n == nil || n.Length == 0 {
+			continue
+		}
+		counts[n.Name]++
+	}
+	return counts
+}
+
+// UpdateDefinitionCounts compares how many of each class of object exist
+// within Objects before and after the diffs are applied, and creates
+// additional diffs that keep the Definitions section in agreement. Object
+// types that end up with no objects are removed entirely. Classes without an
+// existing ObjectType entry are left alone, as there's no property template
+// to build one from. The result is sorted and contains the original diffs.
+func UpdateDefinitionCounts(fbx *FBX, diffs []Diff) []Diff {
+	return updateDefinitionCounts(fbx, diffs, false)
+}
+
+// updateDefinitionCounts keeps object types that end up with no objects
+// around with a count of 0 when keepEmpty is set, so Definitions stays the
+// same size
+func updateDefinitionCounts(fbx *FBX, diffs []Diff, keepEmpty bool) []Diff {
+	objectNodes := fbx.GetNodes("Objects")
+	definitionNodes := fbx.GetNodes("Definitions")
+	if len(objectNodes) == 0 || len(definitionNodes) == 0 || len(diffs) == 0 {
+		return diffs
+	}
+
+	patchedObjects, _ := objectNodes[0].ApplyDiffs(diffs, 0)
+	before := countObjectClasses(objectNodes[0])
+	after := countObjectClasses(patchedObjects)
+
+	changes := make([]Diff, 0)
+	var total int32
+	var totalNode *Node
+
+	for _, n := range definitionNodes[0].NestedNodes {
+		if n == nil {
+			continue
+		}
+
+		if n.Name == "Count" {
+			totalNode = n
+			continue
+		}
+
+		if n.Name != "ObjectType" || len(n.Properties) == 0 {
+			continue
+		}
+
+		countNodes := n.GetNodes("Count")
+		if len(countNodes) == 0 || len(countNodes[0].Properties) == 0 {
+			continue
+		}
+
+		class := n.Properties[0].AsString()
+		count := countNodes[0].Properties[0].AsInt32()
+		newCount := count + after[class] - before[class]
+
+		if newCount <= 0 && keepEmpty {
+			newCount = 0
+		} else if newCount <= 0 {
+			changes = append(changes, NewDeleteNodeDiff(n.id))
+			continue
+		}
+
+		if newCount != count {
+			changes = append(changes, NewPropertyDiff(countNodes[0].id, NewPropertyInt32(newCount)))
+		}
+		total += newCount
+	}
+
+	if len(changes) == 0 {
+		return diffs
+	}
+
+	if totalNode != nil && len(totalNode.Properties) > 0 && totalNode.Properties[0].AsInt32() != total {
+		changes = append(changes, NewPropertyDiff(totalNode.id, NewPropertyInt32(total)))
+	}
+
+	sort.Sort(SortDiff(changes))
+	return combineSorted(diffs, changes)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newObjectTypeNode(class string, count int32) *Node {
+	return NewNode(
+		"ObjectType",
+		[]*Property{NewPropertyString(class)},
+		nil,
+		[]*Node{NewNodeInt32("Count", count)},
+	)
+}
+
+func TestUpdateDefinitionCounts(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Definitions",
+			NewNodeInt32("Version", 100),
+			NewNodeInt32("Count", 6),
+			newObjectTypeNode("GlobalSettings", 1),
+			newObjectTypeNode("Geometry", 2),
+			newObjectTypeNode("Model", 2),
+			newObjectTypeNode("Material", 1),
+		),
+		NewNodeParent(
+			"Objects",
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 2, "A", "Mesh"),
+			newTriangleGeometryNode(3),
+			newObjectNode("Model", 4, "B", "Mesh"),
+			newObjectNode("Material", 5, "M", ""),
+		),
+	)
+	objects := fbx.ObjectNodes()
+	definitions := fbx.GetNodes("Definitions")[0]
+	diffs := []Diff{
+		NewDeleteNodeDiff(objects[1].id),
+		NewDeleteNodeDiff(objects[2].id),
+		NewDeleteNodeDiff(objects[5].id),
+	}
+
+	// ******************************** ACT ***********************************
+	results := UpdateDefinitionCounts(fbx, diffs)
+	patched, _ := definitions.ApplyDiffs(results, 0)
+
+	// ******************************* ASSERT *********************************
+	for i := 1; i < len(results); i++ {
+		assert.LessOrEqual(t, results[i-1].NodeID(), results[i].NodeID())
+	}
+
+	assert.Equal(t, int32(3), patched.GetNodes("Count")[0].Properties[0].AsInt32())
+
+	counts := make(map[string]int32)
+	for _, objectType := range patched.GetNodes("ObjectType") {
+		counts[objectType.Properties[0].AsString()] = objectType.GetNodes("Count")[0].Properties[0].AsInt32()
+	}
+	assert.Equal(t, map[string]int32{
+		"GlobalSettings": 1,
+		"Geometry":       1,
+		"Model":          1,
+	}, counts)
+}
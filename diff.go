@@ -1,5 +1,10 @@
 package main
 
+import (
+	"container/heap"
+	"sync"
+)
+
 // Diff represents a thing to be changed in the original FBX format
 type Diff interface {
 	Apply(n *Node) (*Node, bool)
@@ -12,3 +17,140 @@ type SortDiff []Diff
 func (a SortDiff) Len() int           { return len(a) }
 func (a SortDiff) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a SortDiff) Less(i, j int) bool { return a[i].NodeID() < a[j].NodeID() }
+
+// diffCursor is how far along a single sorted list of diffs a merge is
+type diffCursor struct {
+	diffs []Diff
+	index int
+	list  int
+}
+
+// diffHeap keeps the cursor with the lowest node ID on top, breaking ties by
+// the order the lists were passed in
+type diffHeap []diffCursor
+
+func (h diffHeap) Len() int      { return len(h) }
+func (h diffHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h diffHeap) Less(i, j int) bool {
+	a, b := h[i].diffs[h[i].index].NodeID(), h[j].diffs[h[j].index].NodeID()
+	if a != b {
+		return a < b
+	}
+	return h[i].list < h[j].list
+}
+func (h *diffHeap) Push(x interface{}) { *h = append(*h, x.(diffCursor)) }
+func (h *diffHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// combineSorted merges lists of diffs that are each already sorted by node ID
+// into a single sorted list
+func combineSorted(sortedArrays ...[]Diff) []Diff {
+	resultLen := 0
+	cursors := make(diffHeap, 0, len(sortedArrays))
+	for i, a := range sortedArrays {
+		resultLen += len(a)
+		if len(a) > 0 {
+			cursors = append(cursors, diffCursor{diffs: a, list: i})
+		}
+	}
+	heap.Init(&cursors)
+
+	result := make([]Diff, 0, resultLen)
+	for len(cursors) > 0 {
+		top := &cursors[0]
+		result = append(result, top.diffs[top.index])
+		top.index++
+		if top.index == len(top.diffs) {
+			heap.Pop(&cursors)
+		} else {
+			heap.Fix(&cursors, 0)
+		}
+	}
+	return result
+}
+
+// jobResultHeap keeps the result of the earliest job on top
+type jobResultHeap []WorkerResult
+
+func (h jobResultHeap) Len() int            { return len(h) }
+func (h jobResultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h jobResultHeap) Less(i, j int) bool  { return h[i].job < h[j].job }
+func (h *jobResultHeap) Push(x interface{}) { *h = append(*h, x.(WorkerResult)) }
+func (h *jobResultHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// diffMerger puts the diffs of jobs finished by workers in any order back
+// into node ID order as they come in. Jobs are numbered in the order the
+// reader sends them, and since the reader goes through the file in order, all
+// of a job's diffs come before the next job's. Once every job before one has
+// come in, it's diffs are added to the end of the sorted lists. Anyone writing
+// while jobs are still coming in can wait on the diffs they need with upTo.
+type diffMerger struct {
+	next     int
+	pending  jobResultHeap
+	retained []Diff
+	clipped  []Diff
+
+	// through is the id of the last node the jobs passed along so far went
+	// up to, and done is set once there's no jobs left to come in
+	through uint64
+	done    bool
+	lock    sync.Mutex
+	changed *sync.Cond
+}
+
+func newDiffMerger() *diffMerger {
+	m := &diffMerger{
+		pending:  make(jobResultHeap, 0),
+		retained: make([]Diff, 0),
+		clipped:  make([]Diff, 0),
+	}
+	m.changed = sync.NewCond(&m.lock)
+	return m
+}
+
+// add takes in the sorted diffs of a single job
+func (m *diffMerger) add(r WorkerResult) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	heap.Push(&m.pending, r)
+	for len(m.pending) > 0 && m.pending[0].job == m.next {
+		ready := heap.Pop(&m.pending).(WorkerResult)
+		m.retained = append(m.retained, ready.retained...)
+		m.clipped = append(m.clipped, ready.clipped...)
+		if ready.through > m.through {
+			m.through = ready.through
+		}
+		m.next++
+	}
+	m.changed.Broadcast()
+}
+
+// finish lets anyone waiting know there's no jobs left to come in
+func (m *diffMerger) finish() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.done = true
+	m.changed.Broadcast()
+}
+
+// upTo blocks until the diffs of every node up to and including the id are
+// known, returning all of the diffs passed along so far. Diffs are only ever
+// added to the end of the lists, so what's returned stays as it is.
+func (m *diffMerger) upTo(id uint64) (retained, clipped []Diff) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for m.done == false && m.through < id {
+		m.changed.Wait()
+	}
+	return m.retained[:len(m.retained):len(m.retained)], m.clipped[:len(m.clipped):len(m.clipped)]
+}
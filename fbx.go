@@ -1,9 +1,12 @@
 package main
 
+import "strings"
+
 type FBX struct {
 	Header *Header
 	Top    *Node
 	Nodes  []*Node
+	Footer *Footer
 }
 
 // func (f *FBX) Filter(filter NodeFilter) (nodes []*Node) {
@@ -23,7 +26,7 @@ func (f FBX) GetNodes(names ...string) []*Node {
 
 	nodes := []*Node{}
 
-	if f.Top.Name == names[0] {
+	if f.Top != nil && f.Top.Name == names[0] {
 		nodes = append(nodes, f.Top.GetNodes(names[1:]...)...)
 	}
 
@@ -35,3 +38,97 @@ func (f FBX) GetNodes(names ...string) []*Node {
 
 	return nodes
 }
+
+// ObjectNodes indexes every node found directly under the Objects section by
+// the UID stored in it's first property
+func (f FBX) ObjectNodes() map[int64]*Node {
+	objects := make(map[int64]*Node)
+	for _, objectsNode := range f.GetNodes("Objects") {
+		for _, n := range objectsNode.NestedNodes {
+			if n == nil || len(n.Properties) == 0 || n.Properties[0].TypeCode != 'L' {
+				continue
+			}
+			objects[n.Properties[0].AsInt64()] = n
+		}
+	}
+	return objects
+}
+
+// objectName pulls the name out of an object node's second property, which
+// FBX stores as "Name\x00\x01Class"
+func objectName(n *Node) string {
+	if len(n.Properties) < 2 || n.Properties[1].TypeCode != 'S' {
+		return ""
+	}
+	return strings.SplitN(n.Properties[1].AsString(), "\x00\x01", 2)[0]
+}
+
+// newObjectNode creates a node to live in the Objects section, with the UID,
+// name and subclass every object starts with
+func newObjectNode(class string, uid int64, name string, subclass string, children ...*Node) *Node {
+	return NewNode(
+		class,
+		[]*Property{
+			NewPropertyInt64(uid),
+			NewPropertyString(name + "\x00\x01" + class),
+			NewPropertyString(subclass),
+		},
+		nil,
+		children,
+	)
+}
+
+// newConnectionNode creates an entry for the Connections section, connecting
+// the child object to the parent, optionally through one of it's properties
+func newConnectionNode(connectionType string, child, parent int64, property ...string) *Node {
+	props := []*Property{
+		NewPropertyString(connectionType),
+		NewPropertyInt64(child),
+		NewPropertyInt64(parent),
+	}
+	for _, p := range property {
+		props = append(props, NewPropertyString(p))
+	}
+	return NewNode("C", props, nil, nil)
+}
+
+// newLayerElementNode creates a layer element for geometry, describing how
+// the data in it's children maps onto the mesh
+func newLayerElementNode(name, mapping, reference string, children ...*Node) *Node {
+	return NewNode(
+		name,
+		[]*Property{NewPropertyInt32(0)},
+		nil,
+		append([]*Node{
+			NewNodeInt32("Version", 101),
+			NewNodeString("Name", ""),
+			NewNodeString("MappingInformationType", mapping),
+			NewNodeString("ReferenceInformationType", reference),
+		}, children...),
+	)
+}
+
+// ApplyDiffs creates a new FBX with the sorted diffs applied. Nodes left
+// untouched by the diffs are shared with the original FBX.
+func (f FBX) ApplyDiffs(diffs []Diff) *FBX {
+	patched := &FBX{
+		Header: f.Header,
+		Nodes:  make([]*Node, 0, len(f.Nodes)),
+		Footer: f.Footer,
+	}
+
+	diffIndex := 0
+	if f.Top != nil {
+		patched.Top, diffIndex = f.Top.ApplyDiffs(diffs, diffIndex)
+	}
+
+	for _, n := range f.Nodes {
+		var patchedNode *Node
+		patchedNode, diffIndex = n.ApplyDiffs(diffs, diffIndex)
+		if patchedNode != nil {
+			patched.Nodes = append(patched.Nodes, patchedNode)
+		}
+	}
+
+	return patched
+}
@@ -16,6 +16,19 @@ func FilterName(name string) NodeFilter {
 	}
 }
 
+// FilterNameExact returns true if the node's name matches what's passed in, or
+// if the node is one of it's parents. Unlike FilterName, children of the
+// matching node are not kept.
+func FilterNameExact(name string) NodeFilter {
+	return func(n *NodeStack) bool {
+		s := n.String()
+		if len(s) < len(name) {
+			return strings.Index(name, s+"/") == 0
+		}
+		return s == name
+	}
+}
+
 // EITHER filter returns true f any of the filters passed in return true
 func EITHER(filters ...NodeFilter) NodeFilter {
 	return func(n *NodeStack) bool {
@@ -48,3 +61,13 @@ func MatchStackAndSubNodes(name string, subNodes ...string) NodeFilter {
 		return true
 	}
 }
+
+// MatchMeshGeometry matches polygon mesh geometry directly under Objects.
+// Blend shapes and NURBS are also Geometry and can carry vertices, but
+// aren't meshes that can be split up or exported.
+func MatchMeshGeometry() NodeFilter {
+	match := MatchStackAndSubNodes("Objects/Geometry", "Vertices", "PolygonVertexIndex")
+	return func(n *NodeStack) bool {
+		return match(n) && Geometry{Object{node: n.data[n.position]}}.IsMesh()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// footerTail appears at the very end of every compliant file
+var footerTail = []byte{0xF8, 0x5A, 0x8C, 0x6A, 0xDE, 0xF5, 0xD9, 0x7E, 0xEC, 0xE9, 0x0C, 0xE3, 0x75, 0x8F, 0x29, 0x0B}
+
+// defaultFooterID starts the footer of files that didn't come with one
+var defaultFooterID = []byte{0xFA, 0xBC, 0xAB, 0x09, 0xD0, 0xC8, 0xD4, 0x66, 0xB1, 0x76, 0xFB, 0x83, 0x1C, 0xF7, 0x26, 0x7E}
+
+const (
+	// footerIDLen is how long the ID the footer starts with is, which is
+	// followed by 4 zero bytes
+	footerIDLen = 16
+
+	// footerMinLen is how long a footer is with no padding, which is the ID
+	// and it's 4 zero bytes, the version, 120 zero bytes and the tail
+	footerMinLen = footerIDLen + 4 + 4 + 120 + 16
+)
+
+// Footer is everything that comes after the last top level node of a file.
+// It's an ID, zero bytes padding the version out to a multiple of 16 bytes
+// into the file, the version and a tail every file ends with. It's kept
+// exactly as it was read so it can be written back out byte for byte.
+type Footer struct {
+	data   []byte
+	offset uint64
+}
+
+// NewFooter creates a footer out of everything found after the last top
+// level node, which started at the offset given
+func NewFooter(data []byte, offset uint64) *Footer {
+	return &Footer{data: data, offset: offset}
+}
+
+// valid is whether the footer is laid out the way footers are expected to be
+func (f *Footer) valid() bool {
+	return f != nil && len(f.data) >= footerMinLen && bytes.Equal(f.data[len(f.data)-len(footerTail):], footerTail)
+}
+
+// ID is the 16 bytes the footer starts with
+func (f *Footer) ID() []byte {
+	if f.valid() == false {
+		return defaultFooterID
+	}
+	return f.data[:footerIDLen]
+}
+
+// Padding is how many zero bytes come before the version
+func (f *Footer) Padding() int {
+	if f.valid() == false {
+		return 0
+	}
+	return len(f.data) - footerMinLen
+}
+
+// Version is the FBX version the footer records, or 0 if the footer isn't
+// laid out the way it's expected to be
+func (f *Footer) Version() uint32 {
+	if f.valid() == false {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(f.data[len(f.data)-140:])
+}
+
+// footerPadding is how many zero bytes line the version up to a multiple of
+// 16 bytes into the file, given where the footer starts. Already being lined
+// up still takes a full 16 bytes.
+func footerPadding(offset uint64) int {
+	return 16 - int((offset+footerIDLen+4)%16)
+}
+
+// writeFooter writes out the footer after the last top level node, which
+// ends at the offset given, returning how many bytes were written. A footer
+// that ends up where it was read from is written back exactly as it was,
+// otherwise it's rebuilt with it's ID and version and padded for where it
+// is now. Files without a footer get one for the version given.
+func writeFooter(w io.Writer, offset uint64, footer *Footer, version uint32) (int, error) {
+	if footer.valid() && footer.offset == offset {
+		return w.Write(footer.data)
+	}
+
+	if footer.valid() {
+		version = footer.Version()
+	}
+
+	padding := footerPadding(offset)
+	data := make([]byte, footerMinLen+padding)
+	copy(data, footer.ID())
+	binary.LittleEndian.PutUint32(data[footerIDLen+4+padding:], version)
+	copy(data[len(data)-len(footerTail):], footerTail)
+	return w.Write(data)
+}
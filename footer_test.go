@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmodifiedFilesWriteBackTheSame(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		config := DefaultGeneratorConfig()
+		config.TrianglesPerGeometry = 50
+		config.Version = version
+		file := new(bytes.Buffer)
+		assert.NoError(t, GenerateFBX(file, config))
+
+		// Footers from other exporters don't have to look like ours
+		generated, err := ReadFrom(bytes.NewReader(file.Bytes()))
+		assert.NoError(t, err)
+		original := append([]byte{}, file.Bytes()...)
+		copy(original[generated.Footer.offset:], "someone else's!!")
+
+		fbx, err := ReadFrom(bytes.NewReader(original))
+		assert.NoError(t, err)
+
+		// ******************************** ACT ***********************************
+		written := new(bytes.Buffer)
+		_, writeErr := NewPatchWriter(fbx, nil, func(int, error) {}).Write(written)
+		multi := new(bytes.Buffer)
+		multiErrs := NewMultiPatchWriter(fbx, PatchOutput{Writer: multi}).Write()
+		parallel := new(memoryFile)
+		_, parallelErr := NewParallelPatchWriter(fbx, nil, 2).WriteAt(parallel)
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, writeErr)
+		assert.Equal(t, []error{nil}, multiErrs)
+		assert.NoError(t, parallelErr)
+		assert.Equal(t, version, fbx.Footer.Version())
+		assert.Equal(t, []byte("someone else's!!"), fbx.Footer.ID())
+		assert.Equal(t, original, written.Bytes(), "version %d", version)
+		assert.Equal(t, original, multi.Bytes(), "version %d", version)
+		assert.Equal(t, original, parallel.data, "version %d", version)
+	}
+}
+
+func TestFooterIsRebuiltWhenNodesMove(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx, retained, _ := splitGenerated(t, 7500)
+	fbx.Footer = NewFooter(append([]byte("someone else's!!"), fbx.Footer.data[footerIDLen:]...), fbx.Footer.offset)
+
+	// ******************************** ACT ***********************************
+	written := new(bytes.Buffer)
+	_, writeErr := NewPatchWriter(fbx, retained, func(int, error) {}).Write(written)
+	patched, readErr := ReadFrom(bytes.NewReader(written.Bytes()))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, writeErr)
+	assert.NoError(t, readErr)
+	assert.NotEqual(t, fbx.Footer.offset, patched.Footer.offset)
+	assert.Equal(t, []byte("someone else's!!"), patched.Footer.ID())
+	assert.Equal(t, uint32(7500), patched.Footer.Version())
+	assert.Equal(t, 0, (int(patched.Footer.offset)+footerIDLen+4+patched.Footer.Padding())%16)
+}
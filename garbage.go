@@ -0,0 +1,219 @@
+package main
+
+import "sort"
+
+// containerClasses are objects that group other objects together without
+// owning them. Being connected to one of these does not keep an object alive.
+var containerClasses = map[string]bool{
+	"AnimationStack": true,
+	"AnimationLayer": true,
+}
+
+// polygonCount returns how many polygon vertex indices the geometry has, and
+// false if the geometry has no polygons to begin with
+func polygonCount(geometry *Node) (uint32, bool) {
+	for _, child := range geometry.NestedNodes {
+		if child == nil || child.Name != "PolygonVertexIndex" {
+			continue
+		}
+		if len(child.ArrayProperties) == 0 {
+			return 0, false
+		}
+		return child.ArrayProperties[0].ArrayLength, true
+	}
+	return 0, false
+}
+
+// emptyGeometryRemover works out which objects get thrown out along with
+// geometry left without a single polygon. Diffs can be added a few at a time
+// as they become known, and whatever dies because of them is found as they
+// come in. What's thrown out only ever grows, and ends up the same no matter
+// how the diffs were broken up.
+type emptyGeometryRemover struct {
+	objects map[int64]*Node
+	graph   *ConnectionGraph
+
+	// indexGeometry is the geometry each PolygonVertexIndex node belongs to
+	indexGeometry map[uint64]int64
+
+	// empty is geometry that had no polygons to begin with, which is only
+	// looked at once diffs start coming in
+	empty   []int64
+	started bool
+
+	// users is how many connections to each object come from objects still
+	// alive that can keep it alive
+	users map[int64]int
+
+	deleted map[uint64]bool
+	dead    map[int64]bool
+}
+
+func newEmptyGeometryRemover(fbx *FBX, graph *ConnectionGraph) *emptyGeometryRemover {
+	r := &emptyGeometryRemover{
+		objects:       fbx.ObjectNodes(),
+		graph:         graph,
+		indexGeometry: make(map[uint64]int64),
+		empty:         make([]int64, 0),
+		users:         make(map[int64]int),
+		deleted:       make(map[uint64]bool),
+		dead:          make(map[int64]bool),
+	}
+
+	for _, c := range graph.Connections {
+		if r.keepsAlive(c.Parent) {
+			r.users[c.Child]++
+		}
+	}
+
+	for uid, n := range r.objects {
+		if n.Name != "Geometry" {
+			continue
+		}
+		for _, child := range n.NestedNodes {
+			if child != nil && child.Name == "PolygonVertexIndex" {
+				r.indexGeometry[child.id] = uid
+				break
+			}
+		}
+		if count, ok := polygonCount(n); ok && count == 0 {
+			r.empty = append(r.empty, uid)
+		}
+	}
+	return r
+}
+
+func (r *emptyGeometryRemover) className(uid int64) string {
+	if n, ok := r.objects[uid]; ok {
+		return n.Name
+	}
+	return ""
+}
+
+// modelIsEmpty is whether all of the geometry and sub models that make up
+// the model are gone, which is the only way a model is thrown out
+func (r *emptyGeometryRemover) modelIsEmpty(uid int64) bool {
+	hasContent := false
+	for _, child := range r.graph.ChildIDs(uid, ConnectionType("OO")) {
+		switch r.className(child) {
+		case "Geometry", "Model":
+			hasContent = true
+			if r.dead[child] == false {
+				return false
+			}
+		}
+	}
+	return hasContent
+}
+
+// keepsAlive is whether being connected to the object keeps it's children
+// from being thrown out while it's alive
+func (r *emptyGeometryRemover) keepsAlive(uid int64) bool {
+	return uid != 0 && containerClasses[r.className(uid)] == false
+}
+
+// add takes in more diffs, throwing out any geometry they leave empty along
+// with everything that was only reachable through it
+func (r *emptyGeometryRemover) add(diffs []Diff) {
+	counts := make(map[int64]uint32)
+	emptied := make([]int64, 0)
+	for _, d := range diffs {
+		switch diff := d.(type) {
+		case *ArrayPropertyDiff:
+			uid, ok := r.indexGeometry[diff.nodeID]
+			if ok == false || diff.property == nil {
+				continue
+			}
+			counts[uid] = diff.property.ArrayLength
+			if diff.property.ArrayLength == 0 {
+				emptied = append(emptied, uid)
+			}
+		case *DeleteNodeDiff:
+			r.deleted[diff.nodeID] = true
+		}
+	}
+	if r.started == false {
+		r.started = true
+		for _, uid := range r.empty {
+			if count, ok := counts[uid]; ok == false || count == 0 {
+				emptied = append(emptied, uid)
+			}
+		}
+	}
+
+	queue := make([]int64, 0)
+	for _, uid := range emptied {
+		if r.dead[uid] || r.deleted[r.objects[uid].id] {
+			continue
+		}
+		r.dead[uid] = true
+		queue = append(queue, uid)
+	}
+
+	for len(queue) > 0 {
+		uid := queue[0]
+		queue = queue[1:]
+
+		for _, parent := range r.graph.ParentIDs(uid) {
+			if r.dead[parent] || r.className(parent) != "Model" {
+				continue
+			}
+			if r.modelIsEmpty(parent) {
+				r.dead[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+
+		// Every object using the child being gone is how anything besides a
+		// model is thrown out
+		for _, child := range r.graph.ChildIDs(uid) {
+			if r.keepsAlive(uid) {
+				r.users[child]--
+			}
+			if r.dead[child] || r.className(child) == "" || r.className(child) == "Model" {
+				continue
+			}
+			if r.users[child] == 0 {
+				r.dead[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+}
+
+// isDead is whether the object has been thrown out so far
+func (r *emptyGeometryRemover) isDead(uid int64) bool {
+	return r.dead[uid]
+}
+
+// deletions creates sorted delete diffs for every object thrown out so far,
+// along with all of their connections
+func (r *emptyGeometryRemover) deletions() []Diff {
+	deletions := make([]Diff, 0)
+	for uid := range r.dead {
+		if n, ok := r.objects[uid]; ok && r.deleted[n.id] == false {
+			deletions = append(deletions, NewDeleteNodeDiff(n.id))
+		}
+	}
+	for _, c := range r.graph.Connections {
+		if r.dead[c.Child] || r.dead[c.Parent] {
+			deletions = append(deletions, NewDeleteNodeDiff(c.nodeID))
+		}
+	}
+	sort.Sort(SortDiff(deletions))
+	return deletions
+}
+
+// RemoveEmptyGeometry finds all geometry that is left without a single
+// polygon once the diffs passed in are applied, and creates delete diffs for
+// it along with every object and connection that was only reachable through
+// it (models, materials, textures, deformers, animation curves, etc). The
+// result is sorted and contains the original diffs.
+func RemoveEmptyGeometry(fbx *FBX, graph *ConnectionGraph, diffs []Diff) []Diff {
+	r := newEmptyGeometryRemover(fbx, graph)
+	r.add(diffs)
+	if len(r.dead) == 0 {
+		return diffs
+	}
+	return combineSorted(diffs, r.deletions())
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTriangleGeometryNode(uid int64) *Node {
+	return newObjectNode(
+		"Geometry", uid, "Triangle", "Mesh",
+		NewNodeFloat64Slice("Vertices", []float64{0, 0, 0, 1, 0, 0, 0, 1, 0}),
+		NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3}),
+	)
+}
+
+// writeTestFBX writes the nodes out to an FBX following the standard header
+// nodes
+func writeTestFBX(t *testing.T, nodes ...*Node) []byte {
+	buffer := new(bytes.Buffer)
+	writer, err := NewWriter(buffer)
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	for _, n := range nodes {
+		writer.WriteNode(n)
+	}
+	if assert.NoError(t, writer.Complete()) == false {
+		t.FailNow()
+	}
+	return buffer.Bytes()
+}
+
+// readBackFBX writes the nodes out to an FBX and reads them back in so they
+// get assigned IDs the same way they would coming from disk
+func readBackFBX(t *testing.T, nodes ...*Node) *FBX {
+	fbx, err := ReadFrom(bytes.NewReader(writeTestFBX(t, nodes...)))
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	return fbx
+}
+
+func deletedNodeIDs(diffs []Diff) map[uint64]bool {
+	ids := make(map[uint64]bool)
+	for _, d := range diffs {
+		if del, ok := d.(*DeleteNodeDiff); ok {
+			ids[del.nodeID] = true
+		}
+	}
+	return ids
+}
+
+func TestRemoveEmptyGeometry(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 2, "Emptied", "Mesh"),
+			newObjectNode("Material", 3, "Shared", ""),
+			newTriangleGeometryNode(4),
+			newObjectNode("Model", 5, "Kept", "Mesh"),
+			newObjectNode("Material", 6, "OnlyEmptied", ""),
+			newObjectNode("Texture", 7, "OnlyEmptied", ""),
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 2, 0),
+			newConnectionNode("OO", 1, 2),
+			newConnectionNode("OO", 3, 2),
+			newConnectionNode("OO", 5, 0),
+			newConnectionNode("OO", 4, 5),
+			newConnectionNode("OO", 3, 5),
+			newConnectionNode("OO", 6, 2),
+			newConnectionNode("OP", 7, 6, "DiffuseColor"),
+		),
+	)
+	objects := fbx.ObjectNodes()
+	connections := fbx.GetNodes("Connections", "C")
+	emptiedIndices := objects[1].NestedNodes[1]
+	diffs := []Diff{
+		NewArrayPropertyDiff(emptiedIndices.id, NewArrayPropertyInt32CompressedSlice([]int32{})),
+	}
+
+	// ******************************** ACT ***********************************
+	results := RemoveEmptyGeometry(fbx, NewConnectionGraph(fbx), diffs)
+
+	// ******************************* ASSERT *********************************
+	for i := 1; i < len(results); i++ {
+		assert.LessOrEqual(t, results[i-1].NodeID(), results[i].NodeID())
+	}
+
+	deleted := deletedNodeIDs(results)
+	assert.Len(t, deleted, 9)
+
+	assert.True(t, deleted[objects[1].id])
+	assert.True(t, deleted[objects[2].id])
+	assert.False(t, deleted[objects[3].id])
+	assert.False(t, deleted[objects[4].id])
+	assert.False(t, deleted[objects[5].id])
+	assert.True(t, deleted[objects[6].id])
+	assert.True(t, deleted[objects[7].id])
+
+	assert.True(t, deleted[connections[0].id])
+	assert.True(t, deleted[connections[1].id])
+	assert.True(t, deleted[connections[2].id])
+	assert.False(t, deleted[connections[3].id])
+	assert.False(t, deleted[connections[4].id])
+	assert.False(t, deleted[connections[5].id])
+	assert.True(t, deleted[connections[6].id])
+	assert.True(t, deleted[connections[7].id])
+}
+
+func TestRemoveEmptyGeometryThrowsOutObjectsOnceEveryUserIsGone(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 2, "First", "Mesh"),
+			newTriangleGeometryNode(3),
+			newObjectNode("Model", 4, "Second", "Mesh"),
+			newObjectNode("Material", 5, "Shared", ""),
+			newObjectNode("AnimationLayer", 6, "Layer", ""),
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 2, 0),
+			newConnectionNode("OO", 1, 2),
+			newConnectionNode("OO", 4, 0),
+			newConnectionNode("OO", 3, 4),
+			newConnectionNode("OO", 5, 2),
+			newConnectionNode("OO", 5, 2),
+			newConnectionNode("OO", 5, 4),
+			newConnectionNode("OO", 5, 6),
+		),
+	)
+	objects := fbx.ObjectNodes()
+	graph := NewConnectionGraph(fbx)
+	first := NewArrayPropertyDiff(objects[1].NestedNodes[1].id, NewArrayPropertyInt32Slice([]int32{}))
+	second := NewArrayPropertyDiff(objects[3].NestedNodes[1].id, NewArrayPropertyInt32Slice([]int32{}))
+
+	// ******************************** ACT ***********************************
+	onlyFirst := deletedNodeIDs(RemoveEmptyGeometry(fbx, graph, []Diff{first}))
+	both := deletedNodeIDs(RemoveEmptyGeometry(fbx, graph, []Diff{first, second}))
+
+	// ******************************* ASSERT *********************************
+	assert.True(t, onlyFirst[objects[2].id])
+	assert.False(t, onlyFirst[objects[5].id])
+
+	assert.True(t, both[objects[4].id])
+	assert.True(t, both[objects[5].id])
+	assert.False(t, both[objects[6].id])
+}
+
+func TestRemoveEmptyGeometryLeavesDiffsAloneWhenNothingIsEmpty(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent("Objects", newTriangleGeometryNode(1)),
+		NewNodeParent("Connections", newConnectionNode("OO", 1, 0)),
+	)
+	diffs := []Diff{NewDeleteNodeDiff(1000)}
+
+	// ******************************** ACT ***********************************
+	results := RemoveEmptyGeometry(fbx, NewConnectionGraph(fbx), diffs)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, diffs, results)
+}
+
+func TestPatchWriterDropsDeletedNodes(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent("Objects", newTriangleGeometryNode(1), newTriangleGeometryNode(2)),
+	)
+	objects := fbx.ObjectNodes()
+	originalLength := fbx.GetNodes("Objects")[0].Length
+	out := new(bytes.Buffer)
+	writer := NewPatchWriter(fbx, []Diff{NewDeleteNodeDiff(objects[1].id)}, func(int, error) {})
+
+	// ******************************** ACT ***********************************
+	_, writeErr := writer.Write(out)
+	written, readErr := ReadFrom(bytes.NewReader(out.Bytes()))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, writeErr)
+	assert.NoError(t, readErr)
+	assert.Equal(t, originalLength, fbx.GetNodes("Objects")[0].Length, "original tree should be untouched")
+	assert.Len(t, fbx.GetNodes("Objects", "Geometry"), 2, "original tree should be untouched")
+	writtenGeometry := written.GetNodes("Objects", "Geometry")
+	if assert.Len(t, writtenGeometry, 1) {
+		assert.Equal(t, int64(2), writtenGeometry[0].Properties[0].AsInt64())
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/EliCDavis/vector"
+)
+
+// GeneratorConfig describes a synthetic FBX file for testing and
+// benchmarking against
+type GeneratorConfig struct {
+	// Seed makes the same config always generate the exact same file
+	Seed int64
+
+	// Geometry is how many pieces of geometry, each with it's own model,
+	// end up in the file
+	Geometry int
+
+	// TrianglesPerGeometry is how many triangles each piece of geometry has
+	TrianglesPerGeometry int
+
+	// Compress array properties with zlib
+	Compress bool
+
+	// Version of FBX to write
+	Version uint32
+
+	// Normals adds a normal layer element mapped by polygon vertex
+	Normals bool
+
+	// UVs adds a UV layer element referenced by index
+	UVs bool
+
+	// Materials is how many materials to randomly assign polygons to, with
+	// none meaning there's no material layer element
+	Materials int
+}
+
+// DefaultGeneratorConfig is a small file using every feature
+func DefaultGeneratorConfig() GeneratorConfig {
+	return GeneratorConfig{
+		Seed:                 1,
+		Geometry:             10,
+		TrianglesPerGeometry: 1000,
+		Compress:             true,
+		Version:              7500,
+		Normals:              true,
+		UVs:                  true,
+		Materials:            2,
+	}
+}
+
+// generatorCreationTime is recorded in every generated file, so the same
+// config always produces the same bytes
+var generatorCreationTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// generateMesh creates a strip of randomly placed triangles about the
+// origin, so the model it's attached to decides where it ends up
+func generateMesh(config GeneratorConfig, rng *rand.Rand, i int) SceneMesh {
+	size := 10 + rng.Float64()*90
+	vertexCount := config.TrianglesPerGeometry + 2
+
+	mesh := SceneMesh{
+		Name:               fmt.Sprintf("Geometry%d", i),
+		Vertices:           make([]float64, vertexCount*3),
+		PolygonVertexIndex: make([]int32, 0, config.TrianglesPerGeometry*3),
+	}
+	vertices := mesh.Vertices
+	for v := range vertices {
+		vertices[v] = (rng.Float64() - 0.5) * size
+	}
+
+	for t := 0; t < config.TrianglesPerGeometry; t++ {
+		a, b, c := int32(t), int32(t+1), int32(t+2)
+
+		// Every other triangle in a strip flips to keep the winding the same
+		if t%2 == 1 {
+			a, b = b, a
+		}
+		mesh.PolygonVertexIndex = append(mesh.PolygonVertexIndex, a, b, WrapToIndex(c))
+	}
+	polygonVertexIndex := mesh.PolygonVertexIndex
+
+	if config.Normals {
+		normals := make([]float64, 0, len(polygonVertexIndex)*3)
+		for t := 0; t < len(polygonVertexIndex); t += 3 {
+			point := func(pv int) vector.Vector3 {
+				index := polygonVertexIndex[pv]
+				if index < 0 {
+					index = WrapToIndex(index)
+				}
+				return vector.NewVector3(vertices[index*3], vertices[index*3+1], vertices[index*3+2])
+			}
+			a, b, c := point(t), point(t+1), point(t+2)
+			normal := b.Sub(a).Cross(c.Sub(a))
+			if normal.Length() > 0 {
+				normal = normal.Normalized()
+			}
+			for corner := 0; corner < 3; corner++ {
+				normals = append(normals, normal.X(), normal.Y(), normal.Z())
+			}
+		}
+		mesh.Normals = normals
+	}
+
+	if config.UVs {
+		mesh.UVs = make([]float64, vertexCount*2)
+		for v := range mesh.UVs {
+			mesh.UVs[v] = rng.Float64()
+		}
+		mesh.UVIndex = make([]int32, len(polygonVertexIndex))
+		for pv, index := range polygonVertexIndex {
+			if index < 0 {
+				index = WrapToIndex(index)
+			}
+			mesh.UVIndex[pv] = index
+		}
+	}
+
+	if config.Materials > 0 {
+		mesh.Materials = make([]int32, config.TrianglesPerGeometry)
+		for t := range mesh.Materials {
+			mesh.Materials[t] = int32(rng.Intn(config.Materials))
+		}
+	}
+
+	return mesh
+}
+
+// generatedUID is the UID of the i-th object generated, which are numbered
+// the same way a SceneBuilder numbers them, starting with the materials
+// followed by each geometry and it's model
+func generatedUID(i int) int64 {
+	return firstStreamedUID + int64(i)
+}
+
+// generateObjects passes every object in the scene to each in the order they
+// go in Objects, stopping early if each returns false. The same config always
+// generates the same objects.
+func generateObjects(config GeneratorConfig, each func(*Node) bool) {
+	rng := rand.New(rand.NewSource(config.Seed))
+
+	emit := func(n *Node) bool {
+		addNullRecords(n)
+		return each(n)
+	}
+
+	for m := 0; m < config.Materials; m++ {
+		diffuse := [3]float64{rng.Float64(), rng.Float64(), rng.Float64()}
+		material := newObjectNode("Material", generatedUID(m), fmt.Sprintf("Material%d", m), "", sceneMaterialChildren(diffuse, 1)...)
+		if emit(material) == false {
+			return
+		}
+	}
+
+	for i := 0; i < config.Geometry; i++ {
+		mesh := generateMesh(config, rng, i)
+		geometry := newObjectNode("Geometry", generatedUID(config.Materials+i*2), mesh.Name, "Mesh", sceneMeshChildren(mesh, config.Compress)...)
+		if emit(geometry) == false {
+			return
+		}
+
+		translation := vector.NewVector3(
+			(rng.Float64()-0.5)*2000,
+			(rng.Float64()-0.5)*2000,
+			(rng.Float64()-0.5)*2000,
+		)
+		model := newObjectNode("Model", generatedUID(config.Materials+i*2+1), fmt.Sprintf("Model%d", i), "Mesh", sceneModelChildren(translation)...)
+		if emit(model) == false {
+			return
+		}
+	}
+}
+
+// generateConnections passes every connection in the scene to each, stopping
+// early if each returns false. Every model is attached to the root, with it's
+// geometry and every material attached to it.
+func generateConnections(config GeneratorConfig, each func(*Node) bool) {
+	for i := 0; i < config.Geometry; i++ {
+		model := generatedUID(config.Materials + i*2 + 1)
+		connections := []*Node{
+			newConnectionNode("OO", model, 0),
+			newConnectionNode("OO", generatedUID(config.Materials+i*2), model),
+		}
+		for m := 0; m < config.Materials; m++ {
+			connections = append(connections, newConnectionNode("OO", generatedUID(m), model))
+		}
+		for _, c := range connections {
+			addNullRecords(c)
+			if each(c) == false {
+				return
+			}
+		}
+	}
+}
+
+// GenerateFBX streams out a synthetic FBX made up of randomly placed triangle
+// strips, each with it's own model, only ever holding a single piece of
+// geometry in memory at a time. It's the same file a SceneBuilder would build
+// out of the same objects.
+func GenerateFBX(w io.Writer, config GeneratorConfig) error {
+	if config.Geometry < 0 || config.TrianglesPerGeometry < 1 || config.Materials < 0 {
+		return errors.New("generator needs at least one triangle per geometry and can't have negative counts")
+	}
+	if config.Version < 7000 {
+		return fmt.Errorf("can't generate FBX version %d, only 7000 and up", config.Version)
+	}
+
+	// 32 bit offsets limit how large older versions can get
+	if config.Version < 7500 && uint64(config.Geometry)*uint64(config.TrianglesPerGeometry)*100 > math.MaxUint32 {
+		return fmt.Errorf("too many triangles for FBX version %d", config.Version)
+	}
+
+	writer, err := NewWriterWithOptions(w, WriterOptions{
+		Version:      config.Version,
+		CreationTime: generatorCreationTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Objects are far too large to hold in memory all at once for the files
+	// this is meant for, so they're generated twice from the same seed, once
+	// to work out how long they are and again to write them out
+	objectsLength := uint64(0)
+	generateObjects(config, func(object *Node) bool {
+		objectsLength += writer.Length(object)
+		return true
+	})
+	connectionsLength := uint64(0)
+	generateConnections(config, func(connection *Node) bool {
+		connectionsLength += writer.Length(connection)
+		return true
+	})
+
+	settings := sceneGlobalSettings(DefaultAxisSystem())
+	documents := sceneDocuments(generatedUID(config.Materials + config.Geometry*2))
+	definitions := sceneDefinitions(
+		[]string{"GlobalSettings", "Material", "Geometry", "Model"},
+		map[string]int{
+			"GlobalSettings": 1,
+			"Material":       config.Materials,
+			"Geometry":       config.Geometry,
+			"Model":          config.Geometry,
+		},
+	)
+	for _, n := range []*Node{settings, documents, definitions} {
+		addNullRecords(n)
+		writer.WriteNode(n)
+	}
+
+	writer.StartNode("Objects", objectsLength)
+	generateObjects(config, writer.WriteNode)
+	writer.EndNode()
+
+	writer.StartNode("Connections", connectionsLength)
+	generateConnections(config, writer.WriteNode)
+	writer.EndNode()
+
+	return writer.Complete()
+}
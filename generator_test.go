@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateFBXIsDeterministic(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	config := DefaultGeneratorConfig()
+	config.TrianglesPerGeometry = 50
+	first := new(bytes.Buffer)
+	second := new(bytes.Buffer)
+
+	// ******************************** ACT ***********************************
+	firstErr := GenerateFBX(first, config)
+	secondErr := GenerateFBX(second, config)
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	config.Seed++
+	third := new(bytes.Buffer)
+	assert.NoError(t, GenerateFBX(third, config))
+	assert.NotEqual(t, first.Bytes(), third.Bytes())
+}
+
+func TestGenerateFBXStreamsWhatASceneBuilderBuilds(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		config := DefaultGeneratorConfig()
+		config.Geometry = 4
+		config.TrianglesPerGeometry = 30
+		config.Version = version
+
+		scene := NewSceneBuilder(SceneOptions{
+			Version:      version,
+			CreationTime: generatorCreationTime,
+			AxisSystem:   DefaultAxisSystem(),
+			Compress:     config.Compress,
+		})
+		rng := rand.New(rand.NewSource(config.Seed))
+		materials := make([]int64, config.Materials)
+		for m := range materials {
+			diffuse := [3]float64{rng.Float64(), rng.Float64(), rng.Float64()}
+			materials[m] = scene.AddMaterial(fmt.Sprintf("Material%d", m), diffuse, 1)
+		}
+		for i := 0; i < config.Geometry; i++ {
+			geometry := scene.AddMesh(generateMesh(config, rng, i))
+			translation := vector.NewVector3(
+				(rng.Float64()-0.5)*2000,
+				(rng.Float64()-0.5)*2000,
+				(rng.Float64()-0.5)*2000,
+			)
+			model := scene.AddModel(fmt.Sprintf("Model%d", i), translation, 0)
+			scene.Connect(geometry, model)
+			for _, material := range materials {
+				scene.Connect(material, model)
+			}
+		}
+		expected := new(bytes.Buffer)
+		_, err := scene.WriteTo(expected)
+		assert.NoError(t, err)
+
+		buffer := new(bytes.Buffer)
+
+		// ******************************** ACT ***********************************
+		err = GenerateFBX(buffer, config)
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Bytes(), buffer.Bytes(), "version %d", version)
+	}
+}
+
+func TestGenerateFBXReadsBack(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		for _, compress := range []bool{false, true} {
+			// ****************************** ARRANGE *********************************
+			config := DefaultGeneratorConfig()
+			config.Geometry = 3
+			config.TrianglesPerGeometry = 20
+			config.Version = version
+			config.Compress = compress
+			buffer := new(bytes.Buffer)
+
+			// ******************************** ACT ***********************************
+			err := GenerateFBX(buffer, config)
+			fbx, readErr := ReadFrom(bytes.NewReader(buffer.Bytes()))
+
+			// ******************************* ASSERT *********************************
+			if assert.NoError(t, err) == false || assert.NoError(t, readErr) == false {
+				continue
+			}
+			assert.Equal(t, version, fbx.Header.Version())
+
+			geometry := fbx.GetNodes("Objects", "Geometry")
+			assert.Len(t, geometry, 3)
+			assert.Len(t, fbx.GetNodes("Objects", "Model"), 3)
+			assert.Len(t, fbx.GetNodes("Objects", "Material"), 2)
+
+			for _, g := range geometry {
+				mesh, ok := decodeMeshGeometry(g)
+				if assert.True(t, ok) == false {
+					continue
+				}
+				assert.Len(t, mesh.vertices, 22*3)
+				assert.Len(t, mesh.polygonVertexIndex, 60)
+				if assert.NotNil(t, mesh.normals) {
+					assert.Len(t, mesh.normals.data, 60*3)
+				}
+				if assert.NotNil(t, mesh.uvs) {
+					assert.Len(t, mesh.uvs.index, 60)
+				}
+				assert.Len(t, mesh.materials, 20)
+			}
+		}
+	}
+}
+
+func TestGenerateFBXRejectsBadConfig(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	noTriangles := DefaultGeneratorConfig()
+	noTriangles.TrianglesPerGeometry = 0
+	oldVersion := DefaultGeneratorConfig()
+	oldVersion.Version = 6100
+
+	// ******************************** ACT ***********************************
+	noTrianglesErr := GenerateFBX(ioutil.Discard, noTriangles)
+	oldVersionErr := GenerateFBX(ioutil.Discard, oldVersion)
+
+	// ******************************* ASSERT *********************************
+	assert.Error(t, noTrianglesErr)
+	assert.Error(t, oldVersionErr)
+}
+
+func TestSplitByPlaneIntoChunksFromGeneratedFBX(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	dir, err := ioutil.TempDir("", "generated")
+	if assert.NoError(t, err) == false {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultGeneratorConfig()
+	config.TrianglesPerGeometry = 100
+	generated := new(bytes.Buffer)
+	assert.NoError(t, GenerateFBX(generated, config))
+
+	modelName := filepath.Join(dir, "generated.fbx")
+	assert.NoError(t, ioutil.WriteFile(modelName, generated.Bytes(), 0644))
+
+	retained := new(bytes.Buffer)
+	clipped := new(bytes.Buffer)
+	plane := NewPlane(vector.NewVector3(0, 0, 0), vector.NewVector3(1, 0, 0))
+
+	// ******************************** ACT ***********************************
+	SplitByPlaneIntoChunks(modelName, plane, 2, NewFBXChunkWriter(retained), NewFBXChunkWriter(clipped))
+
+	// ******************************* ASSERT *********************************
+	retainedFBX, err := ReadFrom(bytes.NewReader(retained.Bytes()))
+	assert.NoError(t, err)
+	clippedFBX, err := ReadFrom(bytes.NewReader(clipped.Bytes()))
+	assert.NoError(t, err)
+
+	// Models are spread far enough apart that every one lands on one side
+	models := len(retainedFBX.GetNodes("Objects", "Model")) + len(clippedFBX.GetNodes("Objects", "Model"))
+	assert.Equal(t, config.Geometry, models)
+}
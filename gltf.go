@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/EliCDavis/vector"
+)
+
+// https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
+
+const (
+	gltfComponentUnsignedShort = 5123
+	gltfComponentUnsignedInt   = 5125
+	gltfComponentFloat         = 5126
+
+	gltfTargetArrayBuffer        = 34962
+	gltfTargetElementArrayBuffer = 34963
+
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN\0"
+)
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name   string    `json:"name,omitempty"`
+	Mesh   *int      `json:"mesh,omitempty"`
+	Matrix []float64 `json:"matrix,omitempty"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor  [4]float64       `json:"baseColorFactor"`
+	BaseColorTexture *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float64          `json:"metallicFactor"`
+	RoughnessFactor  float64          `json:"roughnessFactor"`
+}
+
+type gltfMaterial struct {
+	Name                 string           `json:"name,omitempty"`
+	PbrMetallicRoughness gltfPBR          `json:"pbrMetallicRoughness"`
+	NormalTexture        *gltfTextureInfo `json:"normalTexture,omitempty"`
+	AlphaMode            string           `json:"alphaMode,omitempty"`
+	DoubleSided          bool             `json:"doubleSided,omitempty"`
+}
+
+type gltfTexture struct {
+	Sampler int `json:"sampler"`
+	Source  int `json:"source"`
+}
+
+type gltfImage struct {
+	URI string `json:"uri"`
+}
+
+type gltfSampler struct{}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes,omitempty"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Samplers    []gltfSampler    `json:"samplers,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+// gltfBuilder accumulates the JSON document and binary buffer of a GLB
+type gltfBuilder struct {
+	doc       gltfDocument
+	bin       bytes.Buffer
+	materials map[int64]int
+	images    map[string]int
+}
+
+func newGLTFBuilder() *gltfBuilder {
+	return &gltfBuilder{
+		doc: gltfDocument{
+			Asset:  gltfAsset{Version: "2.0", Generator: "https://github.com/EliCDavis/fast-mesh-seg"},
+			Scenes: []gltfScene{{Nodes: make([]int, 0)}},
+		},
+		materials: make(map[int64]int),
+		images:    make(map[string]int),
+	}
+}
+
+func (b *gltfBuilder) addBufferView(data []byte, target int) int {
+	for b.bin.Len()%4 != 0 {
+		b.bin.WriteByte(0)
+	}
+	b.doc.BufferViews = append(b.doc.BufferViews, gltfBufferView{
+		Buffer:     0,
+		ByteOffset: b.bin.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	b.bin.Write(data)
+	return len(b.doc.BufferViews) - 1
+}
+
+func (b *gltfBuilder) addFloatAccessor(values []float32, components int, accessorType string, bounds bool) int {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+
+	accessor := gltfAccessor{
+		BufferView:    b.addBufferView(data, gltfTargetArrayBuffer),
+		ComponentType: gltfComponentFloat,
+		Count:         len(values) / components,
+		Type:          accessorType,
+	}
+
+	if bounds && len(values) > 0 {
+		accessor.Min = make([]float64, components)
+		accessor.Max = make([]float64, components)
+		for c := 0; c < components; c++ {
+			accessor.Min[c] = math.Inf(1)
+			accessor.Max[c] = math.Inf(-1)
+		}
+		for i, v := range values {
+			c := i % components
+			accessor.Min[c] = math.Min(accessor.Min[c], float64(v))
+			accessor.Max[c] = math.Max(accessor.Max[c], float64(v))
+		}
+	}
+
+	b.doc.Accessors = append(b.doc.Accessors, accessor)
+	return len(b.doc.Accessors) - 1
+}
+
+// addIndexAccessor stores indices as 16 bit integers when they fit, and 32
+// bit integers otherwise
+func (b *gltfBuilder) addIndexAccessor(indices []uint32, vertexCount int) int {
+	var data []byte
+	componentType := gltfComponentUnsignedInt
+	if vertexCount <= math.MaxUint16 {
+		componentType = gltfComponentUnsignedShort
+		data = make([]byte, len(indices)*2)
+		for i, index := range indices {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(index))
+		}
+	} else {
+		data = make([]byte, len(indices)*4)
+		for i, index := range indices {
+			binary.LittleEndian.PutUint32(data[i*4:], index)
+		}
+	}
+
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{
+		BufferView:    b.addBufferView(data, gltfTargetElementArrayBuffer),
+		ComponentType: componentType,
+		Count:         len(indices),
+		Type:          "SCALAR",
+	})
+	return len(b.doc.Accessors) - 1
+}
+
+func (b *gltfBuilder) addTexture(uri string) *gltfTextureInfo {
+	if uri == "" {
+		return nil
+	}
+
+	image, ok := b.images[uri]
+	if ok == false {
+		if len(b.doc.Samplers) == 0 {
+			b.doc.Samplers = append(b.doc.Samplers, gltfSampler{})
+		}
+		b.doc.Images = append(b.doc.Images, gltfImage{URI: uri})
+		b.doc.Textures = append(b.doc.Textures, gltfTexture{Sampler: 0, Source: len(b.doc.Images) - 1})
+		image = len(b.doc.Textures) - 1
+		b.images[uri] = image
+	}
+	return &gltfTextureInfo{Index: image}
+}
+
+func (b *gltfBuilder) addMaterial(m *sceneMaterial) int {
+	if index, ok := b.materials[m.uid]; ok {
+		return index
+	}
+
+	material := gltfMaterial{
+		Name: m.name,
+		PbrMetallicRoughness: gltfPBR{
+			BaseColorFactor:  [4]float64{m.diffuse[0], m.diffuse[1], m.diffuse[2], m.opacity},
+			BaseColorTexture: b.addTexture(m.diffuseMap),
+			MetallicFactor:   0,
+			RoughnessFactor:  1,
+		},
+		NormalTexture: b.addTexture(m.normalMap),
+	}
+
+	// The texture's color gets multiplied by the factor, so leave it white
+	if material.PbrMetallicRoughness.BaseColorTexture != nil {
+		material.PbrMetallicRoughness.BaseColorFactor = [4]float64{1, 1, 1, m.opacity}
+	}
+
+	if m.opacity < 1 {
+		material.AlphaMode = "BLEND"
+	}
+
+	b.doc.Materials = append(b.doc.Materials, material)
+	b.materials[m.uid] = len(b.doc.Materials) - 1
+	return b.materials[m.uid]
+}
+
+// gltfVertexKey identifies a unique combination of control point, normal and
+// UV. Polygon vertices sharing all three can share a single glTF vertex.
+type gltfVertexKey struct {
+	vertex int
+	normal int
+	uv     int
+}
+
+// gltfPrimitiveBuilder accumulates all triangles using a single material,
+// with positions and normals transformed into glTF's space
+type gltfPrimitiveBuilder struct {
+	world     Matrix4
+	normal    Matrix4
+	vertices  map[gltfVertexKey]uint32
+	positions []float32
+	normals   []float32
+	uvs       []float32
+	indices   []uint32
+}
+
+func (pb *gltfPrimitiveBuilder) vertex(geometry *meshGeometry, polygonVertex, polygon int) uint32 {
+	v := geometry.vertex(polygonVertex)
+	key := gltfVertexKey{vertex: v, normal: -1, uv: -1}
+	if geometry.normals != nil {
+		key.normal = geometry.normals.elementIndex(polygonVertex, v, polygon)
+	}
+	if geometry.uvs != nil {
+		key.uv = geometry.uvs.elementIndex(polygonVertex, v, polygon)
+	}
+
+	if index, ok := pb.vertices[key]; ok {
+		return index
+	}
+
+	index := uint32(len(pb.positions) / 3)
+	pb.vertices[key] = index
+	p := pb.world.MultiplyPoint(vector.NewVector3(
+		geometry.vertices[v*3],
+		geometry.vertices[v*3+1],
+		geometry.vertices[v*3+2],
+	))
+	pb.positions = append(pb.positions, float32(p.X()), float32(p.Y()), float32(p.Z()))
+
+	if geometry.normals != nil {
+		if key.normal >= 0 {
+			n := geometry.normals.value(key.normal)
+			d := pb.normal.MultiplyDirection(vector.NewVector3(n[0], n[1], n[2]))
+			if d.Length() > 0 {
+				d = d.Normalized()
+			}
+			pb.normals = append(pb.normals, float32(d.X()), float32(d.Y()), float32(d.Z()))
+		} else {
+			pb.normals = append(pb.normals, 0, 0, 0)
+		}
+	}
+
+	if geometry.uvs != nil {
+		// FBX has V going up from the bottom of the image, glTF goes down
+		// from the top
+		if key.uv >= 0 {
+			uv := geometry.uvs.value(key.uv)
+			pb.uvs = append(pb.uvs, float32(uv[0]), float32(1-uv[1]))
+		} else {
+			pb.uvs = append(pb.uvs, 0, 0)
+		}
+	}
+
+	return index
+}
+
+// addMesh adds the mesh with it's world transform baked into it's vertices,
+// along with the transform taking the file's units and axes to glTF's meters
+// with Y up
+func (b *gltfBuilder) addMesh(mesh sceneMesh, toGLTF Matrix4) {
+	geometry, ok := decodeMeshGeometry(mesh.node)
+	if ok == false {
+		return
+	}
+
+	world := toGLTF.Multiply(mesh.world)
+	normal := normalMatrix(world)
+
+	// A mirrored transform turns counter clockwise triangles clockwise, so
+	// they're flipped back to keep facing the right way
+	mirrored := world.determinant() < 0
+
+	// Triangles are grouped into a primitive per material slot, with -1
+	// holding all triangles without a material
+	primitives := make(map[int]*gltfPrimitiveBuilder)
+	order := make([]int, 0)
+
+	geometry.forEachPolygon(func(polygon, start, end int) {
+		if end-start < 3 {
+			return
+		}
+
+		slot := geometry.material(polygon)
+		if slot < 0 || slot >= len(mesh.materials) {
+			slot = -1
+		}
+
+		pb, ok := primitives[slot]
+		if ok == false {
+			pb = &gltfPrimitiveBuilder{world: world, normal: normal, vertices: make(map[gltfVertexKey]uint32)}
+			primitives[slot] = pb
+			order = append(order, slot)
+		}
+
+		first := pb.vertex(geometry, start, polygon)
+		for pv := start + 1; pv+1 < end; pv++ {
+			second, third := pb.vertex(geometry, pv, polygon), pb.vertex(geometry, pv+1, polygon)
+			if mirrored {
+				second, third = third, second
+			}
+			pb.indices = append(pb.indices, first, second, third)
+		}
+	})
+
+	if len(order) == 0 {
+		return
+	}
+
+	gMesh := gltfMesh{Name: mesh.name, Primitives: make([]gltfPrimitive, 0, len(order))}
+	for _, slot := range order {
+		pb := primitives[slot]
+		primitive := gltfPrimitive{
+			Attributes: map[string]int{
+				"POSITION": b.addFloatAccessor(pb.positions, 3, "VEC3", true),
+			},
+		}
+		if len(pb.normals) > 0 {
+			primitive.Attributes["NORMAL"] = b.addFloatAccessor(pb.normals, 3, "VEC3", false)
+		}
+		if len(pb.uvs) > 0 {
+			primitive.Attributes["TEXCOORD_0"] = b.addFloatAccessor(pb.uvs, 2, "VEC2", false)
+		}
+		primitive.Indices = b.addIndexAccessor(pb.indices, len(pb.positions)/3)
+
+		if slot >= 0 {
+			material := b.addMaterial(mesh.materials[slot])
+			primitive.Material = &material
+		}
+
+		gMesh.Primitives = append(gMesh.Primitives, primitive)
+	}
+
+	b.doc.Meshes = append(b.doc.Meshes, gMesh)
+	meshIndex := len(b.doc.Meshes) - 1
+
+	b.doc.Nodes = append(b.doc.Nodes, gltfNode{Name: mesh.name, Mesh: &meshIndex})
+	b.doc.Scenes[0].Nodes = append(b.doc.Scenes[0].Nodes, len(b.doc.Nodes)-1)
+}
+
+func writeGLBChunk(w io.Writer, chunkType uint32, data []byte, padding byte) error {
+	padded := data
+	for len(padded)%4 != 0 {
+		padded = append(padded, padding)
+	}
+
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, uint32(len(padded)))
+	binary.LittleEndian.PutUint32(header[4:], chunkType)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(padded)
+	return err
+}
+
+func (b *gltfBuilder) write(w io.Writer) error {
+	if b.bin.Len() > 0 {
+		b.doc.Buffers = []gltfBuffer{{ByteLength: b.bin.Len()}}
+	}
+
+	jsonData, err := json.Marshal(b.doc)
+	if err != nil {
+		return err
+	}
+
+	padTo4 := func(n int) int {
+		return (n + 3) &^ 3
+	}
+
+	totalLength := 12 + 8 + padTo4(len(jsonData))
+	if b.bin.Len() > 0 {
+		totalLength += 8 + padTo4(b.bin.Len())
+	}
+
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header, glbMagic)
+	binary.LittleEndian.PutUint32(header[4:], 2)
+	binary.LittleEndian.PutUint32(header[8:], uint32(totalLength))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	if err := writeGLBChunk(w, glbChunkJSON, jsonData, ' '); err != nil {
+		return err
+	}
+
+	if b.bin.Len() == 0 {
+		return nil
+	}
+	return writeGLBChunk(w, glbChunkBIN, b.bin.Bytes(), 0)
+}
+
+// GLBChunkWriter writes chunks out as binary glTF 2.0, with a node for every
+// piece of mesh geometry. Vertices are placed using their model's world
+// transform and converted from the file's units and axes into meters with Y
+// up, the way glTF expects.
+type GLBChunkWriter struct {
+	w io.Writer
+}
+
+// NewGLBChunkWriter creates a chunk writer that outputs GLB
+func NewGLBChunkWriter(w io.Writer) *GLBChunkWriter {
+	return &GLBChunkWriter{w: w}
+}
+
+// WriteChunk converts the patched FBX to GLB and writes it out
+func (cw GLBChunkWriter) WriteChunk(fbx *FBX, diffs []Diff) error {
+	patched := fbx.ApplyDiffs(diffs)
+	toGLTF := NewAxisSystem(patched).FileToCanonical()
+
+	builder := newGLTFBuilder()
+	for _, mesh := range sceneMeshes(patched) {
+		builder.addMesh(mesh, toGLTF)
+	}
+	return builder.write(cw.w)
+}
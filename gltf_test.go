@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTexturedQuadScene builds a single quad split into two materials, with
+// normals per polygon vertex and UVs referenced through an index
+func newTexturedQuadScene() []*Node {
+	return []*Node{
+		NewNodeParent(
+			"Objects",
+			newObjectNode(
+				"Geometry", 1, "Quad", "Mesh",
+				NewNodeFloat64Slice("Vertices", []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}),
+				NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3, 0, 2, -4}),
+				newLayerElementNode(
+					"LayerElementNormal", "ByPolygonVertex", "Direct",
+					NewNodeFloat64Slice("Normals", []float64{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}),
+				),
+				newLayerElementNode(
+					"LayerElementUV", "ByPolygonVertex", "IndexToDirect",
+					NewNodeFloat64Slice("UV", []float64{0, 0, 1, 0, 1, 1, 0, 1}),
+					NewNodeInt32Slice("UVIndex", []int32{0, 1, 2, 0, 2, 3}),
+				),
+				newLayerElementNode(
+					"LayerElementMaterial", "ByPolygon", "IndexToDirect",
+					NewNodeInt32Slice("Materials", []int32{0, 1}),
+				),
+			),
+			newObjectNode("Model", 2, "QuadModel", "Mesh", NewNodeParent(
+				"Properties70",
+				newVector3PNode("Lcl Translation", vector.NewVector3(0, 0, 5)),
+			)),
+			newObjectNode("Material", 3, "Red", "", NewNodeParent(
+				"Properties70",
+				newVector3PNode("DiffuseColor", vector.NewVector3(1, 0, 0)),
+			)),
+			newObjectNode("Material", 4, "Textured", ""),
+			newObjectNode("Texture", 5, "Bricks", "", NewNodeString("RelativeFilename", "textures\\bricks.png")),
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 2, 0),
+			newConnectionNode("OO", 1, 2),
+			newConnectionNode("OO", 3, 2),
+			newConnectionNode("OO", 4, 2),
+			newConnectionNode("OP", 5, 4, "DiffuseColor"),
+		),
+	}
+}
+
+func readGLB(t *testing.T, data []byte) (gltfDocument, []byte) {
+	var doc gltfDocument
+	if assert.True(t, len(data) >= 20) == false {
+		t.FailNow()
+	}
+	assert.Equal(t, uint32(glbMagic), binary.LittleEndian.Uint32(data))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(data[4:]))
+	assert.Equal(t, uint32(len(data)), binary.LittleEndian.Uint32(data[8:]))
+
+	jsonLength := binary.LittleEndian.Uint32(data[12:])
+	assert.Equal(t, uint32(glbChunkJSON), binary.LittleEndian.Uint32(data[16:]))
+	assert.NoError(t, json.Unmarshal(data[20:20+jsonLength], &doc))
+
+	rest := data[20+jsonLength:]
+	if len(rest) == 0 {
+		return doc, nil
+	}
+	binLength := binary.LittleEndian.Uint32(rest)
+	assert.Equal(t, uint32(glbChunkBIN), binary.LittleEndian.Uint32(rest[4:]))
+	return doc, rest[8 : 8+binLength]
+}
+
+func TestGLBChunkWriter(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(t, newTexturedQuadScene()...)
+	out := new(bytes.Buffer)
+
+	// ******************************** ACT ***********************************
+	err := NewGLBChunkWriter(out).WriteChunk(fbx, nil)
+	doc, bin := readGLB(t, out.Bytes())
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", doc.Asset.Version)
+	assert.Equal(t, 0, len(bin)%4)
+	assert.InDelta(t, len(bin), doc.Buffers[0].ByteLength, 3)
+
+	if assert.Len(t, doc.Nodes, 1) {
+		assert.Equal(t, "QuadModel", doc.Nodes[0].Name)
+		assert.Nil(t, doc.Nodes[0].Matrix)
+	}
+
+	if assert.Len(t, doc.Meshes, 1) && assert.Len(t, doc.Meshes[0].Primitives, 2) {
+		for _, primitive := range doc.Meshes[0].Primitives {
+			assert.Equal(t, 3, doc.Accessors[primitive.Attributes["POSITION"]].Count)
+			assert.Equal(t, 3, doc.Accessors[primitive.Attributes["NORMAL"]].Count)
+			assert.Equal(t, 3, doc.Accessors[primitive.Attributes["TEXCOORD_0"]].Count)
+			assert.Equal(t, 3, doc.Accessors[primitive.Indices].Count)
+			assert.Equal(t, gltfComponentUnsignedShort, doc.Accessors[primitive.Indices].ComponentType)
+		}
+	}
+
+	if assert.Len(t, doc.Materials, 2) {
+		assert.Equal(t, "Red", doc.Materials[0].Name)
+		assert.Equal(t, [4]float64{1, 0, 0, 1}, doc.Materials[0].PbrMetallicRoughness.BaseColorFactor)
+		assert.Nil(t, doc.Materials[0].PbrMetallicRoughness.BaseColorTexture)
+		assert.NotNil(t, doc.Materials[1].PbrMetallicRoughness.BaseColorTexture)
+	}
+
+	if assert.Len(t, doc.Images, 1) {
+		assert.Equal(t, "textures/bricks.png", doc.Images[0].URI)
+	}
+}
+
+func TestGLBChunkWriterWithoutGeometry(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(t, NewNodeParent("Objects"))
+	out := new(bytes.Buffer)
+
+	// ******************************** ACT ***********************************
+	err := NewGLBChunkWriter(out).WriteChunk(fbx, nil)
+	doc, bin := readGLB(t, out.Bytes())
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	assert.Nil(t, bin)
+	assert.Len(t, doc.Scenes, 1)
+	assert.Len(t, doc.Meshes, 0)
+}
+
+// glbPositions pulls the positions and indices of the first primitive out of
+// the GLB's binary chunk
+func glbPositions(t *testing.T, doc gltfDocument, bin []byte) ([]vector.Vector3, []uint16) {
+	primitive := doc.Meshes[0].Primitives[0]
+
+	positionView := doc.BufferViews[doc.Accessors[primitive.Attributes["POSITION"]].BufferView]
+	positions := make([]vector.Vector3, 0)
+	for i := positionView.ByteOffset; i+12 <= positionView.ByteOffset+positionView.ByteLength; i += 12 {
+		positions = append(positions, vector.NewVector3(
+			float64(math.Float32frombits(binary.LittleEndian.Uint32(bin[i:]))),
+			float64(math.Float32frombits(binary.LittleEndian.Uint32(bin[i+4:]))),
+			float64(math.Float32frombits(binary.LittleEndian.Uint32(bin[i+8:]))),
+		))
+	}
+
+	indexView := doc.BufferViews[doc.Accessors[primitive.Indices].BufferView]
+	indices := make([]uint16, 0)
+	for i := indexView.ByteOffset; i+2 <= indexView.ByteOffset+indexView.ByteLength; i += 2 {
+		indices = append(indices, binary.LittleEndian.Uint16(bin[i:]))
+	}
+	return positions, indices
+}
+
+func TestGLBChunkWriterConvertsIntoMetersWithYUp(t *testing.T) {
+	for _, mirrored := range []bool{false, true} {
+		// ****************************** ARRANGE *********************************
+		scaling := vector.NewVector3(1, 1, 1)
+		if mirrored {
+			scaling = vector.NewVector3(-1, 1, 1)
+		}
+
+		// Z up and in centimeters, with a triangle facing up along Z
+		fbx := readBackFBX(
+			t,
+			NewNodeParent(
+				"GlobalSettings",
+				NewNodeParent(
+					"Properties70",
+					newIntPNode("UpAxis", 2),
+					newIntPNode("UpAxisSign", 1),
+					newIntPNode("FrontAxis", 1),
+					newIntPNode("FrontAxisSign", -1),
+					newIntPNode("CoordAxis", 0),
+					newIntPNode("CoordAxisSign", 1),
+					newDoublePNode("UnitScaleFactor", 1),
+				),
+			),
+			NewNodeParent(
+				"Objects",
+				newObjectNode(
+					"Geometry", 1, "Triangle", "Mesh",
+					NewNodeFloat64Slice("Vertices", []float64{0, 0, 100, 100, 0, 100, 0, 100, 100}),
+					NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3}),
+					newLayerElementNode(
+						"LayerElementNormal", "ByPolygonVertex", "Direct",
+						NewNodeFloat64Slice("Normals", []float64{0, 0, 1, 0, 0, 1, 0, 0, 1}),
+					),
+				),
+				newObjectNode("Model", 2, "TriangleModel", "Mesh", NewNodeParent(
+					"Properties70",
+					newVector3PNode("Lcl Scaling", scaling),
+				)),
+			),
+			NewNodeParent(
+				"Connections",
+				newConnectionNode("OO", 2, 0),
+				newConnectionNode("OO", 1, 2),
+			),
+		)
+		out := new(bytes.Buffer)
+
+		// ******************************** ACT ***********************************
+		err := NewGLBChunkWriter(out).WriteChunk(fbx, nil)
+		doc, bin := readGLB(t, out.Bytes())
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, err)
+		positions, indices := glbPositions(t, doc, bin)
+		if assert.Len(t, positions, 3) == false || assert.Len(t, indices, 3) == false {
+			continue
+		}
+
+		// One meter up, with Z pointing back towards where the file's front
+		// was, and X mirrored along with the model
+		assertVectorInDelta(t, vector.NewVector3(0, 1, 0), positions[0])
+		assertVectorInDelta(t, vector.NewVector3(scaling.X(), 1, 0), positions[1])
+		assertVectorInDelta(t, vector.NewVector3(0, 1, -1), positions[2])
+
+		// Whatever the transform, triangles face up and wind counter
+		// clockwise when looked at from above
+		a, b, c := positions[indices[0]], positions[indices[1]], positions[indices[2]]
+		facing := b.Sub(a).Cross(c.Sub(a))
+		assert.True(t, facing.Y() > 0, "mirrored: %v", mirrored)
+	}
+}
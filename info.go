@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/EliCDavis/vector"
+)
+
+// Bounds is an axis aligned bounding box
+type Bounds struct {
+	Min [3]float64 `json:"min"`
+	Max [3]float64 `json:"max"`
+}
+
+func (b *Bounds) include(o Bounds) {
+	for i := 0; i < 3; i++ {
+		b.Min[i] = math.Min(b.Min[i], o.Min[i])
+		b.Max[i] = math.Max(b.Max[i], o.Max[i])
+	}
+}
+
+// GeometryInfo summarizes a single geometry node, or all geometry nodes in a
+// file when used as a total
+type GeometryInfo struct {
+	UID             int64   `json:"uid,omitempty"`
+	Name            string  `json:"name,omitempty"`
+	Bounds          *Bounds `json:"bounds"`
+	WorldBounds     *Bounds `json:"worldBounds"`
+	Vertices        int     `json:"vertices"`
+	Polygons        int     `json:"polygons"`
+	Indices         int     `json:"indices"`
+	IndexBytes      uint64  `json:"indexBytes"`
+	CompressedBytes uint64  `json:"compressedBytes"`
+	RawBytes        uint64  `json:"rawBytes"`
+	nodeID          uint64
+}
+
+// FileInfo summarizes all geometry found within a file
+type FileInfo struct {
+	File     string         `json:"file"`
+	Version  uint32         `json:"version"`
+	Total    GeometryInfo   `json:"total"`
+	Geometry []GeometryInfo `json:"geometry"`
+}
+
+// newEmptyBounds creates bounds that anything included in them replaces
+func newEmptyBounds() *Bounds {
+	return &Bounds{
+		Min: [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Max: [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+}
+
+func (b *Bounds) includePoint(x, y, z float64) {
+	b.include(Bounds{Min: [3]float64{x, y, z}, Max: [3]float64{x, y, z}})
+}
+
+// AnalyzeGeometry decodes the vertices and polygon indices of a geometry node
+// and summarizes them. Bounds are given both in the geometry's own space and
+// in world space, using the world transform of the model it's attached to.
+func AnalyzeGeometry(geomNode *Node, world Matrix4) GeometryInfo {
+	info := GeometryInfo{
+		Name:   objectName(geomNode),
+		nodeID: geomNode.id,
+	}
+
+	if len(geomNode.Properties) > 0 && geomNode.Properties[0].TypeCode == 'L' {
+		info.UID = geomNode.Properties[0].AsInt64()
+	}
+
+	for _, child := range geomNode.NestedNodes {
+		if child == nil || len(child.ArrayProperties) == 0 {
+			continue
+		}
+
+		prop := child.ArrayProperties[0]
+		switch child.Name {
+		case "Vertices":
+			vertices := prop.AsFloat64Slice()
+			info.Vertices = len(vertices) / 3
+			if info.Vertices > 0 {
+				info.Bounds = newEmptyBounds()
+				info.WorldBounds = newEmptyBounds()
+				for v := 0; v+2 < len(vertices); v += 3 {
+					info.Bounds.includePoint(vertices[v], vertices[v+1], vertices[v+2])
+					p := world.MultiplyPoint(vector.NewVector3(vertices[v], vertices[v+1], vertices[v+2]))
+					info.WorldBounds.includePoint(p.X(), p.Y(), p.Z())
+				}
+			}
+
+		case "PolygonVertexIndex":
+			indices := prop.AsInt32Slice()
+			info.Indices = len(indices)
+			info.IndexBytes = prop.UncompressedSize()
+			for _, index := range indices {
+				if index < 0 {
+					info.Polygons++
+				}
+			}
+
+		default:
+			continue
+		}
+
+		info.CompressedBytes += uint64(len(prop.Data))
+		info.RawBytes += prop.UncompressedSize()
+	}
+
+	return info
+}
+
+func infoWorker(transforms map[int64]Matrix4, jobs <-chan []*Node, results chan<- []GeometryInfo) {
+	infos := make([]GeometryInfo, 0)
+	for j := range jobs {
+		for _, n := range j {
+			world, ok := transforms[Object{node: n}.UID()]
+			if ok == false {
+				world = IdentityMatrix4()
+			}
+			infos = append(infos, AnalyzeGeometry(n, world))
+		}
+	}
+	results <- infos
+}
+
+// InfoProgram streams the geometry of a file through a worker pool, only
+// decoding the vertices and polygon indices of each geometry node. The
+// model transforms are read in first so world space bounds are known.
+func InfoProgram(modelName string, workers int) (*FileInfo, error) {
+	transforms, err := readGeometryTransforms(modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(modelName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	jobs := make(chan []*Node, 10000)
+	results := make(chan []GeometryInfo, workers)
+
+	for w := 0; w < workers; w++ {
+		go infoWorker(transforms, jobs, results)
+	}
+
+	reader := NewReaderWithFilters(
+		MatchMeshGeometry(),
+		jobs,
+		EITHER(
+			FilterNameExact("Objects/Geometry"),
+			FilterName("Objects/Geometry/Vertices"),
+			FilterName("Objects/Geometry/PolygonVertexIndex"),
+		),
+	)
+	reader.ReadFrom(f)
+
+	fileInfo := &FileInfo{
+		File:     modelName,
+		Geometry: make([]GeometryInfo, 0),
+	}
+	for w := 0; w < workers; w++ {
+		fileInfo.Geometry = append(fileInfo.Geometry, <-results...)
+	}
+
+	if reader.Error != nil && reader.Error != io.EOF {
+		return nil, reader.Error
+	}
+	fileInfo.Version = reader.FBX.Header.Version()
+
+	sort.Slice(fileInfo.Geometry, func(i, j int) bool {
+		return fileInfo.Geometry[i].nodeID < fileInfo.Geometry[j].nodeID
+	})
+
+	for _, g := range fileInfo.Geometry {
+		fileInfo.Total.Vertices += g.Vertices
+		fileInfo.Total.Polygons += g.Polygons
+		fileInfo.Total.Indices += g.Indices
+		fileInfo.Total.IndexBytes += g.IndexBytes
+		fileInfo.Total.CompressedBytes += g.CompressedBytes
+		fileInfo.Total.RawBytes += g.RawBytes
+		if g.Bounds == nil {
+			continue
+		}
+		if fileInfo.Total.Bounds == nil {
+			fileInfo.Total.Bounds = newEmptyBounds()
+			fileInfo.Total.WorldBounds = newEmptyBounds()
+		}
+		fileInfo.Total.Bounds.include(*g.Bounds)
+		fileInfo.Total.WorldBounds.include(*g.WorldBounds)
+	}
+
+	return fileInfo, nil
+}
+
+// WriteJSON writes the info out as indented JSON
+func (fi FileInfo) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fi)
+}
+
+func boundsToString(b *Bounds) string {
+	if b == nil {
+		return "-"
+	}
+	return fmt.Sprintf(
+		"(%g, %g, %g) (%g, %g, %g)",
+		b.Min[0], b.Min[1], b.Min[2],
+		b.Max[0], b.Max[1], b.Max[2],
+	)
+}
+
+// WriteText writes the info out as a human readable table
+func (fi FileInfo) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%s (FBX %d)\n", fi.File, fi.Version)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UID\tName\tVertices\tPolygons\tIndices\tIndex Bytes\tCompressed\tRaw\tBounds\tWorld Bounds")
+	row := func(uid, name string, g GeometryInfo) {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			uid, name, g.Vertices, g.Polygons, g.Indices,
+			g.IndexBytes, g.CompressedBytes, g.RawBytes, boundsToString(g.Bounds), boundsToString(g.WorldBounds),
+		)
+	}
+	for _, g := range fi.Geometry {
+		row(fmt.Sprint(g.UID), g.Name, g)
+	}
+	row("Total", fmt.Sprintf("%d geometry", len(fi.Geometry)), fi.Total)
+	return tw.Flush()
+}
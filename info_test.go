@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoProgram(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	dir, err := ioutil.TempDir("", "info")
+	if assert.NoError(t, err) == false {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	quad := newObjectNode(
+		"Geometry", 2, "Quad", "Mesh",
+		NewNodeFloat64Slice("Vertices", []float64{-1, 0, -1, 1, 0, -1, 1, 0, 1, -1, 5, 1}),
+		NewNodeSingleArrayProperty("PolygonVertexIndex", NewArrayPropertyInt32CompressedSlice([]int32{0, 1, 2, -4})),
+		NewNodeFloat64Slice("Normals", []float64{0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0}),
+	)
+	fileName := filepath.Join(dir, "info.fbx")
+	err = ioutil.WriteFile(fileName, writeTestFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 3, "Model", "Mesh", NewNodeParent(
+				"Properties70",
+				newVector3PNode("Lcl Translation", vector.NewVector3(10, 0, 0)),
+			)),
+			quad,
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 3, 0),
+			newConnectionNode("OO", 2, 3),
+		),
+	), 0644)
+	if assert.NoError(t, err) == false {
+		return
+	}
+
+	// ******************************** ACT ***********************************
+	info, err := InfoProgram(fileName, 2)
+	text := new(bytes.Buffer)
+	textErr := info.WriteText(text)
+	jsonOut := new(bytes.Buffer)
+	jsonErr := info.WriteJSON(jsonOut)
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	assert.NoError(t, textErr)
+	assert.NoError(t, jsonErr)
+	assert.True(t, json.Valid(jsonOut.Bytes()))
+	assert.Contains(t, text.String(), "Quad")
+
+	if assert.Len(t, info.Geometry, 2) == false {
+		return
+	}
+
+	triangle := info.Geometry[0]
+	assert.Equal(t, int64(1), triangle.UID)
+	assert.Equal(t, "Triangle", triangle.Name)
+	assert.Equal(t, 3, triangle.Vertices)
+	assert.Equal(t, 1, triangle.Polygons)
+	assert.Equal(t, uint64(12), triangle.IndexBytes)
+	assert.Equal(t, triangle.RawBytes, triangle.CompressedBytes)
+	assert.Equal(t, uint64(9*8+3*4), triangle.RawBytes)
+
+	quadInfo := info.Geometry[1]
+	assert.Equal(t, "Quad", quadInfo.Name)
+	assert.Equal(t, 4, quadInfo.Vertices)
+	assert.Equal(t, 1, quadInfo.Polygons)
+	assert.Equal(t, 4, quadInfo.Indices)
+	assert.Equal(t, [3]float64{-1, 0, -1}, quadInfo.Bounds.Min)
+	assert.Equal(t, [3]float64{1, 5, 1}, quadInfo.Bounds.Max)
+	assert.Equal(t, [3]float64{9, 0, -1}, quadInfo.WorldBounds.Min)
+	assert.Equal(t, [3]float64{11, 5, 1}, quadInfo.WorldBounds.Max)
+	assert.Equal(t, triangle.Bounds, triangle.WorldBounds)
+
+	assert.Equal(t, 7, info.Total.Vertices)
+	assert.Equal(t, 2, info.Total.Polygons)
+	assert.Equal(t, [3]float64{-1, 0, -1}, info.Total.Bounds.Min)
+	assert.Equal(t, [3]float64{1, 5, 1}, info.Total.Bounds.Max)
+	assert.Equal(t, [3]float64{0, 0, -1}, info.Total.WorldBounds.Min)
+	assert.Equal(t, [3]float64{11, 5, 1}, info.Total.WorldBounds.Max)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+)
+
+// layerElementDomain is the set of things a layer element's values are
+// mapped onto, each described by the indices of the original geometry that
+// are kept
+type layerElementDomain struct {
+	polygonVertices []int
+	vertices        []int
+	polygons        []int
+}
+
+func keptIndices(src []int, components int) []int {
+	if components == 1 {
+		return src
+	}
+	expanded := make([]int, 0, len(src)*components)
+	for _, i := range src {
+		for c := 0; c < components; c++ {
+			expanded = append(expanded, i*components+c)
+		}
+	}
+	return expanded
+}
+
+func filterArrayProperty(prop *ArrayProperty, kept []int, components int) *ArrayProperty {
+	indices := keptIndices(kept, components)
+
+	switch prop.TypeCode {
+	case 'd':
+		data := prop.AsFloat64Slice()
+		filtered := make([]float64, len(indices))
+		for i, k := range indices {
+			filtered[i] = data[k]
+		}
+		return NewArrayPropertyFloat64CompressedSlice(filtered)
+
+	case 'i':
+		data := prop.AsInt32Slice()
+		filtered := make([]int32, len(indices))
+		for i, k := range indices {
+			filtered[i] = data[k]
+		}
+		return NewArrayPropertyInt32CompressedSlice(filtered)
+	}
+
+	return nil
+}
+
+// splitLayerElements creates diffs that keep the layer elements (normals,
+// UVs, colors, materials, etc) of a geometry node in agreement with the
+// polygons and vertices kept after a split. Values mapped directly onto
+// polygon vertices, vertices, or polygons are filtered down to only those
+// kept. Values referenced through an index array only have the index array
+// filtered.
+func splitLayerElements(geomNode *Node, originalVertexCount, originalPolygonVertexCount, originalPolygonCount int, domain layerElementDomain) []Diff {
+	diffs := make([]Diff, 0)
+
+	for _, layer := range geomNode.NestedNodes {
+		if layer == nil || strings.HasPrefix(layer.Name, "LayerElement") == false {
+			continue
+		}
+
+		mapping := ""
+		reference := ""
+		for _, child := range layer.NestedNodes {
+			if child == nil {
+				continue
+			}
+			switch child.Name {
+			case "MappingInformationType":
+				mapping, _ = child.StringProperty()
+			case "ReferenceInformationType":
+				reference, _ = child.StringProperty()
+			}
+		}
+
+		var kept []int
+		var domainSize int
+		switch mapping {
+		case "ByPolygonVertex":
+			kept, domainSize = domain.polygonVertices, originalPolygonVertexCount
+		case "ByVertice", "ByVertex":
+			kept, domainSize = domain.vertices, originalVertexCount
+		case "ByPolygon":
+			kept, domainSize = domain.polygons, originalPolygonCount
+		default:
+			// AllSame applies to everything, and ByEdge we have no way of
+			// tracking
+			continue
+		}
+
+		if domainSize == 0 {
+			continue
+		}
+
+		for _, child := range layer.NestedNodes {
+			if child == nil || len(child.ArrayProperties) != 1 {
+				continue
+			}
+
+			// Data referenced through an index doesn't line up with the
+			// domain, only the index does
+			isIndex := strings.HasSuffix(child.Name, "Index") || layer.Name == "LayerElementMaterial"
+			if reference != "Direct" && isIndex == false {
+				continue
+			}
+
+			prop := child.ArrayProperties[0]
+			length := int(prop.ArrayLength)
+			if length == 0 || length%domainSize != 0 {
+				continue
+			}
+
+			filtered := filterArrayProperty(prop, kept, length/domainSize)
+			if filtered != nil {
+				diffs = append(diffs, NewArrayPropertyDiff(child.id, filtered))
+			}
+		}
+	}
+
+	return diffs
+}
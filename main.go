@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/EliCDavis/mesh"
 
@@ -22,24 +27,97 @@ func check(e error) {
 
 var timer Timer
 
-func loadModel(modelName string, jobs chan<- []*Node, fbx chan<- *FBX) {
+func loadModel(modelName string, batcher *JobBatcher, jobs chan<- []*Node, fbx chan<- *FBX) {
 	f, err := os.Open(modelName)
 	check(err)
 	defer f.Close()
 
 	reader := NewReaderWithFilters(
-		MatchStackAndSubNodes("Objects/Geometry", "Vertices", "PolygonVertexIndex"),
+		MatchMeshGeometry(),
 		jobs,
 		// EITHER(
 		// 	FilterName("Objects/Geometry/Vertices"),
 		// 	FilterName("Objects/Geometry/PolygonVertexIndex"),
 		// ),
 	)
+	reader.Batcher = batcher
 	reader.ReadFrom(f)
 	check(reader.Error)
 	fbx <- reader.FBX
 }
 
+// loadPLY converts a PLY file into FBX geometry, sending the geometry off to
+// be split as it's built
+func loadPLY(modelName string, batcher *JobBatcher, jobs chan<- []*Node, fbx chan<- *FBX) {
+	f, err := os.Open(modelName)
+	check(err)
+	defer f.Close()
+
+	reader := NewPLYReader(strings.TrimSuffix(filepath.Base(modelName), filepath.Ext(modelName)), jobs)
+	reader.Batcher = batcher
+	reader.ReadFrom(f)
+	check(reader.Error)
+	fbx <- reader.FBX
+}
+
+// loadOBJ converts an OBJ file into FBX geometry, sending the geometry off to
+// be split as it's built. Material libraries are looked for next to the OBJ.
+func loadOBJ(modelName string, batcher *JobBatcher, jobs chan<- []*Node, fbx chan<- *FBX) {
+	f, err := os.Open(modelName)
+	check(err)
+	defer f.Close()
+
+	reader := NewOBJReader(strings.TrimSuffix(filepath.Base(modelName), filepath.Ext(modelName)), jobs)
+	reader.Batcher = batcher
+	reader.OpenMaterialLibrary = func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(filepath.Dir(modelName), name))
+	}
+	reader.ReadFrom(bufio.NewReader(f))
+	check(reader.Error)
+	fbx <- reader.FBX
+}
+
+// modelFormat is the lowercase extension of the model, which determines how
+// it gets loaded
+func modelFormat(modelName string) string {
+	return strings.ToLower(filepath.Ext(modelName))
+}
+
+// loadGeometryTransforms performs a quick pass over the file that only reads
+// in models and connections, so that the world transform of every geometry
+// node is known before the geometry itself starts streaming in
+func loadGeometryTransforms(modelName string) map[int64]Matrix4 {
+	transforms, err := readGeometryTransforms(modelName)
+	check(err)
+	return transforms
+}
+
+// readGeometryTransforms reads only the models and connections of the FBX to
+// work out the world transform of every piece of geometry
+func readGeometryTransforms(modelName string) (map[int64]Matrix4, error) {
+	f, err := os.Open(modelName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := NewReaderWithFilters(
+		nil,
+		nil,
+		EITHER(
+			FilterName("Objects/Model"),
+			FilterNameExact("Objects/Geometry"),
+			FilterName("Connections"),
+		),
+	)
+	reader.ReadFrom(f)
+	if reader.Error != nil {
+		return nil, reader.Error
+	}
+
+	return NewSceneTransforms(reader.FBX, NewConnectionGraph(reader.FBX)).GeometryTransforms(), nil
+}
+
 func save(mesh mesh.Model, name string) error {
 	// defer timeTrack(time.Now(), fmt.Sprintf("Saving Model (%d tris) as '%s'", len(mesh.GetFaces()), name))
 	timer.begin(fmt.Sprintf("Saving Model (%d tris) as '%s'", len(mesh.GetFaces()), name))
@@ -59,22 +137,73 @@ func save(mesh mesh.Model, name string) error {
 	return w.Flush()
 }
 
-func markPoly(current byte, new byte) byte {
-	if current == 0 {
-		return new
+func WrapToIndex(i int32) int32 {
+	return i ^ -1 // i*-1 - 1
+}
+
+// parallelSplitThreshold is how many bytes a geometry node needs to be before
+// it's faces are worth splitting across multiple goroutines
+const parallelSplitThreshold = 8 * 1000000
+
+// forEachRange divides [0, n) into at most the number of ranges given and
+// runs fn on every one of them in it's own goroutine, waiting for all of them
+// to finish. The same n and ranges always divide up the same way.
+func forEachRange(n, ranges int, fn func(r, start, end int)) {
+	if ranges > n {
+		ranges = n
+	}
+	if ranges <= 1 {
+		fn(0, 0, n)
+		return
 	}
-	if current != new {
-		return 3
+
+	per := (n + ranges - 1) / ranges
+	var wg sync.WaitGroup
+	for r := 0; r < ranges; r++ {
+		start := r * per
+		end := start + per
+		if end > n {
+			end = n
+		}
+		if start > end {
+			start = end
+		}
+
+		wg.Add(1)
+		go func(r, start, end int) {
+			defer wg.Done()
+			fn(r, start, end)
+		}(r, start, end)
 	}
-	return new
+	wg.Wait()
 }
 
-func WrapToIndex(i int32) int32 {
-	return i ^ -1 // i*-1 - 1
+// rangeCounts turns how many things each range kept into where each range
+// starts writing them out, along with the total
+func rangeCounts(counts []int) ([]int, int) {
+	starts := make([]int, len(counts))
+	total := 0
+	for r, count := range counts {
+		starts[r] = total
+		total += count
+	}
+	return starts, total
 }
 
-// SplitByPlane accumulates all geometry nodes and splits them by some plane
+// SplitByPlane splits a geometry node by a plane in it's local space, creating
+// diffs for each side
 func SplitByPlane(geomNode *Node, clippingPlane Plane) ([]Diff, []Diff) {
+	return SplitByPlaneInParallel(geomNode, clippingPlane, 1)
+}
+
+// SplitByPlaneInParallel splits a geometry node the same way as SplitByPlane,
+// but divides it's faces and vertices into ranges that each get worked on by
+// their own goroutine, so a single huge piece of geometry can make use of
+// every core
+func SplitByPlaneInParallel(geomNode *Node, clippingPlane Plane, ranges int) ([]Diff, []Diff) {
+	if ranges < 1 {
+		ranges = 1
+	}
 
 	vertexNodes := geomNode.GetNodes("Vertices")
 	if len(vertexNodes) == 0 {
@@ -89,166 +218,227 @@ func SplitByPlane(geomNode *Node, clippingPlane Plane) ([]Diff, []Diff) {
 	vertice, _ := vertexNodes[0].Float64Slice()
 	verticeIndexes, _ := polyVertexNodes[0].Int32Slice()
 
-	// marked 1 if it's retained, 2 if it's clipped, 3 if it's in both.
-	// eventually those marked 3 will disapear as I have to create new polys
-	// for a proper split by plane.
-	vertMarks := make([]byte, len(vertice))
-	vertexPolyIndexMarks := make([]byte, len(verticeIndexes))
-
 	numFaces := len(verticeIndexes) / 3
-
-	// Mark which tris belong in retained or clipped
-	for f := 0; f < numFaces; f++ {
-		faceIndex := f * 3
-		firstInd := int(verticeIndexes[faceIndex]) * 3
-		secondInd := int(verticeIndexes[faceIndex+1]) * 3
-		wrapInd := (int(verticeIndexes[faceIndex+2])*-1 - 1) * 3
-		points := []vector.Vector3{
-			vector.NewVector3(
-				vertice[firstInd],
-				vertice[firstInd+1],
-				vertice[firstInd+2],
-			),
-			vector.NewVector3(
-				vertice[secondInd],
-				vertice[secondInd+1],
-				vertice[secondInd+2],
-			),
-			vector.NewVector3(
-				vertice[wrapInd],
-				vertice[wrapInd+1],
-				vertice[wrapInd+2],
-			),
+	numPoints := len(vertice) / 3
+
+	// Every vertex is shared by a handful of faces, so which side of the
+	// plane each one is on gets worked out once up front. 1 is in front of
+	// the plane, 0 is on or behind it.
+	sides := make([]byte, numPoints)
+	nx, ny, nz := clippingPlane.normal.X(), clippingPlane.normal.Y(), clippingPlane.normal.Z()
+	ox, oy, oz := clippingPlane.origin.X(), clippingPlane.origin.Y(), clippingPlane.origin.Z()
+	forEachRange(numPoints, ranges, func(_, start, end int) {
+		points := vertice[start*3 : end*3]
+		out := sides[start:end]
+		for p := range out {
+			v := points[p*3 : p*3+3 : p*3+3]
+			if (v[0]-ox)*nx+(v[1]-oy)*ny+(v[2]-oz)*nz > 0 {
+				out[p] = 1
+			}
 		}
+	})
 
-		aDist := clippingPlane.normal.Dot(points[0].Sub(clippingPlane.origin))
-		bDist := clippingPlane.normal.Dot(points[1].Sub(clippingPlane.origin))
-		cDist := clippingPlane.normal.Dot(points[2].Sub(clippingPlane.origin))
-		pos := 0
-		neg := 0
+	// marked 1 if it's retained, 2 if it's clipped, 3 if it's in both.
+	// eventually those marked 3 will disapear as I have to create new polys
+	// for a proper split by plane. Each range of faces marks vertices on it's
+	// own, which are combined afterwards.
+	faceMarks := make([]byte, numFaces)
+	rangeVertMarks := make([][]byte, ranges)
 
-		if aDist > 0 {
-			pos++
-		} else {
-			neg++
-		}
+	// Mark which tris belong in retained or clipped
+	forEachRange(numFaces, ranges, func(r, start, end int) {
+		vertMarks := make([]byte, numPoints)
+		rangeVertMarks[r] = vertMarks
+
+		for f := start; f < end; f++ {
+			faceIndex := f * 3
+			first := verticeIndexes[faceIndex]
+			second := verticeIndexes[faceIndex+1]
+			wrap := WrapToIndex(verticeIndexes[faceIndex+2])
+
+			var mark byte
+			switch sides[first] + sides[second] + sides[wrap] {
+			case 3:
+				mark = 1
+			case 0:
+				mark = 2
+			default:
+				continue
+			}
 
-		if bDist > 0 {
-			pos++
-		} else {
-			neg++
+			faceMarks[f] = mark
+			vertMarks[first] |= mark
+			vertMarks[second] |= mark
+			vertMarks[wrap] |= mark
 		}
+	})
 
-		if cDist > 0 {
-			pos++
-		} else {
-			neg++
+	vertMarks := rangeVertMarks[0]
+	if vertMarks == nil {
+		vertMarks = make([]byte, numPoints)
+	}
+	forEachRange(numPoints, ranges, func(_, start, end int) {
+		for _, marks := range rangeVertMarks[1:] {
+			if marks == nil {
+				continue
+			}
+			for p := start; p < end; p++ {
+				vertMarks[p] |= marks[p]
+			}
 		}
+	})
 
-		if pos == 3 {
-			vertexPolyIndexMarks[faceIndex] = markPoly(vertexPolyIndexMarks[faceIndex], 1)
-			vertexPolyIndexMarks[faceIndex+1] = markPoly(vertexPolyIndexMarks[faceIndex+1], 1)
-			vertexPolyIndexMarks[faceIndex+2] = markPoly(vertexPolyIndexMarks[faceIndex+2], 1)
-
-			vertMarks[firstInd] = markPoly(vertMarks[firstInd], 1)
-			vertMarks[firstInd+1] = markPoly(vertMarks[firstInd+1], 1)
-			vertMarks[firstInd+2] = markPoly(vertMarks[firstInd+2], 1)
-			vertMarks[secondInd] = markPoly(vertMarks[secondInd], 1)
-			vertMarks[secondInd+1] = markPoly(vertMarks[secondInd+1], 1)
-			vertMarks[secondInd+2] = markPoly(vertMarks[secondInd+2], 1)
-			vertMarks[wrapInd] = markPoly(vertMarks[wrapInd], 1)
-			vertMarks[wrapInd+1] = markPoly(vertMarks[wrapInd+1], 1)
-			vertMarks[wrapInd+2] = markPoly(vertMarks[wrapInd+2], 1)
+	// Count what each range of vertices keeps so every range knows where to
+	// write
+	retainedVertexCounts := make([]int, ranges)
+	clippedVertexCounts := make([]int, ranges)
+	forEachRange(numPoints, ranges, func(r, start, end int) {
+		for p := start; p < end; p++ {
+			if vertMarks[p]&1 != 0 {
+				retainedVertexCounts[r]++
+			}
+			if vertMarks[p]&2 != 0 {
+				clippedVertexCounts[r]++
+			}
 		}
-		if neg == 3 {
-			vertexPolyIndexMarks[faceIndex] = markPoly(vertexPolyIndexMarks[faceIndex], 2)
-			vertexPolyIndexMarks[faceIndex+1] = markPoly(vertexPolyIndexMarks[faceIndex+1], 2)
-			vertexPolyIndexMarks[faceIndex+2] = markPoly(vertexPolyIndexMarks[faceIndex+2], 2)
-
-			vertMarks[firstInd] = markPoly(vertMarks[firstInd], 2)
-			vertMarks[firstInd+1] = markPoly(vertMarks[firstInd+1], 2)
-			vertMarks[firstInd+2] = markPoly(vertMarks[firstInd+2], 2)
-			vertMarks[secondInd] = markPoly(vertMarks[secondInd], 2)
-			vertMarks[secondInd+1] = markPoly(vertMarks[secondInd+1], 2)
-			vertMarks[secondInd+2] = markPoly(vertMarks[secondInd+2], 2)
-			vertMarks[wrapInd] = markPoly(vertMarks[wrapInd], 2)
-			vertMarks[wrapInd+1] = markPoly(vertMarks[wrapInd+1], 2)
-			vertMarks[wrapInd+2] = markPoly(vertMarks[wrapInd+2], 2)
+	})
+	retainedVertexStarts, retainedVertexTotal := rangeCounts(retainedVertexCounts)
+	clippedVertexStarts, clippedVertexTotal := rangeCounts(clippedVertexCounts)
+
+	// Keep up with what made it to each side so layer elements can follow
+	var retainedDomain, clippedDomain layerElementDomain
+	retainedDomain.vertices = make([]int, retainedVertexTotal)
+	clippedDomain.vertices = make([]int, clippedVertexTotal)
+
+	retainedVertexes := make([]float64, retainedVertexTotal*3)
+	clippedVertexes := make([]float64, clippedVertexTotal*3)
+
+	// How far each vertex moves down once the ones before it that didn't make
+	// it are removed
+	retainedVertexOffsets := make([]int32, numPoints)
+	clippedVertexOffsets := make([]int32, numPoints)
+
+	forEachRange(numPoints, ranges, func(r, start, end int) {
+		curRetained := retainedVertexStarts[r]
+		curClipped := clippedVertexStarts[r]
+
+		for p := start; p < end; p++ {
+			retainedVertexOffsets[p] = int32(p - curRetained)
+			clippedVertexOffsets[p] = int32(p - curClipped)
+
+			mark := vertMarks[p]
+			if mark&1 != 0 {
+				retainedDomain.vertices[curRetained] = p
+				copy(retainedVertexes[curRetained*3:], vertice[p*3:p*3+3])
+				curRetained++
+			}
+			if mark&2 != 0 {
+				clippedDomain.vertices[curClipped] = p
+				copy(clippedVertexes[curClipped*3:], vertice[p*3:p*3+3])
+				curClipped++
+			}
 		}
-	}
-
-	clippedVertexes := make([]float64, 0)
-	clippedVertexOffsets := make([]int32, len(vertice)/3)
-	curClippedOffset := 0
-
-	retainedVertexes := make([]float64, 0)
-	retainedVertexOffsets := make([]int32, len(vertice)/3)
-	curRetainedOffset := 0
-
-	numPoints := len(vertMarks) / 3
-
-	for p := 0; p < numPoints; p++ {
-		clippedVertexOffsets[p] = int32(curClippedOffset)
-		retainedVertexOffsets[p] = int32(curRetainedOffset)
+	})
 
-		startingVertIndex := p * 3
-		mark := vertMarks[startingVertIndex]
-		if mark == 0 {
-			curClippedOffset++
-			curRetainedOffset++
+	retainedFaceCounts := make([]int, ranges)
+	clippedFaceCounts := make([]int, ranges)
+	forEachRange(numFaces, ranges, func(r, start, end int) {
+		for f := start; f < end; f++ {
+			switch faceMarks[f] {
+			case 1:
+				retainedFaceCounts[r]++
+			case 2:
+				clippedFaceCounts[r]++
+			}
 		}
+	})
+	retainedFaceStarts, retainedFaceTotal := rangeCounts(retainedFaceCounts)
+	clippedFaceStarts, clippedFaceTotal := rangeCounts(clippedFaceCounts)
+
+	retainedPolyVertexIndices := make([]int32, retainedFaceTotal*3)
+	clippedPolyVertexIndices := make([]int32, clippedFaceTotal*3)
+	retainedDomain.polygons = make([]int, retainedFaceTotal)
+	clippedDomain.polygons = make([]int, clippedFaceTotal)
+	retainedDomain.polygonVertices = make([]int, retainedFaceTotal*3)
+	clippedDomain.polygonVertices = make([]int, clippedFaceTotal*3)
+
+	forEachRange(numFaces, ranges, func(r, start, end int) {
+		curRetained := retainedFaceStarts[r]
+		curClipped := clippedFaceStarts[r]
+
+		for f := start; f < end; f++ {
+			faceIndex := f * 3
+
+			var offsets []int32
+			var indices []int32
+			var domain *layerElementDomain
+			var cur *int
+
+			switch faceMarks[f] {
+			case 1:
+				offsets, indices, domain, cur = retainedVertexOffsets, retainedPolyVertexIndices, &retainedDomain, &curRetained
+			case 2:
+				offsets, indices, domain, cur = clippedVertexOffsets, clippedPolyVertexIndices, &clippedDomain, &curClipped
+			default:
+				continue
+			}
 
-		if mark == 1 {
-			curClippedOffset++
-			retainedVertexes = append(retainedVertexes, vertice[startingVertIndex], vertice[startingVertIndex+1], vertice[startingVertIndex+2])
-		}
+			offsetOne := offsets[verticeIndexes[faceIndex]]
+			offsetTwo := offsets[verticeIndexes[faceIndex+1]]
+			offsetThree := offsets[WrapToIndex(verticeIndexes[faceIndex+2])]
 
-		if mark == 2 {
-			curRetainedOffset++
-			clippedVertexes = append(clippedVertexes, vertice[startingVertIndex], vertice[startingVertIndex+1], vertice[startingVertIndex+2])
-		}
+			out := *cur * 3
+			indices[out] = verticeIndexes[faceIndex] - offsetOne
+			indices[out+1] = verticeIndexes[faceIndex+1] - offsetTwo
+			indices[out+2] = verticeIndexes[faceIndex+2] + offsetThree
 
-		if mark == 3 {
-			retainedVertexes = append(retainedVertexes, vertice[startingVertIndex], vertice[startingVertIndex+1], vertice[startingVertIndex+2])
-			clippedVertexes = append(clippedVertexes, vertice[startingVertIndex], vertice[startingVertIndex+1], vertice[startingVertIndex+2])
+			domain.polygons[*cur] = f
+			domain.polygonVertices[out] = faceIndex
+			domain.polygonVertices[out+1] = faceIndex + 1
+			domain.polygonVertices[out+2] = faceIndex + 2
+			*cur++
 		}
+	})
 
-	}
+	// log.Printf("Retained: %d", len(retainedPolyVertexIndices)/3)
+	// log.Printf("clipped: %d", len(clippedPolyVertexIndices)/3)
 
-	clippedPolyVertexIndices := make([]int32, 0)
-	retainedPolyVertexIndices := make([]int32, 0)
+	// Compressing each side is the slowest part left, so when working in
+	// parallel both sides are built at the same time
+	var retained, clipped []Diff
+	buildClipped := func() {
+		clipped = append(
+			[]Diff{
+				NewArrayPropertyDiff(vertexNodes[0].id, NewArrayPropertyFloat64CompressedSlice(clippedVertexes)),
+				NewArrayPropertyDiff(polyVertexNodes[0].id, NewArrayPropertyInt32CompressedSlice(clippedPolyVertexIndices)),
+			},
+			splitLayerElements(geomNode, numPoints, len(verticeIndexes), numFaces, clippedDomain)...,
+		)
+	}
 
-	for f := 0; f < numFaces; f++ {
-		faceIndex := f * 3
-		mark := vertexPolyIndexMarks[faceIndex]
+	clippedBuilt := make(chan bool, 1)
+	if ranges > 1 {
+		go func() {
+			buildClipped()
+			clippedBuilt <- true
+		}()
+	}
 
-		if mark == 1 || mark == 3 {
-			offsetOne := retainedVertexOffsets[verticeIndexes[faceIndex]]
-			offsetTwo := retainedVertexOffsets[verticeIndexes[faceIndex+1]]
-			offsetThree := retainedVertexOffsets[WrapToIndex(verticeIndexes[faceIndex+2])]
-			retainedPolyVertexIndices = append(retainedPolyVertexIndices, verticeIndexes[faceIndex]-offsetOne, verticeIndexes[faceIndex+1]-offsetTwo, verticeIndexes[faceIndex+2]+offsetThree)
-			continue
-		}
+	retained = append(
+		[]Diff{
+			NewArrayPropertyDiff(vertexNodes[0].id, NewArrayPropertyFloat64CompressedSlice(retainedVertexes)),
+			NewArrayPropertyDiff(polyVertexNodes[0].id, NewArrayPropertyInt32CompressedSlice(retainedPolyVertexIndices)),
+		},
+		splitLayerElements(geomNode, numPoints, len(verticeIndexes), numFaces, retainedDomain)...,
+	)
 
-		if mark == 2 || mark == 3 {
-			offsetOne := clippedVertexOffsets[verticeIndexes[faceIndex]]
-			offsetTwo := clippedVertexOffsets[verticeIndexes[faceIndex+1]]
-			offsetThree := clippedVertexOffsets[WrapToIndex(verticeIndexes[faceIndex+2])]
-			clippedPolyVertexIndices = append(clippedPolyVertexIndices, verticeIndexes[faceIndex]-offsetOne, verticeIndexes[faceIndex+1]-offsetTwo, verticeIndexes[faceIndex+2]+offsetThree)
-		}
+	if ranges > 1 {
+		<-clippedBuilt
+	} else {
+		buildClipped()
 	}
 
-	// log.Printf("Retained: %d", len(retainedPolyVertexIndices)/3)
-	// log.Printf("clipped: %d", len(clippedPolyVertexIndices)/3)
-
-	return []Diff{
-		// NewArrayPropertyDiff(vertexNodes[0].id, NewArrayPropertyFloat64CompressedSlice(retainedVertexes)),
-		// NewArrayPropertyDiff(polyVertexNodes[0].id, NewArrayPropertyInt32CompressedSlice(retainedPolyVertexIndices)),
-		},
-		[]Diff{
-			NewArrayPropertyDiff(vertexNodes[0].id, NewArrayPropertyFloat64CompressedSlice(clippedVertexes)),
-			NewArrayPropertyDiff(polyVertexNodes[0].id, NewArrayPropertyInt32CompressedSlice(clippedPolyVertexIndices)),
-		}
+	return retained, clipped
 }
 
 func insertNewDiff(existingDiffs []Diff, newDiff Diff) []Diff {
@@ -266,43 +456,61 @@ func insertNewDiff(existingDiffs []Diff, newDiff Diff) []Diff {
 	return result
 }
 
-func combineSorted(sortedArrays ...[]Diff) []Diff {
-	resultLen := 0
-	for _, a := range sortedArrays {
-		resultLen += len(a)
+// geometryPlane expresses the world space plane in the local space of the
+// geometry node so the geometry's vertices can be classified directly.
+// Returns false if the geometry's transform collapses it and there's no
+// local space to express the plane in.
+func geometryPlane(geomNode *Node, plane Plane, transforms map[int64]Matrix4) (Plane, bool) {
+	if len(geomNode.Properties) == 0 || geomNode.Properties[0].TypeCode != 'L' {
+		return plane, true
 	}
-
-	result := make([]Diff, resultLen)
-	sortedArrayIndexes := make([]int, len(sortedArrays))
-
-	for resultIndex := 0; resultIndex < resultLen; resultIndex++ {
-
-		currentLowestArray := -1
-		lowestValue := uint64(math.MaxInt64)
-
-		for sortedArrayIndex, sortedArray := range sortedArrays {
-			if sortedArrayIndexes[sortedArrayIndex] < len(sortedArray) {
-				if currentLowestArray == -1 || sortedArray[sortedArrayIndexes[sortedArrayIndex]].NodeID() < uint64(lowestValue) {
-					currentLowestArray = sortedArrayIndex
-					lowestValue = sortedArray[sortedArrayIndexes[sortedArrayIndex]].NodeID()
-				}
-			}
-		}
-
-		result[resultIndex] = sortedArrays[currentLowestArray][sortedArrayIndexes[currentLowestArray]]
-		sortedArrayIndexes[currentLowestArray]++
+	world, ok := transforms[geomNode.Properties[0].AsInt64()]
+	if ok == false {
+		return plane, true
 	}
+	return plane.ToLocal(world)
+}
 
-	return result
+// splitJob is a batch of nodes from the reader, numbered in the order it was
+// sent
+type splitJob struct {
+	index int
+	nodes []*Node
 }
 
-func worker(id int, plane Plane, jobs <-chan []*Node, results chan<- WorkerResult) {
-	allRetainedPolygons := make([]Diff, 0)
-	allClippedPolygons := make([]Diff, 0)
+// numberJobs numbers the batches of nodes coming from a reader, closing the
+// numbered jobs once the reader is done
+func numberJobs(batches <-chan []*Node, jobs chan<- splitJob) {
+	index := 0
+	for nodes := range batches {
+		jobs <- splitJob{index: index, nodes: nodes}
+		index++
+	}
+	close(jobs)
+}
 
+// worker splits every node it's given, handing geometry large enough to keep
+// the whole pool busy off to be split in that many pieces at once. The sorted
+// diffs of each job are sent off as soon as it's done.
+func worker(id int, workers int, plane Plane, transforms map[int64]Matrix4, batcher *JobBatcher, jobs <-chan splitJob, results chan<- WorkerResult) {
 	for j := range jobs {
-		for _, n := range j {
-			retained, clipped := SplitByPlane(n, plane)
+		allRetainedPolygons := make([]Diff, 0)
+		allClippedPolygons := make([]Diff, 0)
+
+		start := time.Now()
+		jobSize := int64(0)
+		for _, n := range j.nodes {
+			jobSize += int64(n.Length)
+			ranges := 1
+			if n.Length > parallelSplitThreshold {
+				ranges = workers
+			}
+			local, ok := geometryPlane(n, plane, transforms)
+			if ok == false {
+				log.Printf("Skipping geometry %d, it's transform can't be inverted", Object{node: n}.UID())
+				continue
+			}
+			retained, clipped := SplitByPlaneInParallel(n, local, ranges)
 			allRetainedPolygons = append(allRetainedPolygons, retained...)
 			// for _, d := range retained {
 			// 	allRetainedPolygons = append(allRetainedPolygons, d)
@@ -312,14 +520,22 @@ func worker(id int, plane Plane, jobs <-chan []*Node, results chan<- WorkerResul
 			// 	allClippedPolygons = append(allClippedPolygons, d)
 			// }
 		}
-	}
+		batcher.Observe(jobSize, time.Since(start))
 
-	sort.Sort(SortDiff(allClippedPolygons))
-	sort.Sort(SortDiff(allRetainedPolygons))
-	results <- WorkerResult{clipped: allClippedPolygons, retained: allRetainedPolygons}
+		sort.Sort(SortDiff(allClippedPolygons))
+		sort.Sort(SortDiff(allRetainedPolygons))
+		result := WorkerResult{job: j.index, clipped: allClippedPolygons, retained: allRetainedPolygons}
+		if len(j.nodes) > 0 {
+			result.through = j.nodes[len(j.nodes)-1].endingID
+		}
+		results <- result
+	}
 }
 
-// SplitByPlaneProgram loads in a FBX model and splits it
+// SplitByPlaneProgram loads in a FBX model and splits it by a plane given in
+// world space, writing both halves out as FBX. Geometry is classified in world
+// space using the transforms of the models it's attached to, while it's local
+// vertices are what's written.
 func SplitByPlaneProgram(
 	modelName string,
 	plane Plane,
@@ -327,107 +543,409 @@ func SplitByPlaneProgram(
 	retained io.Writer,
 	clipped io.Writer,
 ) *FBX {
+	return SplitByPlaneIntoChunks(modelName, plane, workers, NewFBXChunkWriter(retained), NewFBXChunkWriter(clipped))
+}
+
+// SplitByPlaneIntoChunks loads in a FBX, PLY or OBJ model and splits it by a plane
+// given in world space, handing both halves off to chunk writers
+func SplitByPlaneIntoChunks(
+	modelName string,
+	plane Plane,
+	workers int,
+	retained ChunkWriter,
+	clipped ChunkWriter,
+) *FBX {
+	// Jobs are sized off of how large the file is and how quickly the
+	// workers get through them
+	fileSize := int64(0)
+	if info, err := os.Stat(modelName); err == nil {
+		fileSize = info.Size()
+	}
+	batcher := NewJobBatcher(fileSize, workers)
+
+	load := func(jobs chan<- []*Node, fbx chan<- *FBX) { loadModel(modelName, batcher, jobs, fbx) }
+	var transforms map[int64]Matrix4
+
+	switch modelFormat(modelName) {
+	case ".ply":
+		// Geometry converted from other formats is already in world space
+		load = func(jobs chan<- []*Node, fbx chan<- *FBX) { loadPLY(modelName, batcher, jobs, fbx) }
+	case ".obj":
+		load = func(jobs chan<- []*Node, fbx chan<- *FBX) { loadOBJ(modelName, batcher, jobs, fbx) }
+	default:
+		timer.begin(fmt.Sprintf("Evaluating model transforms of %s", modelName))
+		transforms = loadGeometryTransforms(modelName)
+		timer.end()
+	}
+
 	timer.begin(fmt.Sprintf("Loading and splitting %s by plane with %d workers", modelName, workers))
 
-	jobs := make(chan []*Node, 10000)
+	batches := make(chan []*Node, 10000)
+	jobs := make(chan splitJob, 10000)
 	workerOutput := make(chan WorkerResult, 10000)
 	finalFBX := make(chan *FBX)
 
 	// start workers before attempting to load model
+	var running sync.WaitGroup
 	for w := 0; w < workers; w++ {
-		go worker(w, plane, jobs, workerOutput)
+		running.Add(1)
+		go func(w int) {
+			defer running.Done()
+			worker(w, workers, plane, transforms, batcher, jobs, workerOutput)
+		}(w)
 	}
+	go func() {
+		running.Wait()
+		close(workerOutput)
+	}()
+
+	go numberJobs(batches, jobs)
+	go load(batches, finalFBX)
+
+	// Diffs are put in order as jobs finish, instead of all at once after
+	// everything has been split
+	merger := newDiffMerger()
+	merged := make(chan bool)
+	go func() {
+		for r := range workerOutput {
+			merger.add(r)
+		}
+		merger.finish()
+		merged <- true
+	}()
 
-	go loadModel(modelName, jobs, finalFBX)
-
-	workerResultsRetained := make([][]Diff, workers)
-	workerResultsClipped := make([][]Diff, workers)
-
-	for i := 0; i < workers; i++ {
-		r := <-workerOutput
-		workerResultsRetained[i] = r.retained
-		workerResultsClipped[i] = r.clipped
+	fbx := <-finalFBX
+	graph := NewConnectionGraph(fbx)
+
+	// FBX files asked to stream start being written as soon as the file has
+	// been read, while the rest of the geometry is still being split
+	streamedRetained, retained := streamedOutputs(retained)
+	streamedClipped, clipped := streamedOutputs(clipped)
+	side := func(retainedSide bool) diffSource {
+		return func(id uint64) []Diff {
+			retained, clipped := merger.upTo(id)
+			if retainedSide {
+				return retained
+			}
+			return clipped
+		}
+	}
+	streamed := [][]*FBXChunkWriter{streamedRetained, streamedClipped}
+	streamErrs := []chan error{make(chan error, len(streamedRetained)), make(chan error, len(streamedClipped))}
+	for i, diffs := range []diffSource{side(true), side(false)} {
+		for _, cw := range streamed[i] {
+			go func(cw *FBXChunkWriter, diffs diffSource, result chan<- error) {
+				w, _ := cw.streamOutput()
+				sw := NewStreamPatchWriter(fbx, graph)
+				sw.Verify = cw.Verify
+				_, err := sw.Write(w, diffs)
+				result <- err
+			}(cw, diffs, streamErrs[i])
+		}
 	}
-	allRetainedPolygons := combineSorted(workerResultsRetained...)
-	allClippedPolygons := combineSorted(workerResultsClipped...)
 
-	fbx := <-finalFBX
+	<-merged
+	allRetainedPolygons := merger.retained
+	allClippedPolygons := merger.clipped
+	timer.end()
+
+	timer.begin("Removing empty geometry")
+	allRetainedPolygons = RemoveEmptyGeometry(fbx, graph, allRetainedPolygons)
+	allClippedPolygons = RemoveEmptyGeometry(fbx, graph, allClippedPolygons)
+	allRetainedPolygons = UpdateDefinitionCounts(fbx, allRetainedPolygons)
+	allClippedPolygons = UpdateDefinitionCounts(fbx, allClippedPolygons)
 	timer.end()
 
 	timer.begin(fmt.Sprintf("Writing results"))
 	defer timer.end()
 
-	retainedWriterError := make(chan error)
-	clippedWriterError := make(chan error)
-	retainedWriter := NewPatchWriter(fbx, allRetainedPolygons, func(n int, e error) {
-		retainedWriterError <- e
-		close(retainedWriterError)
-	})
-	clippedWriter := NewPatchWriter(fbx, allClippedPolygons, func(n int, e error) {
-		clippedWriterError <- e
-		close(clippedWriterError)
+	errs := WriteChunks(fbx, []Chunk{
+		{Writer: retained, Diffs: allRetainedPolygons},
+		{Writer: clipped, Diffs: allClippedPolygons},
 	})
+	for i := range streamed {
+		for range streamed[i] {
+			if err := <-streamErrs[i]; err != nil && errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
 
-	go retainedWriter.Write(retained)
-	go clippedWriter.Write(clipped)
-
-	retErr := <-retainedWriterError
-	if retErr != nil {
-		log.Printf("Error writing to retained: %s", retErr.Error())
+	if errs[0] != nil {
+		log.Printf("Error writing to retained: %s", errs[0].Error())
 	}
 
-	clipErr := <-clippedWriterError
-	if clipErr != nil {
-		log.Printf("Error writing to clipped: %s", clipErr.Error())
+	if errs[1] != nil {
+		log.Printf("Error writing to clipped: %s", errs[1].Error())
 	}
 
 	return fbx
 }
 
-func main() {
+// loadAxisSystem reads just the GlobalSettings of the file to determine it's
+// units and axes
+func loadAxisSystem(modelName string) AxisSystem {
+	// Other formats have no notion of units or axes, and are converted into
+	// FBX as meters with Y up
+	switch modelFormat(modelName) {
+	case ".ply", ".obj":
+		return streamedAxisSystem()
+	}
 
-	retainedOut, err := os.Create("o-retained.fbx")
+	f, err := os.Open(modelName)
 	check(err)
-	defer retainedOut.Close()
+	defer f.Close()
+
+	reader := NewReaderWithFilters(nil, nil, FilterName("GlobalSettings"))
+	reader.ReadFrom(f)
+	check(reader.Error)
+
+	return NewAxisSystem(reader.FBX)
+}
+
+func parseVector3(s string) (vector.Vector3, error) {
+	components := strings.Split(s, ",")
+	if len(components) != 3 {
+		return vector.Vector3Zero(), fmt.Errorf("expected 3 comma separated components, got '%s'", s)
+	}
+
+	values := make([]float64, 3)
+	for i, c := range components {
+		v, err := strconv.ParseFloat(strings.TrimSpace(c), 64)
+		if err != nil {
+			return vector.Vector3Zero(), err
+		}
+		values[i] = v
+	}
+	return vector.NewVector3(values[0], values[1], values[2]), nil
+}
 
-	clippedOut, err := os.Create("o-clipped.fbx")
+// newFBXOutput writes straight to the file, either while geometry is still
+// being split when stream is set, or once it's done by encoding and writing
+// every part of the FBX at the same time when parallel is set instead of
+// sharing the single pass with the other FBX output. The offsets of
+// everything written are checked when verify is set.
+func newFBXOutput(create func(string) *os.File, verify, parallel, stream bool) func(string) ChunkWriter {
+	return func(fileName string) ChunkWriter {
+		w := NewFBXChunkWriter(create(fileName))
+		w.Verify = verify
+		w.Parallel = parallel
+		w.Stream = stream
+		return w
+	}
+}
+
+func newGLBOutput(create func(string) io.Writer) func(string) ChunkWriter {
+	return func(fileName string) ChunkWriter {
+		return NewGLBChunkWriter(create(fileName))
+	}
+}
+
+// newOBJOutput writes materials to a file with the same name as the OBJ but
+// ending in .mtl
+func newOBJOutput(create func(string) io.Writer) func(string) ChunkWriter {
+	return func(fileName string) ChunkWriter {
+		mtlFileName := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".mtl"
+		return NewOBJChunkWriter(create(fileName), create(mtlFileName), filepath.Base(mtlFileName))
+	}
+}
+
+func splitCommand(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	modelName := flags.String("in", "HIB-model.fbx", "FBX, PLY or OBJ file to split")
+	originFlag := flags.String("origin", "105.4350,119.4877,77.9060", "point on the splitting plane as x,y,z")
+	normalFlag := flags.String("normal", "0,1,0", "normal of the splitting plane as x,y,z")
+	workers := flags.Int("workers", 3, "number of workers splitting geometry")
+	canonical := flags.Bool("canonical", false, "plane is given in meters with +Y up instead of the file's own units and axes")
+	retainedName := flags.String("retained", "o-retained.fbx", "where to write geometry in front of the plane as FBX, empty to skip")
+	clippedName := flags.String("clipped", "o-clipped.fbx", "where to write geometry behind the plane as FBX, empty to skip")
+	retainedGLBName := flags.String("retained-glb", "", "where to write geometry in front of the plane as GLB")
+	clippedGLBName := flags.String("clipped-glb", "", "where to write geometry behind the plane as GLB")
+	retainedOBJName := flags.String("retained-obj", "", "where to write geometry in front of the plane as OBJ, with materials in a .mtl next to it")
+	clippedOBJName := flags.String("clipped-obj", "", "where to write geometry behind the plane as OBJ, with materials in a .mtl next to it")
+	verify := flags.Bool("verify", false, "check every node's offsets against the bytes actually written to the FBX outputs")
+	parallelWrite := flags.Bool("parallel-write", false, "encode and write each FBX output at it's offsets in parallel instead of writing every FBX output in one shared pass")
+	streamWrite := flags.Bool("stream-write", false, "write each FBX output while geometry is still being split, keeping object types left without objects in Definitions with a count of 0")
+	flags.Parse(args)
+
+	origin, err := parseVector3(*originFlag)
+	check(err)
+
+	normal, err := parseVector3(*normalFlag)
+	check(err)
+
+	plane := NewPlane(origin, normal)
+	if *canonical {
+		plane = loadAxisSystem(*modelName).PlaneToFile(plane)
+	}
+
+	// Buffered files are flushed and closed once everything has been written
+	files := make([]*os.File, 0)
+	buffers := make([]*bufio.Writer, 0)
+	defer func() {
+		for _, w := range buffers {
+			check(w.Flush())
+		}
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	createFile := func(fileName string) *os.File {
+		f, err := os.Create(fileName)
+		check(err)
+		files = append(files, f)
+		return f
+	}
+
+	create := func(fileName string) io.Writer {
+		w := bufio.NewWriter(createFile(fileName))
+		buffers = append(buffers, w)
+		return w
+	}
+
+	retained := MultiChunkWriter{}
+	clipped := MultiChunkWriter{}
+
+	outputs := []struct {
+		fileName  string
+		newWriter func(fileName string) ChunkWriter
+		chunk     *MultiChunkWriter
+	}{
+		{*retainedName, newFBXOutput(createFile, *verify, *parallelWrite, *streamWrite), &retained},
+		{*clippedName, newFBXOutput(createFile, *verify, *parallelWrite, *streamWrite), &clipped},
+		{*retainedGLBName, newGLBOutput(create), &retained},
+		{*clippedGLBName, newGLBOutput(create), &clipped},
+		{*retainedOBJName, newOBJOutput(create), &retained},
+		{*clippedOBJName, newOBJOutput(create), &clipped},
+	}
+
+	for _, output := range outputs {
+		if output.fileName == "" {
+			continue
+		}
+		*output.chunk = append(*output.chunk, output.newWriter(output.fileName))
+	}
+
+	SplitByPlaneIntoChunks(*modelName, plane, *workers, retained, clipped)
+}
+
+func infoCommand(args []string) {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	modelName := flags.String("in", "HIB-model.fbx", "FBX file to analyze")
+	workers := flags.Int("workers", 3, "number of workers decoding geometry")
+	asJSON := flags.Bool("json", false, "output JSON instead of a table")
+	flags.Parse(args)
+
+	info, err := InfoProgram(*modelName, *workers)
 	check(err)
-	defer clippedOut.Close()
-
-	// out, err := os.Create("out.txt")
-	// check(err)
-	// defer out.Close()
-
-	// log.Printf("Retained Model Polygon Count: %d", len(retained.GetFaces()))
-	// log.Printf("Clipped Model Polygon Count: %d", len(clipped.GetFaces()))
-
-	// SplitByPlaneProgram("dragon_vrip.fbx", NewPlane(vector.Vector3Zero(), vector.Vector3Forward()), 3, retainedOut, clippedOut)
-	// SplitByPlaneProgram("spheres.fbx", NewPlane(vector.Vector3Zero(), vector.Vector3Forward()), 3, retainedOut, clippedOut)
-	// expand(out, fbx.Top)
-	// for _, c := range fbx.Nodes {
-	// 	expand(out, c)
-	// }
-
-	// f, err := os.Open("retained.fbx")
-	// check(err)
-	// defer f.Close()
-
-	// reader := NewReader()
-	// reader.ReadFrom(f)
-	// check(reader.Error)
-	// expand(out, reader.FBX.Top)
-	// for _, c := range reader.FBX.Nodes {
-	// 	expand(out, c)
-	// }
-
-	// save(retained, "retained.obj")
-	// save(clipped, "clipped.obj")
-
-	SplitByPlaneProgram("HIB-model.fbx", NewPlane(vector.NewVector3(105.4350, 119.4877, 77.9060), vector.Vector3Up()), 3, retainedOut, clippedOut)
-	// log.Printf("Retained Model Polygon Count: %d", len(retained.GetFaces()))
-	// log.Printf("Clipped Model Polygon Count: %d", len(clipped.GetFaces()))
-	// log.Print(retained.GetCenterOfBoundingBox())
 
+	if *asJSON {
+		check(info.WriteJSON(os.Stdout))
+	} else {
+		check(info.WriteText(os.Stdout))
+	}
+}
+
+func generateCommand(args []string) {
+	defaults := DefaultGeneratorConfig()
+
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	out := flags.String("out", "generated.fbx", "where to write the generated FBX")
+	seed := flags.Int64("seed", defaults.Seed, "seed for the random geometry, the same seed always makes the same file")
+	geometry := flags.Int("geometry", defaults.Geometry, "number of geometry nodes")
+	triangles := flags.Int("triangles", defaults.TrianglesPerGeometry, "triangles per geometry node")
+	compress := flags.Bool("compress", defaults.Compress, "zlib compress array properties")
+	version := flags.Uint("version", uint(defaults.Version), "FBX version to write, versions before 7500 use 32 bit offsets")
+	normals := flags.Bool("normals", defaults.Normals, "add normals to each geometry")
+	uvs := flags.Bool("uvs", defaults.UVs, "add UVs to each geometry")
+	materials := flags.Int("materials", defaults.Materials, "number of materials polygons are randomly assigned")
+	flags.Parse(args)
+
+	f, err := os.Create(*out)
+	check(err)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	check(GenerateFBX(w, GeneratorConfig{
+		Seed:                 *seed,
+		Geometry:             *geometry,
+		TrianglesPerGeometry: *triangles,
+		Compress:             *compress,
+		Version:              uint32(*version),
+		Normals:              *normals,
+		UVs:                  *uvs,
+		Materials:            *materials,
+	}))
+	check(w.Flush())
+}
+
+func benchCommand(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	out := flags.String("out", "bench.json", "where to write the JSON report, empty to skip")
+	baselineName := flags.String("baseline", "", "report to compare against, exiting with an error on any regression")
+	tolerance := flags.Float64("tolerance", 0.1, "fraction throughput can drop or allocations can grow before it's a regression")
+	scale := flags.Float64("scale", 1, "multiplies the number of triangles in every generated file")
+	workers := flags.Int("workers", 3, "number of workers splitting geometry")
+	iterations := flags.Int("iterations", 3, "times to run each case, keeping the fastest")
+	flags.Parse(args)
+
+	report, err := RunBenchmarks(DefaultBenchmarkCases(*scale), *workers, *iterations)
+	check(err)
+
+	for _, c := range report.Cases {
+		for _, stage := range c.Stages {
+			log.Printf(
+				"%s %s: %.2f MB/s, %.0f tris/s, %d allocs",
+				c.Name, stage.Stage, stage.MBPerSecond, stage.TrianglesPerSecond, stage.Allocs,
+			)
+		}
+	}
+
+	if *out != "" {
+		f, err := os.Create(*out)
+		check(err)
+		defer f.Close()
+		check(report.WriteJSON(f))
+	}
+
+	if *baselineName == "" {
+		return
+	}
+
+	f, err := os.Open(*baselineName)
+	check(err)
+	defer f.Close()
+
+	baseline, err := ReadBenchmarkReport(f)
+	check(err)
+
+	regressions := CompareBenchmarks(baseline, report, *tolerance)
+	for _, regression := range regressions {
+		log.Print(regression)
+	}
+	if len(regressions) > 0 {
+		log.Fatalf("%d regressions from %s", len(regressions), *baselineName)
+	}
+}
+
+func main() {
+	command := "split"
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "split" || args[0] == "info" || args[0] == "generate" || args[0] == "bench") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "info":
+		infoCommand(args)
+	case "generate":
+		generateCommand(args)
+	case "bench":
+		benchCommand(args)
+	default:
+		splitCommand(args)
+	}
 }
 
 var depth = 0
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"io/ioutil"
 	"testing"
 
@@ -27,6 +28,26 @@ func BenchmarkSplitByPlane(b *testing.B) {
 
 }
 
+func BenchmarkSplitByPlaneGenerated(b *testing.B) {
+	config := DefaultGeneratorConfig()
+	config.Geometry = 1
+	config.TrianglesPerGeometry = 200000
+	config.Compress = false
+	file := new(bytes.Buffer)
+	check(GenerateFBX(file, config))
+
+	fbx, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	check(err)
+	geometry := fbx.GetNodes("Objects", "Geometry")[0]
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0.5, 0))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		SplitByPlane(geometry, plane)
+	}
+}
+
 func TestInsertionSort(t *testing.T) {
 	// ****************************** ARRANGE *********************************
 	diffs := []Diff{
@@ -117,3 +138,108 @@ func TestCombiningSortedArrays(t *testing.T) {
 		assert.Equal(t, uint64(9), results[11].NodeID())
 	}
 }
+
+func TestForEachRangeCoversEverything(t *testing.T) {
+	for _, ranges := range []int{0, 1, 3, 7, 20} {
+		// ****************************** ARRANGE *********************************
+		visited := make([]int, 10)
+
+		// ******************************** ACT ***********************************
+		forEachRange(len(visited), ranges, func(r, start, end int) {
+			for i := start; i < end; i++ {
+				visited[i]++
+			}
+		})
+
+		// ******************************* ASSERT *********************************
+		assert.Equal(t, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, visited, "ranges: %d", ranges)
+	}
+}
+
+func TestSplitByPlaneInParallelMatchesSplitByPlane(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	config := DefaultGeneratorConfig()
+	config.Geometry = 1
+	config.TrianglesPerGeometry = 5000
+	file := new(bytes.Buffer)
+	assert.NoError(t, GenerateFBX(file, config))
+
+	fbx, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	if assert.NoError(t, err) == false {
+		return
+	}
+	geometry := fbx.GetNodes("Objects", "Geometry")[0]
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0.5, 0))
+
+	// ******************************** ACT ***********************************
+	retained, clipped := SplitByPlane(geometry, plane)
+
+	// ******************************* ASSERT *********************************
+	assert.True(t, len(retained) > 2)
+	for _, ranges := range []int{2, 3, 8} {
+		parallelRetained, parallelClipped := SplitByPlaneInParallel(geometry, plane, ranges)
+		assert.Equal(t, retained, parallelRetained, "ranges: %d", ranges)
+		assert.Equal(t, clipped, parallelClipped, "ranges: %d", ranges)
+	}
+}
+
+func TestSplitByPlaneWritesRetainedSide(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(t, NewNodeParent(
+		"Objects",
+		newObjectNode(
+			"Geometry", 1, "Pair", "Mesh",
+			NewNodeFloat64Slice("Vertices", []float64{
+				-2, 0, 0, -1, 0, 0, -1, 1, 0,
+				1, 0, 0, 2, 0, 0, 2, 1, 0,
+			}),
+			NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3, 3, 4, -6}),
+		),
+	))
+	geometry := fbx.GetNodes("Objects", "Geometry")[0]
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0, 0))
+
+	// ******************************** ACT ***********************************
+	retained, clipped := SplitByPlane(geometry, plane)
+	retainedFBX := patchAndReadBack(t, fbx, retained)
+	clippedFBX := patchAndReadBack(t, fbx, clipped)
+
+	// ******************************* ASSERT *********************************
+	retainedMesh := NewScene(retainedFBX).Meshes()[0]
+	assert.Equal(t, []float64{1, 0, 0, 2, 0, 0, 2, 1, 0}, retainedMesh.Vertices())
+	assert.Equal(t, [][]int32{{0, 1, 2}}, retainedMesh.Polygons())
+
+	clippedMesh := NewScene(clippedFBX).Meshes()[0]
+	assert.Equal(t, []float64{-2, 0, 0, -1, 0, 0, -1, 1, 0}, clippedMesh.Vertices())
+	assert.Equal(t, [][]int32{{0, 1, 2}}, clippedMesh.Polygons())
+}
+
+func TestDiffMergerPutsJobsBackInOrder(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	merger := newDiffMerger()
+	first := WorkerResult{job: 0, retained: []Diff{NewArrayPropertyDiff(1, nil)}, clipped: []Diff{NewArrayPropertyDiff(2, nil)}}
+	second := WorkerResult{job: 1, retained: []Diff{NewArrayPropertyDiff(5, nil), NewArrayPropertyDiff(6, nil)}}
+	third := WorkerResult{job: 2, clipped: []Diff{NewArrayPropertyDiff(9, nil)}}
+
+	// ******************************** ACT ***********************************
+	merger.add(third)
+	merger.add(second)
+	waiting := len(merger.pending)
+	passedAlong := len(merger.retained)
+	merger.add(first)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, 2, waiting)
+	assert.Equal(t, 0, passedAlong)
+	assert.Len(t, merger.pending, 0)
+
+	ids := func(diffs []Diff) []uint64 {
+		result := make([]uint64, len(diffs))
+		for i, d := range diffs {
+			result[i] = d.NodeID()
+		}
+		return result
+	}
+	assert.Equal(t, []uint64{1, 5, 6}, ids(merger.retained))
+	assert.Equal(t, []uint64{2, 9}, ids(merger.clipped))
+}
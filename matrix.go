@@ -0,0 +1,202 @@
+package main
+
+import (
+	"math"
+
+	"github.com/EliCDavis/vector"
+)
+
+// Matrix4 is a 4x4 affine transformation stored in row major order, meant to
+// be multiplied against column vectors
+type Matrix4 [16]float64
+
+// IdentityMatrix4 creates a matrix that performs no transformation
+func IdentityMatrix4() Matrix4 {
+	return Matrix4{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// TranslationMatrix4 creates a matrix that moves points by the vector
+func TranslationMatrix4(v vector.Vector3) Matrix4 {
+	return Matrix4{
+		1, 0, 0, v.X(),
+		0, 1, 0, v.Y(),
+		0, 0, 1, v.Z(),
+		0, 0, 0, 1,
+	}
+}
+
+// ScalingMatrix4 creates a matrix that scales points along each axis
+func ScalingMatrix4(v vector.Vector3) Matrix4 {
+	return Matrix4{
+		v.X(), 0, 0, 0,
+		0, v.Y(), 0, 0,
+		0, 0, v.Z(), 0,
+		0, 0, 0, 1,
+	}
+}
+
+func rotationXMatrix4(degrees float64) Matrix4 {
+	s, c := math.Sincos(degrees * math.Pi / 180)
+	return Matrix4{
+		1, 0, 0, 0,
+		0, c, -s, 0,
+		0, s, c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+func rotationYMatrix4(degrees float64) Matrix4 {
+	s, c := math.Sincos(degrees * math.Pi / 180)
+	return Matrix4{
+		c, 0, s, 0,
+		0, 1, 0, 0,
+		-s, 0, c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+func rotationZMatrix4(degrees float64) Matrix4 {
+	s, c := math.Sincos(degrees * math.Pi / 180)
+	return Matrix4{
+		c, -s, 0, 0,
+		s, c, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// RotationOrder is the order euler angles are applied in, matching the values
+// FBX stores in a Model's "RotationOrder" property
+type RotationOrder int
+
+const (
+	RotationOrderXYZ RotationOrder = iota
+	RotationOrderXZY
+	RotationOrderYZX
+	RotationOrderYXZ
+	RotationOrderZXY
+	RotationOrderZYX
+	RotationOrderSphericXYZ
+)
+
+// EulerRotationMatrix4 creates a rotation matrix from euler angles in degrees.
+// A rotation order of XYZ rotates about the X axis first, then Y, then Z.
+func EulerRotationMatrix4(degrees vector.Vector3, order RotationOrder) Matrix4 {
+	x := rotationXMatrix4(degrees.X())
+	y := rotationYMatrix4(degrees.Y())
+	z := rotationZMatrix4(degrees.Z())
+
+	switch order {
+	case RotationOrderXZY:
+		return y.Multiply(z).Multiply(x)
+	case RotationOrderYZX:
+		return x.Multiply(z).Multiply(y)
+	case RotationOrderYXZ:
+		return z.Multiply(x).Multiply(y)
+	case RotationOrderZXY:
+		return y.Multiply(x).Multiply(z)
+	case RotationOrderZYX:
+		return x.Multiply(y).Multiply(z)
+	}
+
+	// XYZ, and spheric XYZ which FBX evaluates the same way
+	return z.Multiply(y).Multiply(x)
+}
+
+// Multiply returns m * o, which applies o first and then m
+func (m Matrix4) Multiply(o Matrix4) Matrix4 {
+	var result Matrix4
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			result[row*4+col] = m[row*4]*o[col] +
+				m[row*4+1]*o[4+col] +
+				m[row*4+2]*o[8+col] +
+				m[row*4+3]*o[12+col]
+		}
+	}
+	return result
+}
+
+// Transpose flips the matrix along it's diagonal
+func (m Matrix4) Transpose() Matrix4 {
+	var result Matrix4
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			result[col*4+row] = m[row*4+col]
+		}
+	}
+	return result
+}
+
+// MultiplyPoint transforms the point, taking translation into account
+func (m Matrix4) MultiplyPoint(v vector.Vector3) vector.Vector3 {
+	return vector.NewVector3(
+		m[0]*v.X()+m[1]*v.Y()+m[2]*v.Z()+m[3],
+		m[4]*v.X()+m[5]*v.Y()+m[6]*v.Z()+m[7],
+		m[8]*v.X()+m[9]*v.Y()+m[10]*v.Z()+m[11],
+	)
+}
+
+// MultiplyDirection transforms the direction, ignoring translation
+func (m Matrix4) MultiplyDirection(v vector.Vector3) vector.Vector3 {
+	return vector.NewVector3(
+		m[0]*v.X()+m[1]*v.Y()+m[2]*v.Z(),
+		m[4]*v.X()+m[5]*v.Y()+m[6]*v.Z(),
+		m[8]*v.X()+m[9]*v.Y()+m[10]*v.Z(),
+	)
+}
+
+// determinant is the determinant of the rotation and scale part of the
+// matrix, which is negative when the matrix mirrors whatever it transforms
+func (m Matrix4) determinant() float64 {
+	return m[0]*(m[5]*m[10]-m[6]*m[9]) -
+		m[1]*(m[4]*m[10]-m[6]*m[8]) +
+		m[2]*(m[4]*m[9]-m[5]*m[8])
+}
+
+// Inverse computes the inverse of the matrix, returning false if the matrix
+// can not be inverted (a scale of zero somewhere along the way). How close to
+// zero the determinant can get is relative to the largest scale in the
+// matrix, so uniformly tiny scales still invert while collapsed axes don't.
+func (m Matrix4) Inverse() (Matrix4, bool) {
+	var inv Matrix4
+
+	inv[0] = m[5]*m[10]*m[15] - m[5]*m[11]*m[14] - m[9]*m[6]*m[15] + m[9]*m[7]*m[14] + m[13]*m[6]*m[11] - m[13]*m[7]*m[10]
+	inv[4] = -m[4]*m[10]*m[15] + m[4]*m[11]*m[14] + m[8]*m[6]*m[15] - m[8]*m[7]*m[14] - m[12]*m[6]*m[11] + m[12]*m[7]*m[10]
+	inv[8] = m[4]*m[9]*m[15] - m[4]*m[11]*m[13] - m[8]*m[5]*m[15] + m[8]*m[7]*m[13] + m[12]*m[5]*m[11] - m[12]*m[7]*m[9]
+	inv[12] = -m[4]*m[9]*m[14] + m[4]*m[10]*m[13] + m[8]*m[5]*m[14] - m[8]*m[6]*m[13] - m[12]*m[5]*m[10] + m[12]*m[6]*m[9]
+	inv[1] = -m[1]*m[10]*m[15] + m[1]*m[11]*m[14] + m[9]*m[2]*m[15] - m[9]*m[3]*m[14] - m[13]*m[2]*m[11] + m[13]*m[3]*m[10]
+	inv[5] = m[0]*m[10]*m[15] - m[0]*m[11]*m[14] - m[8]*m[2]*m[15] + m[8]*m[3]*m[14] + m[12]*m[2]*m[11] - m[12]*m[3]*m[10]
+	inv[9] = -m[0]*m[9]*m[15] + m[0]*m[11]*m[13] + m[8]*m[1]*m[15] - m[8]*m[3]*m[13] - m[12]*m[1]*m[11] + m[12]*m[3]*m[9]
+	inv[13] = m[0]*m[9]*m[14] - m[0]*m[10]*m[13] - m[8]*m[1]*m[14] + m[8]*m[2]*m[13] + m[12]*m[1]*m[10] - m[12]*m[2]*m[9]
+	inv[2] = m[1]*m[6]*m[15] - m[1]*m[7]*m[14] - m[5]*m[2]*m[15] + m[5]*m[3]*m[14] + m[13]*m[2]*m[7] - m[13]*m[3]*m[6]
+	inv[6] = -m[0]*m[6]*m[15] + m[0]*m[7]*m[14] + m[4]*m[2]*m[15] - m[4]*m[3]*m[14] - m[12]*m[2]*m[7] + m[12]*m[3]*m[6]
+	inv[10] = m[0]*m[5]*m[15] - m[0]*m[7]*m[13] - m[4]*m[1]*m[15] + m[4]*m[3]*m[13] + m[12]*m[1]*m[7] - m[12]*m[3]*m[5]
+	inv[14] = -m[0]*m[5]*m[14] + m[0]*m[6]*m[13] + m[4]*m[1]*m[14] - m[4]*m[2]*m[13] - m[12]*m[1]*m[6] + m[12]*m[2]*m[5]
+	inv[3] = -m[1]*m[6]*m[11] + m[1]*m[7]*m[10] + m[5]*m[2]*m[11] - m[5]*m[3]*m[10] - m[9]*m[2]*m[7] + m[9]*m[3]*m[6]
+	inv[7] = m[0]*m[6]*m[11] - m[0]*m[7]*m[10] - m[4]*m[2]*m[11] + m[4]*m[3]*m[10] + m[8]*m[2]*m[7] - m[8]*m[3]*m[6]
+	inv[11] = -m[0]*m[5]*m[11] + m[0]*m[7]*m[9] + m[4]*m[1]*m[11] - m[4]*m[3]*m[9] - m[8]*m[1]*m[7] + m[8]*m[3]*m[5]
+	inv[15] = m[0]*m[5]*m[10] - m[0]*m[6]*m[9] - m[4]*m[1]*m[10] + m[4]*m[2]*m[9] + m[8]*m[1]*m[6] - m[8]*m[2]*m[5]
+
+	// Scaling every axis by s scales the determinant of the rotation and
+	// scale part by s cubed, so that's what it's compared against
+	scale := 0.0
+	for axis := 0; axis < 3; axis++ {
+		scale = math.Max(scale, math.Sqrt(m[axis*4]*m[axis*4]+m[axis*4+1]*m[axis*4+1]+m[axis*4+2]*m[axis*4+2]))
+	}
+
+	det := m[0]*inv[0] + m[1]*inv[4] + m[2]*inv[8] + m[3]*inv[12]
+	if det == 0 || math.Abs(m.determinant()) <= scale*scale*scale*1e-12 {
+		return IdentityMatrix4(), false
+	}
+
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
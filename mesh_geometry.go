@@ -0,0 +1,178 @@
+package main
+
+// layerElement holds the decoded values of a single layer element (normals,
+// UVs, etc) and knows how to look them up for any polygon vertex
+type layerElement struct {
+	mapping    string
+	reference  string
+	components int
+	data       []float64
+	index      []int32
+}
+
+func readLayerElement(geometry *Node, layerName, dataName, indexName string, components int) *layerElement {
+	layers := geometry.GetNodes(layerName)
+	if len(layers) == 0 {
+		return nil
+	}
+
+	layer := &layerElement{components: components}
+	for _, child := range layers[0].NestedNodes {
+		if child == nil {
+			continue
+		}
+		switch child.Name {
+		case "MappingInformationType":
+			layer.mapping, _ = child.StringProperty()
+		case "ReferenceInformationType":
+			layer.reference, _ = child.StringProperty()
+		case dataName:
+			if len(child.ArrayProperties) == 1 {
+				if child.ArrayProperties[0].TypeCode == 'f' {
+					for _, v := range child.ArrayProperties[0].AsFloat32Slice() {
+						layer.data = append(layer.data, float64(v))
+					}
+				} else {
+					layer.data = child.ArrayProperties[0].AsFloat64Slice()
+				}
+			}
+		case indexName:
+			layer.index, _ = child.Int32Slice()
+		}
+	}
+
+	if len(layer.data) < components {
+		return nil
+	}
+	return layer
+}
+
+// elementIndex finds which element of the layer's data applies to the polygon
+// vertex, returning -1 if there is none
+func (l *layerElement) elementIndex(polygonVertex, vertex, polygon int) int {
+	i := 0
+	switch l.mapping {
+	case "ByPolygonVertex":
+		i = polygonVertex
+	case "ByVertice", "ByVertex":
+		i = vertex
+	case "ByPolygon":
+		i = polygon
+	case "AllSame":
+		i = 0
+	default:
+		return -1
+	}
+
+	if l.reference != "Direct" {
+		if i >= len(l.index) {
+			return -1
+		}
+		i = int(l.index[i])
+	}
+
+	if i < 0 || (i+1)*l.components > len(l.data) {
+		return -1
+	}
+	return i
+}
+
+// value returns the data of the element at the index
+func (l *layerElement) value(element int) []float64 {
+	return l.data[element*l.components : (element+1)*l.components]
+}
+
+// meshGeometry is the decoded contents of a single mesh Geometry node
+type meshGeometry struct {
+	vertices           []float64
+	polygonVertexIndex []int32
+	normals            *layerElement
+	uvs                *layerElement
+	materialMapping    string
+	materials          []int32
+}
+
+// decodeMeshGeometry decodes everything needed to export a Geometry node,
+// returning false if the node isn't mesh geometry
+func decodeMeshGeometry(geometry *Node) (*meshGeometry, bool) {
+	vertexNodes := geometry.GetNodes("Vertices")
+	indexNodes := geometry.GetNodes("PolygonVertexIndex")
+	if len(vertexNodes) == 0 || len(indexNodes) == 0 {
+		return nil, false
+	}
+
+	mesh := &meshGeometry{}
+	mesh.vertices, _ = vertexNodes[0].Float64Slice()
+	mesh.polygonVertexIndex, _ = indexNodes[0].Int32Slice()
+	mesh.normals = readLayerElement(geometry, "LayerElementNormal", "Normals", "NormalsIndex", 3)
+	mesh.uvs = readLayerElement(geometry, "LayerElementUV", "UV", "UVIndex", 2)
+
+	if materialLayers := geometry.GetNodes("LayerElementMaterial"); len(materialLayers) > 0 {
+		for _, child := range materialLayers[0].NestedNodes {
+			if child == nil {
+				continue
+			}
+			switch child.Name {
+			case "MappingInformationType":
+				mesh.materialMapping, _ = child.StringProperty()
+			case "Materials":
+				mesh.materials, _ = child.Int32Slice()
+			}
+		}
+	}
+
+	return mesh, true
+}
+
+// vertexCount is how many control points the geometry has
+func (m meshGeometry) vertexCount() int {
+	return len(m.vertices) / 3
+}
+
+// vertex returns the index of the control point the polygon vertex uses
+func (m meshGeometry) vertex(polygonVertex int) int {
+	i := m.polygonVertexIndex[polygonVertex]
+	if i < 0 {
+		i = WrapToIndex(i)
+	}
+	return int(i)
+}
+
+// material returns the index of the model's material the polygon uses
+func (m meshGeometry) material(polygon int) int {
+	if len(m.materials) == 0 {
+		return 0
+	}
+	if m.materialMapping == "ByPolygon" && polygon < len(m.materials) {
+		return int(m.materials[polygon])
+	}
+	return int(m.materials[0])
+}
+
+// forEachPolygon calls the function with the range of polygon vertices that
+// make up each polygon. Polygons referencing control points that don't exist
+// are skipped.
+func (m meshGeometry) forEachPolygon(f func(polygon, start, end int)) {
+	polygon := 0
+	start := 0
+	vertexCount := m.vertexCount()
+	for i, index := range m.polygonVertexIndex {
+		if index >= 0 {
+			continue
+		}
+
+		valid := true
+		for pv := start; pv <= i; pv++ {
+			if m.vertex(pv) >= vertexCount {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			f(polygon, start, i+1)
+		}
+
+		polygon++
+		start = i + 1
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// PatchOutput is a single file written out by a MultiPatchWriter, which is
+// the original FBX with it's own sorted diffs applied
+type PatchOutput struct {
+	Writer io.Writer
+	Diffs  []Diff
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing the output with an OffsetError on the first one that's
+	// off
+	Verify bool
+}
+
+// patchCursor keeps up with how far along it's diffs and file an output is
+type patchCursor struct {
+	PatchOutput
+	nw        *nodeWriter
+	diffIndex int
+	offset    uint64
+	err       error
+}
+
+// next is the ID of the node the next diff applies to, skipping over any
+// diffs for nodes that come before the node ID given, which can happen when
+// a diff deletes a node that other diffs were going to change
+func (c *patchCursor) next(id uint64) (uint64, bool) {
+	for c.diffIndex < len(c.Diffs) && c.Diffs[c.diffIndex].NodeID() < id {
+		c.diffIndex++
+	}
+	if c.diffIndex == len(c.Diffs) {
+		return 0, false
+	}
+	return c.Diffs[c.diffIndex].NodeID(), true
+}
+
+func (c *patchCursor) write(b []byte) {
+	if c.err != nil {
+		return
+	}
+	n, err := c.nw.Write(b)
+	c.offset += uint64(n)
+	c.err = err
+}
+
+// MultiPatchWriter writes out many patched versions of the same FBX while
+// only going through it once. Every part of the FBX no diff touches is
+// encoded once and the same bytes are handed to every output that needs it,
+// with only their offsets adjusted to where they land in each file.
+type MultiPatchWriter struct {
+	fbx     *FBX
+	outputs []*patchCursor
+	legacy  bool
+	shared  *bytes.Buffer
+	encoder *nodeWriter
+	patched []byte
+	offsets []int
+}
+
+// NewMultiPatchWriter creates a writer for every output
+func NewMultiPatchWriter(fbx *FBX, outputs ...PatchOutput) *MultiPatchWriter {
+	legacy := fbx.Header.Version() < 7500
+	shared := new(bytes.Buffer)
+	encoder := newNodeWriter(shared, legacy)
+
+	cursors := make([]*patchCursor, len(outputs))
+	for i, o := range outputs {
+		cursors[i] = &patchCursor{PatchOutput: o, nw: newNodeWriter(o.Writer, legacy)}
+		cursors[i].nw.verify = o.Verify
+		encoder.verify = encoder.verify || o.Verify
+	}
+
+	return &MultiPatchWriter{
+		fbx:     fbx,
+		outputs: cursors,
+		legacy:  legacy,
+		shared:  shared,
+		encoder: encoder,
+	}
+}
+
+// Write writes out every output, returning the error each one ran into. An
+// output that errors is skipped from then on without stopping the others.
+func (mw *MultiPatchWriter) Write() []error {
+	for _, c := range mw.outputs {
+		c.write(mw.fbx.Header.data)
+	}
+
+	mw.writeNode(mw.fbx.Top, mw.outputs, nil)
+	for _, n := range mw.fbx.Nodes {
+		mw.writeNode(n, mw.outputs, nil)
+	}
+
+	errs := make([]error, len(mw.outputs))
+	for i, c := range mw.outputs {
+		if c.err == nil {
+			_, c.err = writeFooter(c.nw, c.offset, mw.fbx.Footer, mw.fbx.Header.Version())
+		}
+		if c.err == nil {
+			c.err = c.nw.Flush()
+		}
+		errs[i] = c.err
+	}
+	return errs
+}
+
+func (mw *MultiPatchWriter) writeWhole(n *Node, c *patchCursor) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = c.nw.writeNode(n, c.offset)
+	c.offset += c.nw.length(n)
+}
+
+// writeShared encodes the node once and writes it out to every output, with
+// it's offsets moved to where it lands in each file
+func (mw *MultiPatchWriter) writeShared(n *Node, outputs []*patchCursor) {
+	mw.shared.Reset()
+	_, err := mw.encoder.writeNode(n, 0)
+	if err == nil {
+		err = mw.encoder.Flush()
+	}
+	if err != nil {
+		// Outputs that aren't verifying still get the node, even if it's
+		// offsets are off
+		for _, c := range outputs {
+			if c.nw.verify {
+				c.err = err
+			} else {
+				mw.writeWhole(n, c)
+			}
+		}
+		return
+	}
+	encoded := mw.shared.Bytes()
+
+	mw.offsets = mw.offsets[:0]
+	mw.endOffsets(encoded, 0)
+
+	if cap(mw.patched) < len(encoded) {
+		mw.patched = make([]byte, len(encoded))
+	}
+	patched := mw.patched[:len(encoded)]
+
+	for _, c := range outputs {
+		copy(patched, encoded)
+		for _, at := range mw.offsets {
+			if mw.legacy {
+				binary.LittleEndian.PutUint32(patched[at:], binary.LittleEndian.Uint32(patched[at:])+uint32(c.offset))
+			} else {
+				binary.LittleEndian.PutUint64(patched[at:], binary.LittleEndian.Uint64(patched[at:])+c.offset)
+			}
+		}
+		c.write(patched)
+	}
+}
+
+// endOffsets finds where the end offset of every node encoded in the bytes
+// is, starting with the node at the position given, and returns where the
+// node ends. Empty nodes have no end offset to move.
+func (mw *MultiPatchWriter) endOffsets(encoded []byte, at int) int {
+	var end, propertyListLen int
+	var nameLen int
+	if mw.legacy {
+		end = int(binary.LittleEndian.Uint32(encoded[at:]))
+		propertyListLen = int(binary.LittleEndian.Uint32(encoded[at+8:]))
+		nameLen = int(encoded[at+12])
+	} else {
+		end = int(binary.LittleEndian.Uint64(encoded[at:]))
+		propertyListLen = int(binary.LittleEndian.Uint64(encoded[at+16:]))
+		nameLen = int(encoded[at+24])
+	}
+
+	if end == 0 {
+		return at + int(mw.encoder.headerSize())
+	}
+	mw.offsets = append(mw.offsets, at)
+
+	nested := at + int(mw.encoder.headerSize()) + nameLen + propertyListLen
+	for nested < end {
+		nested = mw.endOffsets(encoded, nested)
+	}
+	return end
+}
+
+// writeNode writes the node out to every output, sharing the encoding of the
+// node between every output without a diff for it or anything under it.
+// versions holds what each output's diffs turn the node into, and is nil for
+// top level nodes, which get each output's diffs applied to them once here.
+// Nested nodes then find their own version under their parent's instead of
+// applying the diffs all over again.
+func (mw *MultiPatchWriter) writeNode(n *Node, outputs []*patchCursor, versions []*Node) {
+	if n == nil {
+		return
+	}
+
+	untouched := make([]*patchCursor, 0, len(outputs))
+	nested := make([]*patchCursor, 0, len(outputs))
+	nestedVersions := make([]*Node, 0, len(outputs))
+	ends := make([]uint64, 0, len(outputs))
+	for i, c := range outputs {
+		if c.err != nil {
+			continue
+		}
+
+		id, ok := c.next(n.id)
+		if ok == false || id > n.endingID || n.Length == 0 {
+			untouched = append(untouched, c)
+			continue
+		}
+
+		var diffed *Node
+		if versions == nil {
+			diffed, _ = n.ApplyDiffs(c.Diffs, c.diffIndex)
+		} else {
+			diffed = versions[i]
+		}
+
+		switch {
+		case diffed == nil:
+			// Deleted by one of the diffs
+
+		case id == n.id || len(n.NestedNodes) == 0:
+			// The node itself changes, so it's written out on it's own
+			mw.writeWhole(diffed, c)
+
+		default:
+			// Only things under the node change, so the header is written
+			// with the length it ends up being and it's nested nodes are
+			// gone through together with everyone else's
+			end := c.offset + c.nw.length(diffed)
+			if c.err = c.nw.writeHeader(n, end); c.err != nil {
+				continue
+			}
+			c.offset += c.nw.headerSize() + uint64(n.NameLen) + n.PropertyListLen
+			nested = append(nested, c)
+			nestedVersions = append(nestedVersions, diffed)
+			ends = append(ends, end)
+		}
+	}
+
+	switch len(untouched) {
+	case 0:
+	case 1:
+		mw.writeWhole(n, untouched[0])
+	default:
+		mw.writeShared(n, untouched)
+	}
+
+	if len(nested) == 0 {
+		return
+	}
+
+	// Diffs only ever remove nested nodes from a node they don't target, so
+	// every version's nested nodes are in the same order as the original's
+	// and can be matched up by ID as we go
+	positions := make([]int, len(nested))
+	childVersions := make([]*Node, len(nested))
+	for _, child := range n.NestedNodes {
+		if child == nil {
+			continue
+		}
+		for i, version := range nestedVersions {
+			childVersions[i] = nil
+			for positions[i] < len(version.NestedNodes) {
+				candidate := version.NestedNodes[positions[i]]
+				if candidate != nil && candidate.id > child.id {
+					break
+				}
+				positions[i]++
+				if candidate != nil && candidate.id == child.id {
+					childVersions[i] = candidate
+					break
+				}
+			}
+		}
+		mw.writeNode(child, nested, childVersions)
+	}
+
+	for i, c := range nested {
+		switch {
+		case c.err != nil:
+			c.err = within(n.Name, c.err)
+		case c.nw.verify && c.offset != ends[i]:
+			c.err = &OffsetError{Path: n.Name, What: "ends", Expected: ends[i], Actual: c.offset}
+		}
+	}
+}
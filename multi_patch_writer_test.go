@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// splitGenerated splits a generated file by the plane the same way the
+// program does, returning the FBX and the diffs for each side
+func splitGenerated(t *testing.T, version uint32) (*FBX, []Diff, []Diff) {
+	config := DefaultGeneratorConfig()
+	config.TrianglesPerGeometry = 200
+	config.Version = version
+	file := new(bytes.Buffer)
+	assert.NoError(t, GenerateFBX(file, config))
+
+	fbx, err := ReadFrom(bytes.NewReader(file.Bytes()))
+	assert.NoError(t, err)
+
+	graph := NewConnectionGraph(fbx)
+	transforms := NewSceneTransforms(fbx, graph).GeometryTransforms()
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0, 0))
+
+	retained := make([]Diff, 0)
+	clipped := make([]Diff, 0)
+	for _, geometry := range fbx.GetNodes("Objects", "Geometry") {
+		local, ok := geometryPlane(geometry, plane, transforms)
+		assert.True(t, ok)
+		r, c := SplitByPlane(geometry, local)
+		retained = append(retained, r...)
+		clipped = append(clipped, c...)
+	}
+	retained = UpdateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, retained))
+	clipped = UpdateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, clipped))
+	return fbx, retained, clipped
+}
+
+func TestMultiPatchWriterMatchesPatchWriter(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		fbx, retained, clipped := splitGenerated(t, version)
+		diffs := [][]Diff{retained, clipped, nil, clipped}
+
+		expected := make([][]byte, len(diffs))
+		for i, d := range diffs {
+			buffer := new(bytes.Buffer)
+			pw := NewPatchWriter(fbx, d, func(int, error) {})
+			pw.Verify = true
+			_, err := pw.Write(buffer)
+			assert.NoError(t, err)
+			expected[i] = buffer.Bytes()
+		}
+
+		outputs := make([]PatchOutput, len(diffs))
+		buffers := make([]*bytes.Buffer, len(diffs))
+		for i, d := range diffs {
+			buffers[i] = new(bytes.Buffer)
+			outputs[i] = PatchOutput{Writer: buffers[i], Diffs: d, Verify: i%2 == 0}
+		}
+
+		// ******************************** ACT ***********************************
+		errs := NewMultiPatchWriter(fbx, outputs...).Write()
+
+		// ******************************* ASSERT *********************************
+		assert.Equal(t, make([]error, len(diffs)), errs)
+		for i := range diffs {
+			assert.Equal(t, expected[i], buffers[i].Bytes(), "version %d output %d", version, i)
+		}
+		assert.NotEqual(t, expected[0], expected[1])
+	}
+}
+
+type failingWriter struct {
+	left int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.left {
+		n := w.left
+		w.left = 0
+		return n, errors.New("out of space")
+	}
+	w.left -= len(b)
+	return len(b), nil
+}
+
+func TestWriteChunks(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx, retained, clipped := splitGenerated(t, 7500)
+
+	retainedFBX := new(bytes.Buffer)
+	retainedGLB := new(bytes.Buffer)
+	clippedFBX := new(bytes.Buffer)
+
+	expected := new(bytes.Buffer)
+	NewPatchWriter(fbx, retained, func(int, error) {}).Write(expected)
+
+	// ******************************** ACT ***********************************
+	errs := WriteChunks(fbx, []Chunk{
+		{Writer: MultiChunkWriter{NewFBXChunkWriter(retainedFBX), NewGLBChunkWriter(retainedGLB)}, Diffs: retained},
+		{Writer: MultiChunkWriter{NewFBXChunkWriter(&failingWriter{left: 1000}), NewFBXChunkWriter(clippedFBX)}, Diffs: clipped},
+		{Writer: nil, Diffs: clipped},
+	})
+
+	// ******************************* ASSERT *********************************
+	if assert.Len(t, errs, 3) {
+		assert.NoError(t, errs[0])
+		assert.EqualError(t, errs[1], "out of space")
+		assert.NoError(t, errs[2])
+	}
+	assert.Equal(t, expected.Bytes(), retainedFBX.Bytes())
+	assert.True(t, retainedGLB.Len() > 0)
+
+	// One output failing doesn't stop the others
+	_, err := ReadFrom(bytes.NewReader(clippedFBX.Bytes()))
+	assert.NoError(t, err)
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -18,6 +18,11 @@ type Node struct {
 	Length          uint64
 	id              uint64
 	endingID        uint64 // ID of the last descendent node
+
+	// arrayOrder is whether each property, in the order they were read, is
+	// an array property. Nodes without one have all of their array
+	// properties come before the rest.
+	arrayOrder []bool
 }
 
 // NewNode creates a new node and calculates some properties required to write to file
@@ -32,13 +37,7 @@ func NewNode(name string, properties []*Property, arrayProperties []*ArrayProper
 		propertyLength += p.Size()
 	}
 
-	var nestedLength uint64
-	for _, n := range nestedNodes {
-		if n == nil {
-			continue
-		}
-		nestedLength += n.Length
-	}
+	nestedLength := nestedNodesLength(nestedNodes)
 
 	return &Node{
 		NumProperties:   uint64(len(properties) + len(arrayProperties)),
@@ -52,6 +51,42 @@ func NewNode(name string, properties []*Property, arrayProperties []*ArrayProper
 	}
 }
 
+// nestedNodesLength is how many bytes the nodes take up once written
+func nestedNodesLength(nodes []*Node) uint64 {
+	var length uint64
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		// Length of 0 denotes empty node, but it still takes up space when
+		// we write it to disk, empty nodes take up 25 bytes
+		if n.Length == 0 {
+			length += 25
+		} else {
+			length += n.Length
+		}
+	}
+	return length
+}
+
+// addNullRecords ends every list of nested nodes under the node with the
+// empty node FBX readers expect to find there, the same way nodes read in
+// from a file already are. Lengths are updated to match.
+func addNullRecords(n *Node) {
+	if len(n.NestedNodes) == 0 {
+		return
+	}
+	for _, child := range n.NestedNodes {
+		if child != nil {
+			addNullRecords(child)
+		}
+	}
+	if last := n.NestedNodes[len(n.NestedNodes)-1]; last == nil || last.Length != 0 {
+		n.NestedNodes = append(n.NestedNodes, &Node{})
+	}
+	n.Length = nestedNodesLength(n.NestedNodes) + n.PropertyListLen + uint64(len(n.Name)) + 25
+}
+
 // NewNodeSingleProperty creates a new node that only has one property
 func NewNodeSingleProperty(name string, property *Property) *Node {
 	return NewNode(name, []*Property{property}, nil, nil)
@@ -89,6 +124,22 @@ func NewNodeParent(name string, children ...*Node) *Node {
 	return NewNode(name, nil, nil, children)
 }
 
+// number assigns ids to the node and all of it's descendents in the same
+// depth first order the reader would have, starting at id, and returns the
+// next free id. Diffs can only be made against nodes that have been numbered.
+func (n *Node) number(id uint64) uint64 {
+	n.id = id
+	next := id + 1
+	for _, child := range n.NestedNodes {
+		if child == nil {
+			continue
+		}
+		next = child.number(next)
+	}
+	n.endingID = next - 1
+	return next
+}
+
 // ShallowCopy returns a new node and shallow copies of any array type
 // contained within the struct
 func (n Node) ShallowCopy() *Node {
@@ -112,9 +163,65 @@ func (n Node) ShallowCopy() *Node {
 		Length:          n.Length,
 		id:              n.id,
 		endingID:        n.endingID,
+		arrayOrder:      n.arrayOrder,
 	}
 }
 
+// eachProperty goes through every property of the node in the order they
+// appear in the file, stopping at the first error. Exactly one of the
+// properties handed over is ever set.
+func (n *Node) eachProperty(fn func(*Property, *ArrayProperty) error) error {
+	arrays, properties := n.ArrayProperties, n.Properties
+	for _, isArray := range n.arrayOrder {
+		var err error
+		switch {
+		case isArray && len(arrays) > 0:
+			err = fn(nil, arrays[0])
+			arrays = arrays[1:]
+		case isArray == false && len(properties) > 0:
+			err = fn(properties[0], nil)
+			properties = properties[1:]
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Anything the order doesn't account for goes arrays first
+	for _, p := range arrays {
+		if err := fn(nil, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range properties {
+		if err := fn(p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errFoundProperty stops eachProperty once the property being looked for is
+// found
+var errFoundProperty = errors.New("found property")
+
+// PropertyAt is the property at the index given, counting both array and
+// regular properties in the order they appear in the file. Only one of the
+// two is returned, and neither if there's no property at the index.
+func (n *Node) PropertyAt(i int) (*Property, *ArrayProperty) {
+	var property *Property
+	var array *ArrayProperty
+	n.eachProperty(func(p *Property, a *ArrayProperty) error {
+		if i > 0 {
+			i--
+			return nil
+		}
+		property, array = p, a
+		return errFoundProperty
+	})
+	return property, array
+}
+
 func (n *Node) ApplyDiffs(allDiffs []Diff, curDifIndex int) (*Node, int) {
 
 	if n.Length == 0 {
@@ -146,8 +253,22 @@ func (n *Node) ApplyDiffs(allDiffs []Diff, curDifIndex int) (*Node, int) {
 		}
 	}
 
+	// Nodes are shared between everyone writing out the FBX, so a node is
+	// only ever copied and modified when one of it's descendents changed
 	for i, nested := range diffedNode.NestedNodes {
-		diffedNode.NestedNodes[i], newDifIndex = nested.ApplyDiffs(allDiffs, newDifIndex)
+		var patched *Node
+		patched, newDifIndex = nested.ApplyDiffs(allDiffs, newDifIndex)
+		if patched == nested {
+			continue
+		}
+		if diffedNode == n {
+			diffedNode = n.ShallowCopy()
+		}
+		diffedNode.NestedNodes[i] = patched
+	}
+
+	if diffedNode == n {
+		return n, newDifIndex
 	}
 
 	var propertyLength uint64
@@ -160,19 +281,7 @@ func (n *Node) ApplyDiffs(allDiffs []Diff, curDifIndex int) (*Node, int) {
 	}
 	diffedNode.PropertyListLen = propertyLength
 
-	var nestedLength uint64
-	for _, n := range diffedNode.NestedNodes {
-		if n == nil {
-			continue
-		}
-		// Length of 0 denotes empty node, but it still takes up space when
-		// we write it to disk, empty nodes take up 25 bytes
-		if n.Length == 0 {
-			nestedLength += 25
-		} else {
-			nestedLength += n.Length
-		}
-	}
+	nestedLength := nestedNodesLength(diffedNode.NestedNodes)
 
 	diffedNode.Length = nestedLength + propertyLength + uint64(len(diffedNode.Name)) + 25
 	diffedNode.NameLen = uint8(len(diffedNode.Name))
@@ -181,59 +290,30 @@ func (n *Node) ApplyDiffs(allDiffs []Diff, curDifIndex int) (*Node, int) {
 	return diffedNode, newDifIndex
 }
 
-func (node Node) Write(writer io.Writer, currentOffset uint64, endOfList bool) (uint64, error) {
-	offset := node.Length
-	if offset != 0 {
-		offset += currentOffset
-	}
-	err := binary.Write(writer, binary.LittleEndian, uint64(offset))
-	if err != nil {
-		return 0, err
-	}
-
-	err = binary.Write(writer, binary.LittleEndian, node.NumProperties)
+// Write writes the node and everything under it to the writer, starting at
+// the offset given, and returns the offset the node ends at
+func (node Node) Write(writer io.Writer, currentOffset uint64) (uint64, error) {
+	nw := newNodeWriter(writer, false)
+	end, err := nw.writeNode(&node, currentOffset)
 	if err != nil {
 		return 0, err
 	}
+	return end, nw.Flush()
+}
 
-	err = binary.Write(writer, binary.LittleEndian, node.PropertyListLen)
-	if err != nil {
-		return 0, err
-	}
-
-	err = binary.Write(writer, binary.LittleEndian, node.NameLen)
-	if err != nil {
-		return 0, err
-	}
-
-	_, err = writer.Write([]byte(node.Name))
-	if err != nil {
-		return 0, err
-	}
-
-	for _, p := range node.ArrayProperties {
-		err := p.Write(writer)
-		if err != nil {
-			return 0, nil
-		}
-	}
-
-	for _, p := range node.Properties {
-		err := p.Write(writer)
-		if err != nil {
-			return 0, nil
-		}
+// legacyLength is how many bytes the node takes up when written with the 13
+// byte headers files before version 7500 use
+func (node Node) legacyLength() uint64 {
+	if node.Length == 0 {
+		return 13
 	}
-
-	offsetSofar := currentOffset + 25 + uint64(node.NameLen) + node.PropertyListLen
-	for i, p := range node.NestedNodes {
-		offsetSofar, err = p.Write(writer, offsetSofar, len(node.NestedNodes)-1 == i)
-		if err != nil {
-			return 0, nil
+	length := 13 + uint64(len(node.Name)) + node.PropertyListLen
+	for _, n := range node.NestedNodes {
+		if n != nil {
+			length += n.legacyLength()
 		}
 	}
-
-	return uint64(node.Length + currentOffset), err
+	return length
 }
 
 // PropertyInfo looks at all properties contained within the node and computes
@@ -276,7 +356,7 @@ func (n Node) GetNodes(names ...string) []*Node {
 	nodes := []*Node{}
 
 	for _, c := range n.NestedNodes {
-		if c.Name == names[0] {
+		if c != nil && c.Name == names[0] {
 			nodes = append(nodes, c.GetNodes(names[1:]...)...)
 		}
 	}
@@ -26,7 +26,7 @@ func TestSaveAndLoadNodeWithSliceProperty(t *testing.T) {
 	node := NewNodeFloat64Slice("Float64 Test", data)
 
 	// ******************************** ACT ***********************************
-	_, writeErr := node.Write(buffer, 0, false)
+	_, writeErr := node.Write(buffer, 0)
 	nodeFromBuffer, _ := reader.ReadNodeFrom(bytes.NewReader(buffer.Bytes()))
 
 	// ******************************* ASSERT *********************************
@@ -117,3 +117,215 @@ func TestSaveAndLoadNestedNodeStructuresWithSliceProperty(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeWriteReturnsWriteErrors(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	node := NewNodeParent(
+		"Geometry",
+		NewNodeFloat64Slice("Vertices", []float64{1, 2, 3}),
+		NewNodeString("Name", "Cube"),
+	)
+	addNullRecords(node)
+
+	// ******************************** ACT ***********************************
+	_, writeErr := node.Write(&failingWriter{left: 40}, 0)
+	legacy := newNodeWriter(&failingWriter{left: 40}, true)
+	_, legacyErr := legacy.writeNode(node, 0)
+	if legacyErr == nil {
+		legacyErr = legacy.Flush()
+	}
+	_, okErr := node.Write(&failingWriter{left: int(node.Length)}, 0)
+
+	// ******************************* ASSERT *********************************
+	assert.EqualError(t, writeErr, "out of space")
+	assert.EqualError(t, legacyErr, "out of space")
+	assert.NoError(t, okErr)
+}
+
+func TestVerifyingWriterCatchesMiscountedNodes(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	node := NewNodeParent(
+		"Objects",
+		NewNodeParent(
+			"Geometry",
+			NewNodeFloat64Slice("Vertices", []float64{1, 2, 3}),
+			NewNodeString("Name", "Cube"),
+		),
+	)
+	addNullRecords(node)
+	node.NestedNodes[0].NestedNodes[1].Length++
+
+	properties := NewNodeString("Name", "Cube")
+	properties.PropertyListLen--
+
+	// ******************************** ACT ***********************************
+	_, uncheckedErr := node.Write(new(bytes.Buffer), 0)
+
+	checked := newNodeWriter(new(bytes.Buffer), false)
+	checked.verify = true
+	_, checkedErr := checked.writeNode(node, 0)
+
+	legacy := newNodeWriter(new(bytes.Buffer), true)
+	legacy.verify = true
+	_, propertiesErr := legacy.writeNode(properties, 100)
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, uncheckedErr)
+	if assert.IsType(t, &OffsetError{}, checkedErr) {
+		offsetErr := checkedErr.(*OffsetError)
+		assert.Equal(t, "Objects/Geometry/Name", offsetErr.Path)
+		assert.Equal(t, offsetErr.Actual+1, offsetErr.Expected)
+	}
+	assert.EqualError(t, propertiesErr, "node Name: properties end at 25 but writing ended up at 26")
+}
+
+func TestEmptyNodesMoveTheOffsetAlong(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	buffer := new(bytes.Buffer)
+	writer, err := NewWriterWithOptions(buffer, WriterOptions{Version: 7500, Verify: true})
+	assert.NoError(t, err)
+	start := writer.currentOffset
+
+	// ******************************** ACT ***********************************
+	empty := writer.WriteNode(&Node{})
+	after := writer.currentOffset
+	full := writer.WriteNode(NewNodeString("Creator", "test"))
+	completeErr := writer.Complete()
+
+	// ******************************* ASSERT *********************************
+	assert.True(t, empty)
+	assert.True(t, full)
+	assert.NoError(t, completeErr)
+	assert.Equal(t, start+25, after)
+	assert.Equal(t, writer.currentOffset, uint64(buffer.Len()))
+}
+
+func TestStartedNodesMatchNodesWrittenWhole(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		children := []*Node{
+			NewNodeString("Creator", "test"),
+			NewNodeParent("Nested", NewNodeInt32("Version", 100)),
+		}
+		whole := NewNodeParent("Objects", children...)
+		addNullRecords(whole)
+
+		options := WriterOptions{Version: version, Verify: true}
+		expected := new(bytes.Buffer)
+		expectedWriter, err := NewWriterWithOptions(expected, options)
+		assert.NoError(t, err)
+		expectedWriter.WriteNode(whole)
+		assert.NoError(t, expectedWriter.Complete())
+
+		buffer := new(bytes.Buffer)
+		writer, err := NewWriterWithOptions(buffer, options)
+		assert.NoError(t, err)
+
+		// ******************************** ACT ***********************************
+		nestedLength := uint64(0)
+		for _, child := range children {
+			nestedLength += writer.Length(child)
+		}
+		started := writer.StartNode("Objects", nestedLength)
+		for _, child := range children {
+			writer.WriteNode(child)
+		}
+		ended := writer.EndNode()
+		completeErr := writer.Complete()
+
+		// ******************************* ASSERT *********************************
+		assert.True(t, started)
+		assert.True(t, ended)
+		assert.NoError(t, completeErr)
+		assert.Equal(t, expected.Bytes(), buffer.Bytes(), "version %d", version)
+	}
+}
+
+func TestStartedNodesCatchMiscountedLengths(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	writer, err := NewWriterWithOptions(new(bytes.Buffer), WriterOptions{Version: 7500})
+	assert.NoError(t, err)
+	child := NewNodeString("Creator", "test")
+
+	// ******************************** ACT ***********************************
+	writer.StartNode("Objects", writer.Length(child)+1)
+	writer.WriteNode(child)
+	ended := writer.EndNode()
+	completeErr := writer.Complete()
+
+	// ******************************* ASSERT *********************************
+	assert.False(t, ended)
+	if assert.IsType(t, &OffsetError{}, completeErr) {
+		offsetErr := completeErr.(*OffsetError)
+		assert.Equal(t, "Objects", offsetErr.Path)
+		assert.Equal(t, offsetErr.Expected, offsetErr.Actual+1)
+	}
+}
+
+func TestCompletingWithAStartedNodeFails(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	writer, err := NewWriterWithOptions(new(bytes.Buffer), WriterOptions{Version: 7500})
+	assert.NoError(t, err)
+
+	// ******************************** ACT ***********************************
+	writer.StartNode("Objects", 0)
+	completeErr := writer.Complete()
+
+	// ******************************* ASSERT *********************************
+	assert.EqualError(t, completeErr, "node Objects was started but never ended")
+}
+
+// readNode reads a single node written with 25 byte headers
+func readNode(t *testing.T, b []byte) *Node {
+	reader := NewReader()
+	reader.nodeHeader = make([]byte, 25)
+	reader.FBX.Header = &Header{version: 7500}
+	node, _ := reader.ReadNodeFrom(bytes.NewReader(b))
+	assert.NoError(t, reader.Error)
+	return node
+}
+
+func TestInterleavedPropertiesKeepTheirOrder(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	node := NewNode(
+		"Shape",
+		[]*Property{NewPropertyString("Shape::Smile"), NewPropertyInt32(3)},
+		[]*ArrayProperty{NewArrayPropertyInt32Slice([]int32{1, 2}), NewArrayPropertyFloat64Slice([]float64{0.5})},
+		nil,
+	)
+	node.arrayOrder = []bool{false, true, false, true}
+
+	written := new(bytes.Buffer)
+	_, err := node.Write(written, 0)
+	assert.NoError(t, err)
+
+	// ******************************** ACT ***********************************
+	read := readNode(t, written.Bytes())
+	rewritten := new(bytes.Buffer)
+	_, rewriteErr := read.Write(rewritten, 0)
+
+	diffed, _ := NewArrayPropertyDiff(read.id, NewArrayPropertyFloat64Slice([]float64{0.25})).Apply(read)
+	diffedWritten := new(bytes.Buffer)
+	_, diffedErr := diffed.Write(diffedWritten, 0)
+	diffedRead := readNode(t, diffedWritten.Bytes())
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, rewriteErr)
+	assert.NoError(t, diffedErr)
+	assert.Equal(t, written.Bytes(), rewritten.Bytes())
+	assert.Equal(t, []bool{false, true, false, true}, read.arrayOrder)
+
+	first, _ := read.PropertyAt(0)
+	_, second := read.PropertyAt(1)
+	third, _ := read.PropertyAt(2)
+	_, fourth := diffedRead.PropertyAt(3)
+	none, noArray := read.PropertyAt(4)
+	if assert.NotNil(t, first) && assert.NotNil(t, second) && assert.NotNil(t, third) && assert.NotNil(t, fourth) {
+		assert.Equal(t, "Shape::Smile", first.AsString())
+		assert.Equal(t, []int32{1, 2}, second.AsInt32Slice())
+		assert.Equal(t, byte('I'), third.TypeCode)
+		assert.Equal(t, []float64{0.25}, fourth.AsFloat64Slice())
+	}
+	assert.Nil(t, none)
+	assert.Nil(t, noArray)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// nodeWriterBufferSize is how much gets buffered before being written out to
+// the underlying writer
+const nodeWriterBufferSize = 64 * 1024
+
+// OffsetError is what a verifying writer returns when the offsets it worked
+// out for a node don't match up with how many bytes it actually wrote
+type OffsetError struct {
+	// Path of node names from the outermost node being written down to the
+	// one that was miscounted, separated by slashes
+	Path string
+
+	// What is the part of the node that was miscounted
+	What string
+
+	// Expected is where the node said it would be, and Actual where writing
+	// actually ended up. Where properties end is counted from the start of
+	// the node.
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("node %s: %s at %d but writing ended up at %d", e.Path, e.What, e.Expected, e.Actual)
+}
+
+// within adds the name of the node the miscounted node is nested in to the
+// front of the error's path, leaving any other error as is
+func within(name string, err error) error {
+	if offsetErr, ok := err.(*OffsetError); ok {
+		offsetErr.Path = name + "/" + offsetErr.Path
+	}
+	return err
+}
+
+// nodeWriter serializes nodes through a buffered writer, encoding every node
+// header and property header into the same small buffer instead of writing
+// them out a field at a time. Nothing is guaranteed to reach the underlying
+// writer until Flush is called. When verifying, every node's end offset and
+// property list length is checked against the bytes actually written for it.
+type nodeWriter struct {
+	w       *bufio.Writer
+	legacy  bool
+	verify  bool
+	written uint64
+	header  [25]byte
+}
+
+// newNodeWriter creates a writer for nodes of a file laid out with 13 byte
+// headers when legacy is true, or 25 byte headers otherwise. Everything goes
+// through it's own buffer, so the writer given doesn't need to be buffered.
+func newNodeWriter(w io.Writer, legacy bool) *nodeWriter {
+	return &nodeWriter{
+		w:      bufio.NewWriterSize(w, nodeWriterBufferSize),
+		legacy: legacy,
+	}
+}
+
+// Flush writes out anything still buffered
+func (nw *nodeWriter) Flush() error {
+	return nw.w.Flush()
+}
+
+// Write writes the bytes out as is
+func (nw *nodeWriter) Write(b []byte) (int, error) {
+	n, err := nw.w.Write(b)
+	nw.written += uint64(n)
+	return n, err
+}
+
+func (nw *nodeWriter) write(b []byte) error {
+	_, err := nw.Write(b)
+	return err
+}
+
+func (nw *nodeWriter) headerSize() uint64 {
+	if nw.legacy {
+		return 13
+	}
+	return 25
+}
+
+// length is how many bytes the node takes up once written
+func (nw *nodeWriter) length(n *Node) uint64 {
+	if n.Length == 0 {
+		return nw.headerSize()
+	}
+	if nw.legacy {
+		return n.legacyLength()
+	}
+	return n.Length
+}
+
+func (nw *nodeWriter) writeProperty(p *Property) error {
+	header := nw.header[:1]
+	header[0] = p.TypeCode
+	if p.TypeCode == 'S' || p.TypeCode == 'R' {
+		header = nw.header[:5]
+		binary.LittleEndian.PutUint32(header[1:], uint32(len(p.Data)))
+	}
+	if err := nw.write(header); err != nil {
+		return err
+	}
+	return nw.write(p.Data)
+}
+
+func (nw *nodeWriter) writeArrayProperty(p *ArrayProperty) error {
+	header := nw.header[:13]
+	header[0] = p.TypeCode
+	binary.LittleEndian.PutUint32(header[1:], p.ArrayLength)
+	binary.LittleEndian.PutUint32(header[5:], p.Encoding)
+	binary.LittleEndian.PutUint32(header[9:], p.CompressedLength)
+	if err := nw.write(header); err != nil {
+		return err
+	}
+	return nw.write(p.Data)
+}
+
+// writeHeader writes out everything about the node that comes before it's
+// nested nodes, with the node ending at the offset given
+func (nw *nodeWriter) writeHeader(n *Node, endOffset uint64) error {
+	start := nw.written
+	var header []byte
+	if nw.legacy {
+		header = nw.header[:13]
+		binary.LittleEndian.PutUint32(header, uint32(endOffset))
+		binary.LittleEndian.PutUint32(header[4:], uint32(n.NumProperties))
+		binary.LittleEndian.PutUint32(header[8:], uint32(n.PropertyListLen))
+		header[12] = n.NameLen
+	} else {
+		header = nw.header[:25]
+		binary.LittleEndian.PutUint64(header, endOffset)
+		binary.LittleEndian.PutUint64(header[8:], n.NumProperties)
+		binary.LittleEndian.PutUint64(header[16:], n.PropertyListLen)
+		header[24] = n.NameLen
+	}
+	if err := nw.write(header); err != nil {
+		return err
+	}
+
+	written, err := nw.w.WriteString(n.Name)
+	nw.written += uint64(written)
+	if err != nil {
+		return err
+	}
+
+	err = n.eachProperty(func(p *Property, a *ArrayProperty) error {
+		if a != nil {
+			return nw.writeArrayProperty(a)
+		}
+		return nw.writeProperty(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	expected := nw.headerSize() + uint64(n.NameLen) + n.PropertyListLen
+	if nw.verify && nw.written-start != expected {
+		return &OffsetError{
+			Path:     n.Name,
+			What:     "properties end",
+			Expected: expected,
+			Actual:   nw.written - start,
+		}
+	}
+	return nil
+}
+
+// writeNode writes the node and everything under it starting at the offset
+// given, returning the offset the node ends at
+func (nw *nodeWriter) writeNode(n *Node, currentOffset uint64) (uint64, error) {
+	if n.Length == 0 {
+		if err := nw.writeHeader(n, 0); err != nil {
+			return 0, err
+		}
+		return currentOffset + nw.headerSize(), nil
+	}
+
+	start := nw.written
+	end := currentOffset + nw.length(n)
+	if err := nw.writeHeader(n, end); err != nil {
+		return 0, err
+	}
+
+	offsetSofar := currentOffset + nw.headerSize() + uint64(n.NameLen) + n.PropertyListLen
+	for _, nested := range n.NestedNodes {
+		if nested == nil {
+			continue
+		}
+		var err error
+		offsetSofar, err = nw.writeNode(nested, offsetSofar)
+		if err != nil {
+			return 0, within(n.Name, err)
+		}
+	}
+
+	if nw.verify && currentOffset+nw.written-start != end {
+		return 0, &OffsetError{
+			Path:     n.Name,
+			What:     "ends",
+			Expected: end,
+			Actual:   currentOffset + nw.written - start,
+		}
+	}
+	return end, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OBJChunkWriter writes chunks out as Wavefront OBJ, with the materials going
+// to a separate MTL file. Geometry is decoded and written one Geometry node
+// at a time, so the whole chunk never has to exist as a mesh in memory.
+type OBJChunkWriter struct {
+	obj     io.Writer
+	mtl     io.Writer
+	mtlName string
+}
+
+// NewOBJChunkWriter creates a chunk writer that outputs OBJ. mtlName is what
+// the OBJ's mtllib statement references, and materials are only written when
+// mtl isn't nil.
+func NewOBJChunkWriter(obj io.Writer, mtl io.Writer, mtlName string) *OBJChunkWriter {
+	return &OBJChunkWriter{obj: obj, mtl: mtl, mtlName: mtlName}
+}
+
+// objName makes a name safe to use in OBJ and MTL statements, which end at
+// the first whitespace
+func objName(name string) string {
+	name = strings.Join(strings.Fields(name), "_")
+	if name == "" {
+		return "unnamed"
+	}
+	return name
+}
+
+// objWriter keeps track of where we are in the OBJ file, since indices are
+// shared across all groups, and reuses a single buffer for formatting lines
+type objWriter struct {
+	w         *bufio.Writer
+	line      []byte
+	vertices  int
+	uvs       int
+	normals   int
+	materials map[int64]string
+	used      map[string]bool
+	order     []*sceneMaterial
+}
+
+func (ow *objWriter) writeValues(prefix string, values ...float64) {
+	ow.line = append(ow.line[:0], prefix...)
+	for _, v := range values {
+		ow.line = append(ow.line, ' ')
+		ow.line = strconv.AppendFloat(ow.line, v, 'g', -1, 64)
+	}
+	ow.line = append(ow.line, '\n')
+	ow.w.Write(ow.line)
+}
+
+func (ow *objWriter) materialName(m *sceneMaterial) string {
+	if name, ok := ow.materials[m.uid]; ok {
+		return name
+	}
+
+	name := objName(m.name)
+	if ow.used[name] {
+		name = fmt.Sprintf("%s_%d", name, m.uid)
+	}
+	ow.used[name] = true
+	ow.materials[m.uid] = name
+	ow.order = append(ow.order, m)
+	return name
+}
+
+// normalMatrix is the matrix that keeps normals perpendicular to surfaces
+// after being transformed by the world matrix
+func normalMatrix(world Matrix4) Matrix4 {
+	inverse, ok := world.Inverse()
+	if ok == false {
+		return world
+	}
+	return inverse.Transpose()
+}
+
+// writeMesh writes the mesh with it's world transform baked into it's
+// vertices, along with the transform taking the file's units and axes to
+// meters with Y up
+func (ow *objWriter) writeMesh(mesh sceneMesh, toCanonical Matrix4) {
+	geometry, ok := decodeMeshGeometry(mesh.node)
+	if ok == false {
+		return
+	}
+
+	ow.writeValues("g " + objName(mesh.name))
+
+	m := toCanonical.Multiply(mesh.world)
+
+	// A mirrored transform turns counter clockwise faces clockwise, so the
+	// corners are written out backwards to keep them facing the right way
+	mirrored := m.determinant() < 0
+	for i := 0; i+2 < len(geometry.vertices); i += 3 {
+		x, y, z := geometry.vertices[i], geometry.vertices[i+1], geometry.vertices[i+2]
+		ow.writeValues(
+			"v",
+			m[0]*x+m[1]*y+m[2]*z+m[3],
+			m[4]*x+m[5]*y+m[6]*z+m[7],
+			m[8]*x+m[9]*y+m[10]*z+m[11],
+		)
+	}
+
+	uvCount := 0
+	if geometry.uvs != nil {
+		uvCount = len(geometry.uvs.data) / 2
+		for i := 0; i < uvCount; i++ {
+			uv := geometry.uvs.value(i)
+			ow.writeValues("vt", uv[0], uv[1])
+		}
+	}
+
+	normalCount := 0
+	if geometry.normals != nil {
+		n := normalMatrix(m)
+		normalCount = len(geometry.normals.data) / 3
+		for i := 0; i < normalCount; i++ {
+			v := geometry.normals.value(i)
+			x := n[0]*v[0] + n[1]*v[1] + n[2]*v[2]
+			y := n[4]*v[0] + n[5]*v[1] + n[6]*v[2]
+			z := n[8]*v[0] + n[9]*v[1] + n[10]*v[2]
+			if length := math.Sqrt(x*x + y*y + z*z); length > 0 {
+				x, y, z = x/length, y/length, z/length
+			}
+			ow.writeValues("vn", x, y, z)
+		}
+	}
+
+	currentMaterial := ""
+	geometry.forEachPolygon(func(polygon, start, end int) {
+		if end-start < 3 {
+			return
+		}
+
+		if slot := geometry.material(polygon); slot >= 0 && slot < len(mesh.materials) {
+			if name := ow.materialName(mesh.materials[slot]); name != currentMaterial {
+				currentMaterial = name
+				ow.writeValues("usemtl " + name)
+			}
+		}
+
+		// OBJ wants every corner of a face to have the same attributes, so
+		// an attribute is dropped for the whole face if any corner lacks it
+		withUV := geometry.uvs != nil
+		withNormal := geometry.normals != nil
+		for pv := start; pv < end; pv++ {
+			v := geometry.vertex(pv)
+			if withUV && geometry.uvs.elementIndex(pv, v, polygon) < 0 {
+				withUV = false
+			}
+			if withNormal && geometry.normals.elementIndex(pv, v, polygon) < 0 {
+				withNormal = false
+			}
+		}
+
+		ow.line = append(ow.line[:0], 'f')
+		for corner := start; corner < end; corner++ {
+			pv := corner
+			if mirrored {
+				pv = end - 1 - (corner - start)
+			}
+			v := geometry.vertex(pv)
+			ow.line = append(ow.line, ' ')
+			ow.line = strconv.AppendInt(ow.line, int64(ow.vertices+v+1), 10)
+			if withUV == false && withNormal == false {
+				continue
+			}
+			ow.line = append(ow.line, '/')
+			if withUV {
+				ow.line = strconv.AppendInt(ow.line, int64(ow.uvs+geometry.uvs.elementIndex(pv, v, polygon)+1), 10)
+			}
+			if withNormal {
+				ow.line = append(ow.line, '/')
+				ow.line = strconv.AppendInt(ow.line, int64(ow.normals+geometry.normals.elementIndex(pv, v, polygon)+1), 10)
+			}
+		}
+		ow.line = append(ow.line, '\n')
+		ow.w.Write(ow.line)
+	})
+
+	ow.vertices += geometry.vertexCount()
+	ow.uvs += uvCount
+	ow.normals += normalCount
+}
+
+func writeMTL(w io.Writer, materials []*sceneMaterial, names map[int64]string) error {
+	out := bufio.NewWriter(w)
+	for _, m := range materials {
+		fmt.Fprintf(out, "newmtl %s\n", names[m.uid])
+		fmt.Fprintf(out, "Kd %g %g %g\n", m.diffuse[0], m.diffuse[1], m.diffuse[2])
+		fmt.Fprintf(out, "d %g\n", m.opacity)
+		if m.diffuseMap != "" {
+			fmt.Fprintf(out, "map_Kd %s\n", m.diffuseMap)
+		}
+		if m.normalMap != "" {
+			fmt.Fprintf(out, "norm %s\n", m.normalMap)
+		}
+		fmt.Fprintln(out)
+	}
+	return out.Flush()
+}
+
+// WriteChunk converts the patched FBX to OBJ, with a group for every piece of
+// mesh geometry, transformed into world space. OBJ has no way of saying what
+// units or axes it's in, so it's converted into meters with Y up the same as
+// GLB is, which is what most tools assume.
+func (cw OBJChunkWriter) WriteChunk(fbx *FBX, diffs []Diff) error {
+	ow := &objWriter{
+		w:         bufio.NewWriter(cw.obj),
+		materials: make(map[int64]string),
+		used:      make(map[string]bool),
+		order:     make([]*sceneMaterial, 0),
+	}
+
+	if cw.mtl != nil && cw.mtlName != "" {
+		ow.writeValues("mtllib " + cw.mtlName)
+	}
+
+	patched := fbx.ApplyDiffs(diffs)
+	toCanonical := NewAxisSystem(patched).FileToCanonical()
+	for _, mesh := range sceneMeshes(patched) {
+		ow.writeMesh(mesh, toCanonical)
+	}
+
+	if err := ow.w.Flush(); err != nil {
+		return err
+	}
+
+	if cw.mtl == nil {
+		return nil
+	}
+	return writeMTL(cw.mtl, ow.order, ow.materials)
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// objCorner is a single corner of an OBJ face, as indices into the file's
+// positions, texture coordinates and normals, with -1 meaning it's missing
+type objCorner struct {
+	v, vt, vn int32
+}
+
+// objIndexRemap keeps track of which of the file's values a piece of
+// geometry uses, giving each a new index local to the geometry
+type objIndexRemap struct {
+	local map[int32]int32
+	used  []int32
+}
+
+func newOBJIndexRemap() objIndexRemap {
+	return objIndexRemap{local: make(map[int32]int32), used: make([]int32, 0)}
+}
+
+func (r *objIndexRemap) index(global int32) int32 {
+	if global < 0 {
+		return -1
+	}
+	local, ok := r.local[global]
+	if ok == false {
+		local = int32(len(r.used))
+		r.local[global] = local
+		r.used = append(r.used, global)
+	}
+	return local
+}
+
+func (r *objIndexRemap) gather(values []float64, components int) []float64 {
+	gathered := make([]float64, 0, len(r.used)*components)
+	for _, index := range r.used {
+		gathered = append(gathered, values[int(index)*components:(int(index)+1)*components]...)
+	}
+	return gathered
+}
+
+// objGeometryBuilder collects faces until there's enough of them to make a
+// piece of geometry, or the group changes
+type objGeometryBuilder struct {
+	name               string
+	vertices           objIndexRemap
+	uvs                objIndexRemap
+	normals            objIndexRemap
+	polygonVertexIndex []int32
+	uvIndex            []int32
+	normalIndex        []int32
+	hasUVs             bool
+	hasNormals         bool
+
+	// missingUVs and missingNormals are whether any corner was left without
+	// one, in which case the layer is left out since IndexToDirect can't
+	// point at nothing
+	missingUVs     bool
+	missingNormals bool
+
+	material         int
+	materialSlots    map[int]int32
+	materials        []int
+	polygonMaterials []int32
+}
+
+func newOBJGeometryBuilder(name string, material int) *objGeometryBuilder {
+	return &objGeometryBuilder{
+		name:               name,
+		vertices:           newOBJIndexRemap(),
+		uvs:                newOBJIndexRemap(),
+		normals:            newOBJIndexRemap(),
+		polygonVertexIndex: make([]int32, 0),
+		uvIndex:            make([]int32, 0),
+		normalIndex:        make([]int32, 0),
+		material:           material,
+		materialSlots:      make(map[int]int32),
+		materials:          make([]int, 0),
+		polygonMaterials:   make([]int32, 0),
+	}
+}
+
+func (b *objGeometryBuilder) addCorner(c objCorner, last bool) {
+	v := b.vertices.index(c.v)
+	if last {
+		v = WrapToIndex(v)
+	}
+	b.polygonVertexIndex = append(b.polygonVertexIndex, v)
+	b.uvIndex = append(b.uvIndex, b.uvs.index(c.vt))
+	b.normalIndex = append(b.normalIndex, b.normals.index(c.vn))
+	b.hasUVs = b.hasUVs || c.vt >= 0
+	b.hasNormals = b.hasNormals || c.vn >= 0
+	b.missingUVs = b.missingUVs || c.vt < 0
+	b.missingNormals = b.missingNormals || c.vn < 0
+}
+
+// addFace adds the polygon as a fan of triangles, since splitting only deals
+// in triangles
+func (b *objGeometryBuilder) addFace(corners []objCorner) {
+	slot := int32(-1)
+	if b.material >= 0 {
+		var ok bool
+		slot, ok = b.materialSlots[b.material]
+		if ok == false {
+			slot = int32(len(b.materials))
+			b.materialSlots[b.material] = slot
+			b.materials = append(b.materials, b.material)
+		}
+	}
+
+	for i := 1; i+1 < len(corners); i++ {
+		b.addCorner(corners[0], false)
+		b.addCorner(corners[i], false)
+		b.addCorner(corners[i+1], true)
+		b.polygonMaterials = append(b.polygonMaterials, slot)
+	}
+}
+
+func (b *objGeometryBuilder) empty() bool {
+	return len(b.polygonVertexIndex) == 0
+}
+
+func (b *objGeometryBuilder) build(positions, uvs, normals []float64) streamedGeometry {
+	g := streamedGeometry{
+		name:               b.name,
+		vertices:           b.vertices.gather(positions, 3),
+		polygonVertexIndex: b.polygonVertexIndex,
+		layerElements:      make([]*Node, 0),
+		materials:          b.materials,
+	}
+
+	if b.hasNormals && b.missingNormals == false {
+		g.layerElements = append(g.layerElements, newLayerElementNode(
+			"LayerElementNormal", "ByPolygonVertex", "IndexToDirect",
+			NewNodeFloat64Slice("Normals", b.normals.gather(normals, 3)),
+			NewNodeInt32Slice("NormalsIndex", b.normalIndex),
+		))
+	}
+
+	if b.hasUVs && b.missingUVs == false {
+		g.layerElements = append(g.layerElements, newLayerElementNode(
+			"LayerElementUV", "ByPolygonVertex", "IndexToDirect",
+			NewNodeFloat64Slice("UV", b.uvs.gather(uvs, 2)),
+			NewNodeInt32Slice("UVIndex", b.uvIndex),
+		))
+	}
+
+	if len(b.materials) > 0 {
+		g.layerElements = append(g.layerElements, newLayerElementNode(
+			"LayerElementMaterial", "ByPolygon", "IndexToDirect",
+			NewNodeInt32Slice("Materials", b.polygonMaterials),
+		))
+	}
+
+	return g
+}
+
+// OBJReader converts a Wavefront OBJ file into FBX geometry, streaming faces
+// out to the results channel in batches as they're read so they can be split
+// while the rest of the file loads. Every group or object in the file ends up
+// as at least one piece of geometry.
+type OBJReader struct {
+	FBX   *FBX
+	Error error
+	Name  string
+
+	// OpenMaterialLibrary opens the files named by mtllib statements.
+	// Materials are given default values when it's nil or fails.
+	OpenMaterialLibrary func(name string) (io.ReadCloser, error)
+
+	// Batcher decides how large the jobs sent to the results channel are
+	Batcher *JobBatcher
+
+	results chan<- []*Node
+
+	positions []float64
+	uvs       []float64
+	normals   []float64
+	materials map[string]streamedMaterial
+}
+
+// NewOBJReader creates a reader that sends the geometry it builds to the
+// results channel, which is closed once the file has been read. Geometry
+// without a group is named after name.
+func NewOBJReader(name string, results chan<- []*Node) *OBJReader {
+	return &OBJReader{
+		Name:      name,
+		results:   results,
+		positions: make([]float64, 0),
+		uvs:       make([]float64, 0),
+		normals:   make([]float64, 0),
+		materials: make(map[string]streamedMaterial),
+	}
+}
+
+func parseOBJFloats(fields [][]byte, values []float64) ([]float64, error) {
+	for _, field := range fields {
+		v, err := strconv.ParseFloat(string(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// resolveOBJIndex turns a 1 based index, or a negative index relative to the
+// end, into a 0 based index, returning -1 for indices left empty
+func resolveOBJIndex(field []byte, count int) (int32, error) {
+	if len(field) == 0 {
+		return -1, nil
+	}
+
+	i, err := strconv.Atoi(string(field))
+	if err != nil {
+		return -1, err
+	}
+
+	resolved := i - 1
+	if i < 0 {
+		resolved = count + i
+	}
+	if i == 0 || resolved < 0 || resolved >= count {
+		return -1, fmt.Errorf("OBJ index %d out of range, only %d values defined", i, count)
+	}
+	return int32(resolved), nil
+}
+
+func (or *OBJReader) parseCorner(field []byte) (objCorner, error) {
+	corner := objCorner{v: -1, vt: -1, vn: -1}
+	parts := bytes.SplitN(field, []byte("/"), 3)
+
+	var err error
+	if corner.v, err = resolveOBJIndex(parts[0], len(or.positions)/3); err != nil {
+		return corner, err
+	}
+	if corner.v < 0 {
+		return corner, fmt.Errorf("OBJ face corner '%s' has no vertex", field)
+	}
+	if len(parts) > 1 {
+		if corner.vt, err = resolveOBJIndex(parts[1], len(or.uvs)/2); err != nil {
+			return corner, err
+		}
+	}
+	if len(parts) > 2 {
+		if corner.vn, err = resolveOBJIndex(parts[2], len(or.normals)/3); err != nil {
+			return corner, err
+		}
+	}
+	return corner, nil
+}
+
+func (or *OBJReader) readMaterialLibrary(name string) {
+	if or.OpenMaterialLibrary == nil {
+		return
+	}
+
+	f, err := or.OpenMaterialLibrary(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var current *streamedMaterial
+	finish := func() {
+		if current != nil {
+			or.materials[current.name] = *current
+		}
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "newmtl" && len(fields) > 1 {
+			finish()
+			m := newStreamedMaterial(strings.Join(fields[1:], " "))
+			current = &m
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		values := make([]float64, 0, len(fields)-1)
+		for _, field := range fields[1:] {
+			if v, err := strconv.ParseFloat(field, 64); err == nil {
+				values = append(values, v)
+			}
+		}
+
+		switch fields[0] {
+		case "Kd":
+			if len(values) >= 3 {
+				current.diffuse = [3]float64{values[0], values[1], values[2]}
+			}
+		case "d":
+			if len(values) >= 1 {
+				current.opacity = values[0]
+			}
+		case "Tr":
+			if len(values) >= 1 {
+				current.opacity = 1 - values[0]
+			}
+		case "map_Kd":
+			// Options come before the file name
+			current.diffuseMap = fields[len(fields)-1]
+		}
+	}
+	finish()
+}
+
+func (or *OBJReader) read(r io.Reader, stream *sceneStream) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	builder := newOBJGeometryBuilder("", -1)
+	flush := func() {
+		if builder.empty() == false {
+			stream.addGeometry(builder.build(or.positions, or.uvs, or.normals))
+		}
+		builder = newOBJGeometryBuilder(builder.name, builder.material)
+	}
+
+	corners := make([]objCorner, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+
+		var err error
+		switch string(fields[0]) {
+		case "v":
+			if len(fields) < 4 {
+				return fmt.Errorf("line %d: vertex needs 3 components", line)
+			}
+			or.positions, err = parseOBJFloats(fields[1:4], or.positions)
+
+		case "vt":
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: texture coordinate needs at least 1 component", line)
+			}
+			or.uvs, err = parseOBJFloats(fields[1:2], or.uvs)
+			if err == nil && len(fields) > 2 {
+				or.uvs, err = parseOBJFloats(fields[2:3], or.uvs)
+			} else if err == nil {
+				or.uvs = append(or.uvs, 0)
+			}
+
+		case "vn":
+			if len(fields) < 4 {
+				return fmt.Errorf("line %d: normal needs 3 components", line)
+			}
+			or.normals, err = parseOBJFloats(fields[1:4], or.normals)
+
+		case "f":
+			if len(fields) < 4 {
+				continue
+			}
+			corners = corners[:0]
+			for _, field := range fields[1:] {
+				corner, cornerErr := or.parseCorner(field)
+				if cornerErr != nil {
+					err = cornerErr
+					break
+				}
+				corners = append(corners, corner)
+			}
+			if err == nil {
+				builder.addFace(corners)
+				if len(builder.polygonVertexIndex) >= maxStreamedPolygonVertices {
+					flush()
+				}
+			}
+
+		case "g", "o":
+			flush()
+			builder.name = ""
+			if len(fields) > 1 {
+				builder.name = string(bytes.Join(fields[1:], []byte("_")))
+			}
+
+		case "usemtl":
+			// Libraries can come after the materials in them are used, so
+			// materials are only looked up once the whole file is read
+			name := ""
+			if len(fields) > 1 {
+				name = string(bytes.Join(fields[1:], []byte(" ")))
+			}
+			builder.material = stream.material(newStreamedMaterial(name))
+
+		case "mtllib":
+			for _, name := range fields[1:] {
+				or.readMaterialLibrary(string(name))
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	flush()
+	return nil
+}
+
+// resolveMaterials fills in every material used with what the material
+// libraries say about it, leaving the defaults for any they don't mention
+func (or *OBJReader) resolveMaterials(stream *sceneStream) {
+	for i, m := range stream.materials {
+		if found, ok := or.materials[m.name]; ok {
+			stream.materials[i] = found
+		}
+	}
+}
+
+// ReadFrom reads the entire OBJ file, building up the FBX
+func (or *OBJReader) ReadFrom(r io.Reader) (int64, error) {
+	stream := newSceneStream(or.Name, or.results, or.Batcher)
+	counter := &countingReader{r: r}
+	or.Error = or.read(counter, stream)
+	or.resolveMaterials(stream)
+	or.FBX = stream.finish()
+	return counter.n, or.Error
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+const objTestFile = `# two groups sharing a material, the second using negative indices
+mtllib scene.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+g Floor
+usemtl Stone
+f 1/1/1 2/2/1 3/3/1 4/4/1
+v 5 0 0
+v 6 0 0
+v 5 1 0
+g Wall
+f -3//1 -2//1 -1//1
+`
+
+const objTestMaterials = `newmtl Stone
+Kd 0.5 0.4 0.3
+d 0.75
+map_Kd -bm 1 textures/stone.png
+`
+
+func newTestOBJReader(results chan<- []*Node) *OBJReader {
+	reader := NewOBJReader("scene", results)
+	reader.OpenMaterialLibrary = func(name string) (io.ReadCloser, error) {
+		if name != "scene.mtl" {
+			return nil, errors.New("not found")
+		}
+		return ioutil.NopCloser(strings.NewReader(objTestMaterials)), nil
+	}
+	return reader
+}
+
+func TestOBJReader(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	results := make(chan []*Node, 10)
+	reader := newTestOBJReader(results)
+
+	// ******************************** ACT ***********************************
+	_, err := reader.ReadFrom(strings.NewReader(objTestFile))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+
+	batches := make([][]*Node, 0)
+	for batch := range results {
+		batches = append(batches, batch)
+	}
+	if assert.Len(t, batches, 1) == false || assert.Len(t, batches[0], 2) == false {
+		return
+	}
+
+	floor, ok := decodeMeshGeometry(batches[0][0])
+	if assert.True(t, ok) {
+		assert.Equal(t, "Floor", objectName(batches[0][0]))
+		assert.Equal(t, []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}, floor.vertices)
+		assert.Equal(t, []int32{0, 1, -3, 0, 2, -4}, floor.polygonVertexIndex)
+		assert.Equal(t, []int32{0, 1, 2, 0, 2, 3}, floor.uvs.index)
+		assert.Equal(t, []int32{0, 0, 0, 0, 0, 0}, floor.normals.index)
+		assert.Equal(t, []int32{0, 0}, floor.materials)
+	}
+
+	wall, ok := decodeMeshGeometry(batches[0][1])
+	if assert.True(t, ok) {
+		assert.Equal(t, "Wall", objectName(batches[0][1]))
+		assert.Equal(t, []float64{5, 0, 0, 6, 0, 0, 5, 1, 0}, wall.vertices)
+		assert.Equal(t, []int32{0, 1, -3}, wall.polygonVertexIndex)
+		assert.Nil(t, wall.uvs)
+	}
+
+	meshes := sceneMeshes(reader.FBX)
+	if assert.Len(t, meshes, 2) && assert.Len(t, meshes[0].materials, 1) {
+		stone := meshes[0].materials[0]
+		assert.Equal(t, "Stone", stone.name)
+		assert.Equal(t, [3]float64{0.5, 0.4, 0.3}, stone.diffuse)
+		assert.Equal(t, 0.75, stone.opacity)
+		assert.Equal(t, "textures/stone.png", stone.diffuseMap)
+	}
+}
+
+func TestOBJReaderLeavesOutLayersSomeCornersLack(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	results := make(chan []*Node, 10)
+	reader := newTestOBJReader(results)
+	file := `v 0 0 0
+v 1 0 0
+v 1 1 0
+vt 0 0
+vn 0 0 1
+f 1/1/1 2/1/1 3/1/1
+f 1/1/1 2//1 3/1/1
+`
+
+	// ******************************** ACT ***********************************
+	_, err := reader.ReadFrom(strings.NewReader(file))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	batch := <-results
+	if assert.Len(t, batch, 1) == false {
+		return
+	}
+	geometry, ok := decodeMeshGeometry(batch[0])
+	if assert.True(t, ok) {
+		assert.Nil(t, geometry.uvs)
+		if assert.NotNil(t, geometry.normals) {
+			assert.Equal(t, []int32{0, 0, 0, 0, 0, 0}, geometry.normals.index)
+		}
+	}
+}
+
+func TestOBJReaderFindsMaterialsFromLaterLibraries(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	reader := newTestOBJReader(nil)
+	file := `v 0 0 0
+v 1 0 0
+v 1 1 0
+usemtl Stone
+f 1 2 3
+mtllib scene.mtl
+`
+
+	// ******************************** ACT ***********************************
+	_, err := reader.ReadFrom(strings.NewReader(file))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	meshes := sceneMeshes(reader.FBX)
+	if assert.Len(t, meshes, 1) && assert.Len(t, meshes[0].materials, 1) {
+		assert.Equal(t, [3]float64{0.5, 0.4, 0.3}, meshes[0].materials[0].diffuse)
+		assert.Equal(t, "textures/stone.png", meshes[0].materials[0].diffuseMap)
+	}
+}
+
+func TestOBJReaderRejectsOutOfRangeIndices(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	reader := NewOBJReader("scene", nil)
+
+	// ******************************** ACT ***********************************
+	_, err := reader.ReadFrom(strings.NewReader("v 0 0 0\nv 1 0 0\nf 1 2 -3\n"))
+
+	// ******************************* ASSERT *********************************
+	assert.Error(t, err)
+}
+
+func TestSplitByPlaneIntoChunksFromOBJ(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	dir, err := ioutil.TempDir("", "obj")
+	if assert.NoError(t, err) == false {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	modelName := filepath.Join(dir, "scene.obj")
+	assert.NoError(t, ioutil.WriteFile(modelName, []byte(objTestFile), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "scene.mtl"), []byte(objTestMaterials), 0644))
+
+	retained := new(bytes.Buffer)
+	clipped := new(bytes.Buffer)
+	plane := NewPlane(vector.NewVector3(3, 0, 0), vector.NewVector3(1, 0, 0))
+
+	// ******************************** ACT ***********************************
+	SplitByPlaneIntoChunks(
+		modelName,
+		plane,
+		2,
+		NewOBJChunkWriter(retained, nil, ""),
+		NewOBJChunkWriter(clipped, nil, ""),
+	)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, strings.Join([]string{
+		"g Wall",
+		"v 5 0 0",
+		"v 6 0 0",
+		"v 5 1 0",
+		"vn 0 0 1",
+		"usemtl Stone",
+		"f 1//1 2//1 3//1",
+		"",
+	}, "\n"), retained.String())
+
+	assert.Equal(t, strings.Join([]string{
+		"g Floor",
+		"v 0 0 0",
+		"v 1 0 0",
+		"v 1 1 0",
+		"v 0 1 0",
+		"vt 0 0",
+		"vt 1 0",
+		"vt 1 1",
+		"vt 0 1",
+		"vn 0 0 1",
+		"usemtl Stone",
+		"f 1/1/1 2/2/1 3/3/1",
+		"f 1/1/1 3/3/1 4/4/1",
+		"",
+	}, "\n"), clipped.String())
+}
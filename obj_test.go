@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOBJChunkWriter(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	// Declared in meters with Y up, so coordinates come out as they are
+	fbx := readBackFBX(t, append([]*Node{sceneGlobalSettings(streamedAxisSystem())}, newTexturedQuadScene()...)...)
+	obj := new(bytes.Buffer)
+	mtl := new(bytes.Buffer)
+
+	// ******************************** ACT ***********************************
+	err := NewOBJChunkWriter(obj, mtl, "quad.mtl").WriteChunk(fbx, nil)
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"mtllib quad.mtl",
+		"g QuadModel",
+		"v 0 0 5",
+		"v 1 0 5",
+		"v 1 1 5",
+		"v 0 1 5",
+		"vt 0 0",
+		"vt 1 0",
+		"vt 1 1",
+		"vt 0 1",
+		"vn 0 0 1",
+		"vn 0 0 1",
+		"vn 0 0 1",
+		"vn 0 0 1",
+		"vn 0 0 1",
+		"vn 0 0 1",
+		"usemtl Red",
+		"f 1/1/1 2/2/2 3/3/3",
+		"usemtl Textured",
+		"f 1/1/4 3/3/5 4/4/6",
+		"",
+	}, "\n"), obj.String())
+
+	assert.Equal(t, strings.Join([]string{
+		"newmtl Red",
+		"Kd 1 0 0",
+		"d 1",
+		"",
+		"newmtl Textured",
+		"Kd 0.8 0.8 0.8",
+		"d 1",
+		"map_Kd textures/bricks.png",
+		"",
+		"",
+	}, "\n"), mtl.String())
+}
+
+func TestOBJChunkWriterIndicesContinueAcrossGroups(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(t, NewNodeParent(
+		"Objects",
+		newTriangleGeometryNode(1),
+		newTriangleGeometryNode(2),
+	))
+	obj := new(bytes.Buffer)
+
+	// ******************************** ACT ***********************************
+	err := NewOBJChunkWriter(obj, nil, "").WriteChunk(fbx, nil)
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	faces := make([]string, 0)
+	groups := 0
+	for _, line := range strings.Split(obj.String(), "\n") {
+		if strings.HasPrefix(line, "f ") {
+			faces = append(faces, line)
+		}
+		if strings.HasPrefix(line, "g ") {
+			groups++
+		}
+	}
+	assert.Equal(t, 2, groups)
+	assert.Equal(t, []string{"f 1 2 3", "f 4 5 6"}, faces)
+}
+
+func TestOBJChunkWriterConvertsIntoMetersWithYUp(t *testing.T) {
+	for _, mirrored := range []bool{false, true} {
+		// ****************************** ARRANGE *********************************
+		scaling := vector.NewVector3(1, 1, 1)
+		if mirrored {
+			scaling = vector.NewVector3(-1, 1, 1)
+		}
+
+		// Z up and in centimeters, with a triangle facing up along Z
+		zUp := AxisSystem{
+			UpAxis: 2, UpAxisSign: 1,
+			FrontAxis: 1, FrontAxisSign: -1,
+			CoordAxis: 0, CoordAxisSign: 1,
+			UnitScaleFactor: 1,
+		}
+		fbx := readBackFBX(
+			t,
+			sceneGlobalSettings(zUp),
+			NewNodeParent(
+				"Objects",
+				newObjectNode(
+					"Geometry", 1, "Triangle", "Mesh",
+					NewNodeFloat64Slice("Vertices", []float64{0, 0, 100, 100, 0, 100, 0, 100, 100}),
+					NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3}),
+				),
+				newObjectNode("Model", 2, "TriangleModel", "Mesh", NewNodeParent(
+					"Properties70",
+					newVector3PNode("Lcl Scaling", scaling),
+				)),
+			),
+			NewNodeParent(
+				"Connections",
+				newConnectionNode("OO", 2, 0),
+				newConnectionNode("OO", 1, 2),
+			),
+		)
+		obj := new(bytes.Buffer)
+
+		// ******************************** ACT ***********************************
+		err := NewOBJChunkWriter(obj, nil, "").WriteChunk(fbx, nil)
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, err)
+		positions := make([]vector.Vector3, 0)
+		corners := make([]int, 0)
+		for _, line := range strings.Split(obj.String(), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 4 && fields[0] == "v" {
+				x, _ := strconv.ParseFloat(fields[1], 64)
+				y, _ := strconv.ParseFloat(fields[2], 64)
+				z, _ := strconv.ParseFloat(fields[3], 64)
+				positions = append(positions, vector.NewVector3(x, y, z))
+			}
+			if len(fields) > 0 && fields[0] == "f" {
+				for _, f := range fields[1:] {
+					index, _ := strconv.Atoi(f)
+					corners = append(corners, index-1)
+				}
+			}
+		}
+		if assert.Len(t, positions, 3) == false || assert.Len(t, corners, 3) == false {
+			continue
+		}
+
+		// One meter up, with Z pointing back towards where the file's front
+		// was, and X mirrored along with the model
+		assertVectorInDelta(t, vector.NewVector3(0, 1, 0), positions[0])
+		assertVectorInDelta(t, vector.NewVector3(scaling.X(), 1, 0), positions[1])
+		assertVectorInDelta(t, vector.NewVector3(0, 1, -1), positions[2])
+
+		// Whatever the transform, faces point up and wind counter clockwise
+		// when looked at from above
+		a, b, c := positions[corners[0]], positions[corners[1]], positions[corners[2]]
+		facing := b.Sub(a).Cross(c.Sub(a))
+		assert.True(t, facing.Y() > 0, "mirrored: %v", mirrored)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// offsetWriter writes to a WriterAt one piece after another, starting at an
+// offset
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (ow *offsetWriter) Write(b []byte) (int, error) {
+	n, err := ow.w.WriteAt(b, ow.offset)
+	ow.offset += int64(n)
+	return n, err
+}
+
+// placedNode is a node along with where it starts in the file. Nodes that
+// are split up only get everything before their nested nodes written, with
+// each nested node placed on it's own.
+type placedNode struct {
+	node       *Node
+	parent     *Node
+	offset     uint64
+	end        uint64
+	headerOnly bool
+}
+
+// ParallelPatchWriter writes out the FBX with it's sorted diffs applied
+// directly to where everything belongs in the file. Once the diffs are
+// applied every node's length is known, so where each top level node and
+// each node directly under Objects lands can be worked out up front and
+// they're all encoded and written at the same time.
+type ParallelPatchWriter struct {
+	fbx     *FBX
+	diffs   []Diff
+	workers int
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing with an OffsetError on the first one that's off
+	Verify bool
+}
+
+// NewParallelPatchWriter creates a writer that encodes with the number of
+// workers given
+func NewParallelPatchWriter(fbx *FBX, diffs []Diff, workers int) *ParallelPatchWriter {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ParallelPatchWriter{
+		fbx:     fbx,
+		diffs:   diffs,
+		workers: workers,
+	}
+}
+
+// place works out where every piece of the patched FBX is written, returning
+// the pieces and where the last one ends
+func (pw *ParallelPatchWriter) place(patched *FBX, sizer *nodeWriter) ([]placedNode, uint64, error) {
+	offset := uint64(len(patched.Header.data))
+	nodes := make([]*Node, 0, len(patched.Nodes)+1)
+	if patched.Top != nil {
+		nodes = append(nodes, patched.Top)
+	}
+	nodes = append(nodes, patched.Nodes...)
+
+	placed := make([]placedNode, 0, len(nodes))
+	for _, n := range nodes {
+		end := offset + sizer.length(n)
+		if n.Name != "Objects" || n.Length == 0 {
+			placed = append(placed, placedNode{node: n, offset: offset})
+			offset = end
+			continue
+		}
+
+		placed = append(placed, placedNode{node: n, offset: offset, end: end, headerOnly: true})
+		offset += sizer.headerSize() + uint64(n.NameLen) + n.PropertyListLen
+		for _, child := range n.NestedNodes {
+			if child == nil {
+				continue
+			}
+			placed = append(placed, placedNode{node: child, parent: n, offset: offset})
+			offset += sizer.length(child)
+		}
+
+		if pw.Verify && offset != end {
+			return nil, 0, &OffsetError{Path: n.Name, What: "ends", Expected: end, Actual: offset}
+		}
+		offset = end
+	}
+	return placed, offset, nil
+}
+
+// write encodes a single piece of the FBX to where it belongs
+func (pw *ParallelPatchWriter) write(w io.WriterAt, p placedNode, legacy bool) error {
+	nw := newNodeWriter(&offsetWriter{w: w, offset: int64(p.offset)}, legacy)
+	nw.verify = pw.Verify
+
+	var err error
+	if p.headerOnly {
+		err = nw.writeHeader(p.node, p.end)
+	} else {
+		_, err = nw.writeNode(p.node, p.offset)
+	}
+
+	if err != nil && p.parent != nil {
+		return within(p.parent.Name, err)
+	}
+	if err != nil {
+		return err
+	}
+	return nw.Flush()
+}
+
+// WriteAt writes the patched FBX starting at the very beginning of the
+// writer, returning how many bytes it takes up. Writing stops at the first
+// error any worker runs into.
+func (pw *ParallelPatchWriter) WriteAt(w io.WriterAt) (int64, error) {
+	legacy := pw.fbx.Header.Version() < 7500
+	patched := pw.fbx.ApplyDiffs(pw.diffs)
+
+	placed, end, err := pw.place(patched, &nodeWriter{legacy: legacy})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := w.WriteAt(patched.Header.data, 0); err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan placedNode, pw.workers)
+	errs := make(chan error, pw.workers)
+	failed := make(chan struct{})
+	var fail sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < pw.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			for p := range jobs {
+				if err != nil {
+					continue
+				}
+				if err = pw.write(w, p, legacy); err != nil {
+					fail.Do(func() { close(failed) })
+				}
+			}
+			errs <- err
+		}()
+	}
+
+send:
+	for _, p := range placed {
+		select {
+		case jobs <- p:
+		case <-failed:
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	footer := &offsetWriter{w: w, offset: int64(end)}
+	n, err := writeFooter(footer, end, patched.Footer, patched.Header.Version())
+	return int64(end) + int64(n), err
+}
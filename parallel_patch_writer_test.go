@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryFile is a WriterAt that grows to fit whatever is written to it
+type memoryFile struct {
+	mutex sync.Mutex
+	data  []byte
+	fail  bool
+}
+
+func (f *memoryFile) WriteAt(b []byte, offset int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.fail {
+		return 0, errors.New("out of space")
+	}
+	if end := int(offset) + len(b); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	return copy(f.data[offset:], b), nil
+}
+
+func TestParallelPatchWriterMatchesPatchWriter(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		fbx, retained, clipped := splitGenerated(t, version)
+
+		for _, diffs := range [][]Diff{retained, clipped, nil} {
+			expected := new(bytes.Buffer)
+			_, err := NewPatchWriter(fbx, diffs, func(int, error) {}).Write(expected)
+			assert.NoError(t, err)
+
+			file := new(memoryFile)
+			pw := NewParallelPatchWriter(fbx, diffs, 4)
+			pw.Verify = true
+
+			// ******************************** ACT ***********************************
+			written, err := pw.WriteAt(file)
+
+			// ******************************* ASSERT *********************************
+			assert.NoError(t, err)
+			assert.Equal(t, int64(expected.Len()), written)
+			assert.Equal(t, expected.Bytes(), file.data, "version %d", version)
+		}
+	}
+}
+
+func TestParallelPatchWriterStopsOnError(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx, retained, _ := splitGenerated(t, 7500)
+
+	// ******************************** ACT ***********************************
+	_, err := NewParallelPatchWriter(fbx, retained, 4).WriteAt(&memoryFile{fail: true})
+
+	// ******************************* ASSERT *********************************
+	assert.EqualError(t, err, "out of space")
+}
+
+func TestWriteChunksWritesFilesInParallelWhenAsked(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx, retained, _ := splitGenerated(t, 7500)
+
+	dir, err := ioutil.TempDir("", "parallel")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	parallelFile, err := os.Create(filepath.Join(dir, "parallel.fbx"))
+	assert.NoError(t, err)
+	defer parallelFile.Close()
+
+	singlePassFile, err := os.Create(filepath.Join(dir, "single-pass.fbx"))
+	assert.NoError(t, err)
+	defer singlePassFile.Close()
+
+	parallel := NewFBXChunkWriter(parallelFile)
+	parallel.Parallel = true
+	singlePass := NewFBXChunkWriter(singlePassFile)
+
+	expected := new(bytes.Buffer)
+	NewPatchWriter(fbx, retained, func(int, error) {}).Write(expected)
+
+	// ******************************** ACT ***********************************
+	outputs, rest := fbxOutputs(MultiChunkWriter{parallel, singlePass}, retained, nil)
+	errs := WriteChunks(fbx, []Chunk{{Writer: MultiChunkWriter{parallel, singlePass}, Diffs: retained}})
+	parallelWritten, parallelErr := ioutil.ReadFile(parallelFile.Name())
+	singlePassWritten, singlePassErr := ioutil.ReadFile(singlePassFile.Name())
+
+	// ******************************* ASSERT *********************************
+	if assert.Len(t, outputs, 1) {
+		assert.Equal(t, singlePassFile, outputs[0].Writer)
+	}
+	assert.Equal(t, []ChunkWriter{parallel}, rest)
+	assert.Equal(t, []error{nil}, errs)
+	assert.NoError(t, parallelErr)
+	assert.NoError(t, singlePassErr)
+	assert.Equal(t, expected.Bytes(), parallelWritten)
+	assert.Equal(t, expected.Bytes(), singlePassWritten)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/binary"
 	"io"
 )
 
@@ -12,6 +11,10 @@ type PatchWriter struct {
 	diffs     []Diff
 	diffIndex int
 	callback  func(int, error)
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing with an OffsetError on the first one that's off
+	Verify bool
 }
 
 func NewPatchWriter(fbx *FBX, diffs []Diff, callback func(int, error)) *PatchWriter {
@@ -23,55 +26,49 @@ func NewPatchWriter(fbx *FBX, diffs []Diff, callback func(int, error)) *PatchWri
 	}
 }
 
+// Write writes out the patched FBX, buffering everything on it's way to the
+// writer, and returns how many bytes were written
 func (pw PatchWriter) Write(w io.Writer) (int, error) {
-	currentOffset := 0
-	bytesWritten, err := w.Write(pw.fbx.Header.data)
-	currentOffset += bytesWritten
+	nw := newNodeWriter(w, pw.fbx.Header.Version() < 7500)
+	nw.verify = pw.Verify
+	currentOffset, err := pw.write(nw)
+	if err == nil {
+		err = nw.Flush()
+	}
+	pw.callback(currentOffset, err)
+	return currentOffset, err
+}
 
+func (pw *PatchWriter) write(nw *nodeWriter) (int, error) {
+	currentOffset, err := nw.Write(pw.fbx.Header.data)
 	if err != nil {
 		return currentOffset, err
 	}
 
-	currentOffset, err = pw.writeNode(w, pw.fbx.Top, currentOffset, false)
+	currentOffset, err = pw.writeNode(nw, pw.fbx.Top, currentOffset)
 	if err != nil {
 		return currentOffset, err
 	}
 
-	for i, n := range pw.fbx.Nodes {
-		currentOffset, err = pw.writeNode(w, n, currentOffset, len(pw.fbx.Nodes)-1 == i)
+	for _, n := range pw.fbx.Nodes {
+		currentOffset, err = pw.writeNode(nw, n, currentOffset)
 		if err != nil {
 			return currentOffset, err
 		}
 	}
 
-	err = binary.Write(w, binary.LittleEndian, uint64(7409768082772311290))
-	if err != nil {
-		return currentOffset, err
-	}
-	err = binary.Write(w, binary.LittleEndian, uint64(9090224599739365041))
-	if err != nil {
-		return currentOffset, err
-	}
-
-	fuckingEmpty := make([]byte, 120)
-	n, err := w.Write(fuckingEmpty)
-
-	if err != nil {
-		return currentOffset + 16 + n, err
-	}
-
-	// this just appears at the end of every compliant file
-	n, err = w.Write([]byte{0xF8, 0x5A, 0x8C, 0x6A, 0xDE, 0xF5, 0xD9, 0x7E, 0xEC, 0xE9, 0x0C, 0xE3, 0x75, 0x8F, 0x29, 0x0B})
-
-	pw.callback(currentOffset+136+n, err)
-	return currentOffset + 136 + n, err
+	n, err := writeFooter(nw, uint64(currentOffset), pw.fbx.Footer, pw.fbx.Header.Version())
+	return currentOffset + n, err
 }
 
-// WriteNode writes a node to the writer, and returns true if you can continue writing
-func (pw *PatchWriter) writeNode(w io.Writer, n *Node, currentOffset int, endOfList bool) (int, error) {
+// writeNode writes a node with all of it's diffs applied, returning the
+// offset it ends at
+func (pw *PatchWriter) writeNode(nw *nodeWriter, n *Node, currentOffset int) (int, error) {
 	var diffedNode *Node
 	diffedNode, pw.diffIndex = n.ApplyDiffs(pw.diffs, pw.diffIndex)
-	newOffset, err := diffedNode.Write(w, uint64(currentOffset), endOfList)
-	// newOffset, err := n.Write(w, uint64(currentOffset), endOfList)
+	if diffedNode == nil {
+		return currentOffset, nil
+	}
+	newOffset, err := nw.writeNode(diffedNode, uint64(currentOffset))
 	return int(newOffset), err
 }
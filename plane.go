@@ -14,3 +14,18 @@ type Plane struct {
 func NewPlane(origin, normal vector.Vector3) Plane {
 	return Plane{origin, normal.Normalized()}
 }
+
+// ToLocal takes a plane defined in world space and expresses it in the space
+// of something that's transformed into world space by the matrix. Which side
+// of the plane a point falls on is preserved. Returns false if the matrix
+// collapses space and can't be inverted.
+func (p Plane) ToLocal(world Matrix4) (Plane, bool) {
+	inverse, ok := world.Inverse()
+	if ok == false {
+		return p, false
+	}
+	return NewPlane(
+		inverse.MultiplyPoint(p.origin),
+		world.Transpose().MultiplyDirection(p.normal),
+	), true
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type plyProperty struct {
+	name      string
+	dataType  string
+	countType string // only set for list properties
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+type plyHeader struct {
+	format   string
+	elements []plyElement
+}
+
+func plyTypeSize(dataType string) int {
+	switch dataType {
+	case "char", "int8", "uchar", "uint8":
+		return 1
+	case "short", "int16", "ushort", "uint16":
+		return 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+func readPLYHeader(r *bufio.Reader) (*plyHeader, error) {
+	header := &plyHeader{elements: make([]plyElement, 0)}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(line) != "ply" {
+		return nil, errors.New("not a PLY file")
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("malformed PLY format: %s", line)
+			}
+			header.format = fields[1]
+
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("malformed PLY element: %s", line)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			header.elements = append(header.elements, plyElement{name: fields[1], count: count})
+
+		case "property":
+			if len(header.elements) == 0 {
+				return nil, errors.New("PLY property declared before any element")
+			}
+			property := plyProperty{}
+			if len(fields) == 5 && fields[1] == "list" {
+				property.countType, property.dataType, property.name = fields[2], fields[3], fields[4]
+			} else if len(fields) == 3 {
+				property.dataType, property.name = fields[1], fields[2]
+			} else {
+				return nil, fmt.Errorf("malformed PLY property: %s", line)
+			}
+			if plyTypeSize(property.dataType) == 0 || (property.countType != "" && plyTypeSize(property.countType) == 0) {
+				return nil, fmt.Errorf("unknown PLY property type: %s", line)
+			}
+			element := &header.elements[len(header.elements)-1]
+			element.properties = append(element.properties, property)
+
+		case "end_header":
+			switch header.format {
+			case "ascii", "binary_little_endian", "binary_big_endian":
+				return header, nil
+			}
+			return nil, fmt.Errorf("unsupported PLY format '%s'", header.format)
+		}
+	}
+}
+
+// plyDecoder reads the individual values of a PLY body
+type plyDecoder interface {
+	value(dataType string) (float64, error)
+}
+
+type plyBinaryDecoder struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (d *plyBinaryDecoder) value(dataType string) (float64, error) {
+	b := d.buf[:plyTypeSize(dataType)]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return 0, err
+	}
+
+	switch dataType {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(d.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(d.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(d.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(d.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(d.order.Uint32(b))), nil
+	case "double", "float64":
+		return math.Float64frombits(d.order.Uint64(b)), nil
+	}
+	return 0, fmt.Errorf("unknown PLY type '%s'", dataType)
+}
+
+type plyASCIIDecoder struct {
+	r     *bufio.Reader
+	token []byte
+}
+
+func (d *plyASCIIDecoder) value(dataType string) (float64, error) {
+	d.token = d.token[:0]
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(d.token) > 0 {
+				break
+			}
+			return 0, err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if len(d.token) > 0 {
+				break
+			}
+			continue
+		}
+		d.token = append(d.token, c)
+	}
+	return strconv.ParseFloat(string(d.token), 64)
+}
+
+// plyVertexAttribute is where a property of the vertex element ends up
+type plyVertexAttribute struct {
+	values    *[]float64
+	stride    int
+	component int
+	scale     float64
+}
+
+// plyVertices holds every vertex of the file, since faces can reference any
+// of them at any point
+type plyVertices struct {
+	positions []float64
+	normals   []float64
+	uvs       []float64
+	colors    []float64
+}
+
+func (pv *plyVertices) attributes(element plyElement) []*plyVertexAttribute {
+	attributes := make([]*plyVertexAttribute, len(element.properties))
+	hasNormals, hasUVs, hasColors := false, false, false
+
+	for i, property := range element.properties {
+		if property.countType != "" {
+			continue
+		}
+
+		attribute := &plyVertexAttribute{scale: 1}
+		switch property.name {
+		case "x", "y", "z":
+			attribute.values = &pv.positions
+			attribute.stride = 3
+			attribute.component = int(property.name[0] - 'x')
+		case "nx", "ny", "nz":
+			attribute.values = &pv.normals
+			attribute.stride = 3
+			attribute.component = int(property.name[1] - 'x')
+			hasNormals = true
+		case "u", "s", "texture_u", "texture_s":
+			attribute.values = &pv.uvs
+			attribute.stride = 2
+			hasUVs = true
+		case "v", "t", "texture_v", "texture_t":
+			attribute.values = &pv.uvs
+			attribute.stride = 2
+			attribute.component = 1
+			hasUVs = true
+		case "red", "green", "blue", "alpha":
+			attribute.values = &pv.colors
+			attribute.stride = 4
+			attribute.component = map[string]int{"red": 0, "green": 1, "blue": 2, "alpha": 3}[property.name]
+			if property.dataType == "uchar" || property.dataType == "uint8" {
+				attribute.scale = 1.0 / 255
+			}
+			hasColors = true
+		default:
+			continue
+		}
+		attributes[i] = attribute
+	}
+
+	pv.positions = make([]float64, element.count*3)
+	if hasNormals {
+		pv.normals = make([]float64, element.count*3)
+	}
+	if hasUVs {
+		pv.uvs = make([]float64, element.count*2)
+	}
+	if hasColors {
+		// Colors without alpha are opaque
+		pv.colors = make([]float64, element.count*4)
+		for i := 3; i < len(pv.colors); i += 4 {
+			pv.colors[i] = 1
+		}
+	}
+
+	return attributes
+}
+
+func (pv *plyVertices) count() int {
+	return len(pv.positions) / 3
+}
+
+// plyGeometryBuilder collects faces until there's enough of them to make a
+// piece of geometry, only keeping the vertices those faces use
+type plyGeometryBuilder struct {
+	source             *plyVertices
+	remap              map[int32]int32
+	used               []int32
+	polygonVertexIndex []int32
+}
+
+func newPLYGeometryBuilder(source *plyVertices) *plyGeometryBuilder {
+	return &plyGeometryBuilder{
+		source:             source,
+		remap:              make(map[int32]int32),
+		used:               make([]int32, 0),
+		polygonVertexIndex: make([]int32, 0),
+	}
+}
+
+func (b *plyGeometryBuilder) local(index int32) int32 {
+	local, ok := b.remap[index]
+	if ok == false {
+		local = int32(len(b.used))
+		b.remap[index] = local
+		b.used = append(b.used, index)
+	}
+	return local
+}
+
+// addFace adds the polygon as a fan of triangles, since splitting only deals
+// in triangles
+func (b *plyGeometryBuilder) addFace(indices []int32) {
+	first := b.local(indices[0])
+	for i := 1; i+1 < len(indices); i++ {
+		b.polygonVertexIndex = append(
+			b.polygonVertexIndex,
+			first,
+			b.local(indices[i]),
+			WrapToIndex(b.local(indices[i+1])),
+		)
+	}
+}
+
+func (b *plyGeometryBuilder) gather(values []float64, components int) []float64 {
+	gathered := make([]float64, 0, len(b.used)*components)
+	for _, index := range b.used {
+		gathered = append(gathered, values[int(index)*components:(int(index)+1)*components]...)
+	}
+	return gathered
+}
+
+// flush sends everything collected so far off as a piece of geometry
+func (b *plyGeometryBuilder) flush(stream *sceneStream) {
+	if len(b.polygonVertexIndex) == 0 {
+		return
+	}
+
+	layerElements := make([]*Node, 0)
+	if b.source.normals != nil {
+		layerElements = append(layerElements, newLayerElementNode(
+			"LayerElementNormal", "ByVertice", "Direct",
+			NewNodeFloat64Slice("Normals", b.gather(b.source.normals, 3)),
+		))
+	}
+	if b.source.uvs != nil {
+		layerElements = append(layerElements, newLayerElementNode(
+			"LayerElementUV", "ByVertice", "Direct",
+			NewNodeFloat64Slice("UV", b.gather(b.source.uvs, 2)),
+		))
+	}
+	if b.source.colors != nil {
+		layerElements = append(layerElements, newLayerElementNode(
+			"LayerElementColor", "ByVertice", "Direct",
+			NewNodeFloat64Slice("Colors", b.gather(b.source.colors, 4)),
+		))
+	}
+
+	stream.addGeometry(streamedGeometry{
+		vertices:           b.gather(b.source.positions, 3),
+		polygonVertexIndex: b.polygonVertexIndex,
+		layerElements:      layerElements,
+	})
+
+	b.remap = make(map[int32]int32)
+	b.used = make([]int32, 0)
+	b.polygonVertexIndex = make([]int32, 0)
+}
+
+// PLYReader converts a PLY file into FBX geometry, streaming faces out to the
+// results channel in pieces as they're read so they can be split while the
+// rest of the file loads
+type PLYReader struct {
+	FBX   *FBX
+	Error error
+	Name  string
+
+	// Batcher decides how large the jobs sent to the results channel are
+	Batcher *JobBatcher
+
+	results chan<- []*Node
+}
+
+// NewPLYReader creates a reader that sends the geometry it builds to the
+// results channel, which is closed once the file has been read. Geometry and
+// models are named after name.
+func NewPLYReader(name string, results chan<- []*Node) *PLYReader {
+	return &PLYReader{
+		Name:    name,
+		results: results,
+	}
+}
+
+func (pr *PLYReader) readElement(decoder plyDecoder, element plyElement, f func(property int, values []float64) error) error {
+	values := make([]float64, 0)
+	for i := 0; i < element.count; i++ {
+		for p, property := range element.properties {
+			values = values[:0]
+			if property.countType == "" {
+				v, err := decoder.value(property.dataType)
+				if err != nil {
+					return err
+				}
+				values = append(values, v)
+			} else {
+				count, err := decoder.value(property.countType)
+				if err != nil {
+					return err
+				}
+				for c := 0; c < int(count); c++ {
+					v, err := decoder.value(property.dataType)
+					if err != nil {
+						return err
+					}
+					values = append(values, v)
+				}
+			}
+			if err := f(p, values); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pr *PLYReader) read(r *bufio.Reader, stream *sceneStream) error {
+	header, err := readPLYHeader(r)
+	if err != nil {
+		return err
+	}
+
+	var decoder plyDecoder
+	switch header.format {
+	case "ascii":
+		decoder = &plyASCIIDecoder{r: r}
+	case "binary_little_endian":
+		decoder = &plyBinaryDecoder{r: r, order: binary.LittleEndian}
+	case "binary_big_endian":
+		decoder = &plyBinaryDecoder{r: r, order: binary.BigEndian}
+	}
+
+	vertices := &plyVertices{}
+	builder := newPLYGeometryBuilder(vertices)
+	faceIndices := make([]int32, 0)
+
+	for _, element := range header.elements {
+		switch element.name {
+		case "vertex":
+			attributes := vertices.attributes(element)
+			vertex := 0
+			err = pr.readElement(decoder, element, func(property int, values []float64) error {
+				if attribute := attributes[property]; attribute != nil {
+					(*attribute.values)[vertex*attribute.stride+attribute.component] = values[0] * attribute.scale
+				}
+				if property == len(element.properties)-1 {
+					vertex++
+				}
+				return nil
+			})
+
+		case "face":
+			err = pr.readElement(decoder, element, func(property int, values []float64) error {
+				name := element.properties[property].name
+				if name != "vertex_indices" && name != "vertex_index" {
+					return nil
+				}
+				if len(values) < 3 {
+					return nil
+				}
+
+				faceIndices = faceIndices[:0]
+				for _, v := range values {
+					if v < 0 || int(v) >= vertices.count() {
+						return fmt.Errorf("PLY face references vertex %d, but there are only %d vertices", int(v), vertices.count())
+					}
+					faceIndices = append(faceIndices, int32(v))
+				}
+				builder.addFace(faceIndices)
+
+				if len(builder.polygonVertexIndex) >= maxStreamedPolygonVertices {
+					builder.flush(stream)
+				}
+				return nil
+			})
+
+		default:
+			err = pr.readElement(decoder, element, func(int, []float64) error { return nil })
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	builder.flush(stream)
+	return nil
+}
+
+// ReadFrom reads the entire PLY file, building up the FBX
+func (pr *PLYReader) ReadFrom(r io.Reader) (int64, error) {
+	stream := newSceneStream(pr.Name, pr.results, pr.Batcher)
+	counter := &countingReader{r: r}
+	pr.Error = pr.read(bufio.NewReader(counter), stream)
+	pr.FBX = stream.finish()
+	return counter.n, pr.Error
+}
+
+// countingReader keeps track of how many bytes have been read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+const plyTestHeader = `element vertex 5
+property float x
+property float y
+property float z
+property float nx
+property float ny
+property float nz
+property uchar red
+property uchar green
+property uchar blue
+element face 2
+property list uchar int vertex_indices
+property uchar flags
+end_header
+`
+
+// plyTestVertices is a quad next to a triangle, with x, y, z, nx, ny, nz,
+// red, green, blue for each vertex
+var plyTestVertices = [][]float64{
+	{0, 0, 0, 0, 0, 1, 255, 0, 0},
+	{1, 0, 0, 0, 0, 1, 0, 255, 0},
+	{1, 1, 0, 0, 0, 1, 0, 0, 255},
+	{0, 1, 0, 0, 0, 1, 255, 255, 255},
+	{2, 0, 0, 0, 0, 1, 0, 0, 0},
+}
+
+var plyTestFaces = [][]int32{
+	{0, 1, 2, 3},
+	{1, 4, 2},
+}
+
+func plyTestFile(format string) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("ply\nformat " + format + " 1.0\ncomment test\n" + plyTestHeader)
+
+	if format == "ascii" {
+		for _, v := range plyTestVertices {
+			for i, c := range v {
+				if i > 0 {
+					buffer.WriteString(" ")
+				}
+				buffer.WriteString(strconv.FormatFloat(c, 'g', -1, 64))
+			}
+			buffer.WriteString("\n")
+		}
+		for _, f := range plyTestFaces {
+			buffer.WriteString(strconv.Itoa(len(f)))
+			for _, i := range f {
+				buffer.WriteString(" " + strconv.Itoa(int(i)))
+			}
+			buffer.WriteString(" 0\n")
+		}
+		return buffer.Bytes()
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if format == "binary_big_endian" {
+		order = binary.BigEndian
+	}
+	for _, v := range plyTestVertices {
+		for i := 0; i < 6; i++ {
+			binary.Write(buffer, order, float32(v[i]))
+		}
+		buffer.Write([]byte{byte(v[6]), byte(v[7]), byte(v[8])})
+	}
+	for _, f := range plyTestFaces {
+		buffer.WriteByte(byte(len(f)))
+		for _, i := range f {
+			binary.Write(buffer, order, i)
+		}
+		buffer.WriteByte(0)
+	}
+	return buffer.Bytes()
+}
+
+func TestPLYReader(t *testing.T) {
+	for _, format := range []string{"ascii", "binary_little_endian", "binary_big_endian"} {
+		t.Run(format, func(t *testing.T) {
+			// ****************************** ARRANGE *********************************
+			results := make(chan []*Node, 10)
+			reader := NewPLYReader("scan", results)
+
+			// ******************************** ACT ***********************************
+			_, err := reader.ReadFrom(bytes.NewReader(plyTestFile(format)))
+
+			// ******************************* ASSERT *********************************
+			assert.NoError(t, err)
+
+			streamed := make([]*Node, 0)
+			for batch := range results {
+				streamed = append(streamed, batch...)
+			}
+			if assert.Len(t, streamed, 1) == false {
+				return
+			}
+
+			objects := reader.FBX.ObjectNodes()
+			assert.Len(t, objects, 2)
+			assert.Equal(t, streamed[0], objects[firstStreamedUID])
+			assert.Equal(t, "scan_0", objectName(objects[firstStreamedUID+1]))
+
+			geometry, ok := decodeMeshGeometry(streamed[0])
+			if assert.True(t, ok) == false {
+				return
+			}
+			assert.Equal(t, []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0, 2, 0, 0}, geometry.vertices)
+			assert.Equal(t, []int32{0, 1, -3, 0, 2, -4, 1, 4, -3}, geometry.polygonVertexIndex)
+			if assert.NotNil(t, geometry.normals) {
+				assert.Equal(t, "ByVertice", geometry.normals.mapping)
+				assert.Len(t, geometry.normals.data, 15)
+			}
+
+			colors := streamed[0].GetNodes("LayerElementColor", "Colors")
+			if assert.Len(t, colors, 1) {
+				data, _ := colors[0].Float64Slice()
+				assert.Equal(t, []float64{1, 0, 0, 1}, data[:4])
+			}
+
+			// Scans are taken to already be in meters with Y up
+			assert.Equal(t, IdentityMatrix4(), NewAxisSystem(reader.FBX).CanonicalToFile())
+
+			types := make([]string, 0)
+			for _, objectType := range reader.FBX.GetNodes("Definitions", "ObjectType") {
+				types = append(types, objectType.Properties[0].AsString())
+			}
+			assert.Equal(t, []string{"GlobalSettings", "Geometry", "Model"}, types)
+
+			graph := NewConnectionGraph(reader.FBX)
+			assert.Equal(t, []int64{firstStreamedUID + 1}, graph.ParentIDs(firstStreamedUID))
+			assert.Equal(t, []int64{0}, graph.ParentIDs(firstStreamedUID+1))
+		})
+	}
+}
+
+func TestPLYReaderRejectsOutOfRangeFaces(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	file := "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n3 0 1 2\n"
+	reader := NewPLYReader("scan", nil)
+
+	// ******************************** ACT ***********************************
+	_, err := reader.ReadFrom(bytes.NewReader([]byte(file)))
+
+	// ******************************* ASSERT *********************************
+	assert.Error(t, err)
+}
+
+func TestSplitByPlaneIntoChunksFromPLY(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	dir, err := ioutil.TempDir("", "ply")
+	if assert.NoError(t, err) == false {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	modelName := filepath.Join(dir, "scan.ply")
+	assert.NoError(t, ioutil.WriteFile(modelName, plyTestFile("binary_little_endian"), 0644))
+
+	retained := new(bytes.Buffer)
+	clipped := new(bytes.Buffer)
+	plane := NewPlane(vector.NewVector3(-1, 0, 0), vector.NewVector3(1, 0, 0))
+
+	// ******************************** ACT ***********************************
+	SplitByPlaneIntoChunks(modelName, plane, 2, NewFBXChunkWriter(retained), NewFBXChunkWriter(clipped))
+
+	// ******************************* ASSERT *********************************
+	retainedFBX, err := ReadFrom(bytes.NewReader(retained.Bytes()))
+	assert.NoError(t, err)
+	clippedFBX, err := ReadFrom(bytes.NewReader(clipped.Bytes()))
+	assert.NoError(t, err)
+
+	polygons := func(fbx *FBX) []int32 {
+		indices := fbx.GetNodes("Objects", "Geometry", "PolygonVertexIndex")
+		if len(indices) != 1 {
+			return nil
+		}
+		values, _ := indices[0].Int32Slice()
+		return values
+	}
+	assert.Equal(t, []int32{0, 1, -3, 0, 2, -4, 1, 4, -3}, polygons(retainedFBX))
+	assert.Nil(t, polygons(clippedFBX))
+	assert.Len(t, clippedFBX.GetNodes("Objects", "Model"), 0)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/EliCDavis/vector"
+)
+
+// Property70Kind is what a Properties70 entry's values hold
+type Property70Kind int
+
+const (
+	// Property70Unknown is anything that doesn't fit the other kinds, like
+	// entries with no values at all
+	Property70Unknown Property70Kind = iota
+	Property70Int
+	Property70Enum
+	Property70Double
+	Property70Vector3
+	Property70Color
+	Property70String
+)
+
+// Property70 is a single P entry of a Properties70 section. Every entry has a
+// name, a type, a label, flags and then it's values.
+type Property70 struct {
+	Name  string
+	Type  string
+	Label string
+
+	// Flags are A when the property can be animated, + when it is animated
+	// and U when it was added by the user rather than being built in
+	Flags string
+
+	Values []*Property
+}
+
+// NewProperty70 creates an entry to add to a Properties70 section
+func NewProperty70(name, propertyType, label, flags string, values ...*Property) *Property70 {
+	return &Property70{
+		Name:   name,
+		Type:   propertyType,
+		Label:  label,
+		Flags:  flags,
+		Values: values,
+	}
+}
+
+// readProperty70 interprets a P node, returning false if it isn't laid out
+// like one
+func readProperty70(n *Node) (*Property70, bool) {
+	if n == nil || n.Name != "P" || len(n.Properties) < 4 {
+		return nil, false
+	}
+	for _, p := range n.Properties[:4] {
+		if p.TypeCode != 'S' {
+			return nil, false
+		}
+	}
+	return NewProperty70(
+		n.Properties[0].AsString(),
+		n.Properties[1].AsString(),
+		n.Properties[2].AsString(),
+		n.Properties[3].AsString(),
+		n.Properties[4:]...,
+	), true
+}
+
+// node creates the P node the entry is written as
+func (p *Property70) node() *Node {
+	properties := make([]*Property, 0, 4+len(p.Values))
+	properties = append(
+		properties,
+		NewPropertyString(p.Name),
+		NewPropertyString(p.Type),
+		NewPropertyString(p.Label),
+		NewPropertyString(p.Flags),
+	)
+	return NewNode("P", append(properties, p.Values...), nil, nil)
+}
+
+// Kind works out what the entry's values hold, going by it's type for enums
+// and colors and by the values themselves for everything else
+func (p *Property70) Kind() Property70Kind {
+	if p.Type == "enum" {
+		return Property70Enum
+	}
+	if strings.HasPrefix(p.Type, "Color") {
+		return Property70Color
+	}
+	if len(p.Values) == 1 && p.Values[0].TypeCode == 'S' {
+		return Property70String
+	}
+
+	numbers := p.Numbers()
+	if len(numbers) != len(p.Values) {
+		return Property70Unknown
+	}
+	switch len(numbers) {
+	case 1:
+		switch p.Values[0].TypeCode {
+		case 'D', 'F':
+			return Property70Double
+		}
+		return Property70Int
+	case 3:
+		return Property70Vector3
+	}
+	return Property70Unknown
+}
+
+// Animatable is whether the property can be animated
+func (p *Property70) Animatable() bool {
+	return strings.Contains(p.Flags, "A")
+}
+
+// Animated is whether the property has animation curves driving it
+func (p *Property70) Animated() bool {
+	return strings.Contains(p.Flags, "+")
+}
+
+// UserDefined is whether the property was added by the user, like a custom
+// attribute, rather than being one every object of the class has
+func (p *Property70) UserDefined() bool {
+	return strings.Contains(p.Flags, "U")
+}
+
+// Numbers are every numeric value of the entry, skipping anything that
+// isn't a number
+func (p *Property70) Numbers() []float64 {
+	numbers := make([]float64, 0, len(p.Values))
+	for _, prop := range p.Values {
+		switch prop.TypeCode {
+		case 'D':
+			numbers = append(numbers, prop.AsFloat64())
+		case 'F':
+			numbers = append(numbers, float64(prop.AsFloat32()))
+		case 'I':
+			numbers = append(numbers, float64(prop.AsInt32()))
+		case 'L':
+			numbers = append(numbers, float64(prop.AsInt64()))
+		case 'Y':
+			numbers = append(numbers, float64(prop.AsInt16()))
+		case 'C':
+			numbers = append(numbers, float64(prop.AsInt8()))
+		}
+	}
+	return numbers
+}
+
+// Float64 is the first number the entry holds, or 0 if it has none
+func (p *Property70) Float64() float64 {
+	if numbers := p.Numbers(); len(numbers) > 0 {
+		return numbers[0]
+	}
+	return 0
+}
+
+// Int is the first number the entry holds as an integer, which is what
+// enums and bools are stored as
+func (p *Property70) Int() int64 {
+	return int64(p.Float64())
+}
+
+// Vector3 is the first three numbers the entry holds
+func (p *Property70) Vector3() (vector.Vector3, bool) {
+	numbers := p.Numbers()
+	if len(numbers) < 3 {
+		return vector.Vector3Zero(), false
+	}
+	return vector.NewVector3(numbers[0], numbers[1], numbers[2]), true
+}
+
+// Color is the red, green and blue the entry holds
+func (p *Property70) Color() ([3]float64, bool) {
+	numbers := p.Numbers()
+	if len(numbers) < 3 {
+		return [3]float64{}, false
+	}
+	return [3]float64{numbers[0], numbers[1], numbers[2]}, true
+}
+
+// Text is the string the entry holds, or an empty one if it doesn't hold one
+func (p *Property70) Text() string {
+	for _, prop := range p.Values {
+		if prop.TypeCode == 'S' {
+			return prop.AsString()
+		}
+	}
+	return ""
+}
+
+// Properties70 is every entry of an object's Properties70 section, keyed by
+// name. Changes to it are made as diffs against the section, or against the
+// object when it doesn't have one yet.
+type Properties70 struct {
+	owner   *Node
+	node    *Node
+	entries map[string]*Property70
+	names   []string
+}
+
+// NewProperties70 reads the Properties70 section of the node, which is
+// usually an object or GlobalSettings. Entries that aren't laid out like
+// P entries are skipped.
+func NewProperties70(n *Node) *Properties70 {
+	props := &Properties70{
+		owner:   n,
+		entries: make(map[string]*Property70),
+		names:   make([]string, 0),
+	}
+	for _, child := range n.NestedNodes {
+		if child != nil && child.Name == "Properties70" {
+			props.node = child
+			break
+		}
+	}
+	if props.node == nil {
+		return props
+	}
+
+	for _, entry := range props.node.NestedNodes {
+		p, ok := readProperty70(entry)
+		if ok == false {
+			continue
+		}
+		if _, ok := props.entries[p.Name]; ok == false {
+			props.names = append(props.names, p.Name)
+		}
+		props.entries[p.Name] = p
+	}
+	return props
+}
+
+// Names are the names of every entry in the order they're found in the file
+func (props *Properties70) Names() []string {
+	return props.names
+}
+
+// Get finds the entry with the name
+func (props *Properties70) Get(name string) (*Property70, bool) {
+	p, ok := props.entries[name]
+	return p, ok
+}
+
+// Numbers are the numeric values of the entry with the name
+func (props *Properties70) Numbers(name string) ([]float64, bool) {
+	p, ok := props.entries[name]
+	if ok == false {
+		return nil, false
+	}
+	return p.Numbers(), true
+}
+
+// Float64Or is the number held by the entry with the name, or the fallback
+// if there isn't one
+func (props *Properties70) Float64Or(name string, fallback float64) float64 {
+	if v, ok := props.Numbers(name); ok && len(v) > 0 {
+		return v[0]
+	}
+	return fallback
+}
+
+// Vector3Or is the vector held by the entry with the name, or the fallback
+// if there isn't one
+func (props *Properties70) Vector3Or(name string, fallback vector.Vector3) vector.Vector3 {
+	if p, ok := props.entries[name]; ok {
+		if v, ok := p.Vector3(); ok {
+			return v
+		}
+	}
+	return fallback
+}
+
+// ColorOr is the color held by the entry with the name, or the fallback if
+// there isn't one
+func (props *Properties70) ColorOr(name string, fallback [3]float64) [3]float64 {
+	if p, ok := props.entries[name]; ok {
+		if c, ok := p.Color(); ok {
+			return c
+		}
+	}
+	return fallback
+}
+
+// Set creates a diff replacing the entry with the same name, or adding it if
+// there isn't one yet
+func (props *Properties70) Set(p *Property70) Diff {
+	if props.node != nil {
+		return NewProperty70Diff(props.node.id, p)
+	}
+	return NewProperty70Diff(props.owner.id, p)
+}
+
+// setValues creates a diff changing the values of the entry with the name,
+// keeping it's type, label and flags if it already exists
+func (props *Properties70) setValues(name, propertyType, label, flags string, values ...*Property) Diff {
+	if p, ok := props.entries[name]; ok {
+		propertyType, label, flags = p.Type, p.Label, p.Flags
+	}
+	return props.Set(NewProperty70(name, propertyType, label, flags, values...))
+}
+
+// SetFloat64 creates a diff changing the number held by the entry
+func (props *Properties70) SetFloat64(name string, v float64) Diff {
+	return props.setValues(name, "double", "Number", "", NewPropertyFloat64(v))
+}
+
+// SetInt creates a diff changing the integer held by the entry
+func (props *Properties70) SetInt(name string, v int32) Diff {
+	return props.setValues(name, "int", "Integer", "", NewPropertyInt32(v))
+}
+
+// SetEnum creates a diff changing which option of an enum the entry holds
+func (props *Properties70) SetEnum(name string, v int32) Diff {
+	return props.setValues(name, "enum", "", "", NewPropertyInt32(v))
+}
+
+// SetString creates a diff changing the string held by the entry
+func (props *Properties70) SetString(name string, v string) Diff {
+	return props.setValues(name, "KString", "", "", NewPropertyString(v))
+}
+
+// SetVector3 creates a diff changing the vector held by the entry. New
+// transform entries are typed after their name the way FBX expects, and
+// anything else is a plain Vector3D.
+func (props *Properties70) SetVector3(name string, v vector.Vector3) Diff {
+	propertyType, label := "Vector3D", "Vector"
+	switch name {
+	case "Lcl Translation", "Lcl Rotation", "Lcl Scaling":
+		propertyType, label = name, ""
+	}
+	return props.setValues(
+		name, propertyType, label, "A",
+		NewPropertyFloat64(v.X()), NewPropertyFloat64(v.Y()), NewPropertyFloat64(v.Z()),
+	)
+}
+
+// SetColor creates a diff changing the color held by the entry
+func (props *Properties70) SetColor(name string, c [3]float64) Diff {
+	return props.setValues(
+		name, "Color", "", "A",
+		NewPropertyFloat64(c[0]), NewPropertyFloat64(c[1]), NewPropertyFloat64(c[2]),
+	)
+}
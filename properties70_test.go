@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// patchAndReadBack writes the FBX out with the diffs applied, checking every
+// offset along the way, and reads it back in
+func patchAndReadBack(t *testing.T, fbx *FBX, diffs []Diff) *FBX {
+	sort.Sort(SortDiff(diffs))
+	pw := NewPatchWriter(fbx, diffs, func(int, error) {})
+	pw.Verify = true
+
+	buffer := new(bytes.Buffer)
+	_, err := pw.Write(buffer)
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	patched, err := ReadFrom(bytes.NewReader(buffer.Bytes()))
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	return patched
+}
+
+func TestProperties70ParsesEveryKind(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	model := newObjectNode(
+		"Model", 1, "Cube", "Mesh",
+		NewNodeParent(
+			"Properties70",
+			newIntPNode("DefaultAttributeIndex", 0),
+			NewProperty70("InheritType", "enum", "", "", NewPropertyInt32(1)).node(),
+			newDoublePNode("Visibility", 0.5),
+			newVector3PNode("Lcl Translation", vector.NewVector3(1, 2, 3)),
+			newColorPNode("Color", [3]float64{0.25, 0.5, 1}),
+			NewProperty70("Notes", "KString", "", "A+U", NewPropertyString("hello")).node(),
+			NewProperty70("Broken", "int", "", "").node(),
+			NewNodeString("P", "not an entry"),
+		),
+	)
+
+	// ******************************** ACT ***********************************
+	props := NewProperties70(model)
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, []string{
+		"DefaultAttributeIndex", "InheritType", "Visibility", "Lcl Translation", "Color", "Notes", "Broken",
+	}, props.Names())
+
+	kinds := make([]Property70Kind, 0)
+	for _, name := range props.Names() {
+		p, _ := props.Get(name)
+		kinds = append(kinds, p.Kind())
+	}
+	assert.Equal(t, []Property70Kind{
+		Property70Int, Property70Enum, Property70Double, Property70Vector3, Property70Color, Property70String, Property70Unknown,
+	}, kinds)
+
+	enum, _ := props.Get("InheritType")
+	assert.Equal(t, int64(1), enum.Int())
+
+	translation, _ := props.Get("Lcl Translation")
+	assert.True(t, translation.Animatable())
+	assert.False(t, translation.Animated())
+	assert.False(t, translation.UserDefined())
+	assert.Equal(t, vector.NewVector3(1, 2, 3), props.Vector3Or("Lcl Translation", vector.Vector3Zero()))
+	assert.Equal(t, vector.NewVector3(1, 1, 1), props.Vector3Or("Lcl Scaling", vector.NewVector3(1, 1, 1)))
+
+	color, _ := props.Get("Color")
+	c, ok := color.Color()
+	assert.True(t, ok)
+	assert.Equal(t, [3]float64{0.25, 0.5, 1}, c)
+	assert.Equal(t, c, props.ColorOr("Color", [3]float64{}))
+	assert.Equal(t, [3]float64{1, 1, 1}, props.ColorOr("Missing", [3]float64{1, 1, 1}))
+
+	notes, _ := props.Get("Notes")
+	assert.True(t, notes.UserDefined())
+	assert.Equal(t, "hello", notes.Text())
+
+	assert.Equal(t, 0.5, props.Float64Or("Visibility", 1))
+	assert.Equal(t, 1., props.Float64Or("Missing", 1))
+	_, ok = props.Get("Missing")
+	assert.False(t, ok)
+}
+
+func TestProperties70ChangesBecomeDiffs(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newObjectNode(
+				"Model", 1, "Cube", "Mesh",
+				NewNodeInt32("Version", 232),
+				NewNodeParent(
+					"Properties70",
+					newVector3PNode("Lcl Translation", vector.NewVector3(1, 2, 3)),
+					newDoublePNode("Visibility", 1),
+				),
+			),
+			newObjectNode("Deformer", 2, "Skin", "Skin"),
+		),
+	)
+	scene := NewScene(fbx)
+	model := Model{scene.Models()[0].Object}
+	skin, _ := scene.Object(2)
+	props := model.Properties()
+
+	// ******************************** ACT ***********************************
+	patched := patchAndReadBack(t, fbx, []Diff{
+		model.SetLocalTranslation(vector.NewVector3(4, 5, 6)),
+		props.SetFloat64("Visibility", 0),
+		props.Set(NewProperty70("Health", "int", "Integer", "A+U", NewPropertyInt32(100))),
+		skin.Properties().SetString("Notes", "added"),
+	})
+
+	// ******************************* ASSERT *********************************
+	patchedScene := NewScene(patched)
+	patchedProps := patchedScene.Models()[0].Properties()
+	assert.Equal(t, []string{"Lcl Translation", "Visibility", "Health"}, patchedProps.Names())
+	assert.Equal(t, vector.NewVector3(4, 5, 6), patchedScene.Models()[0].LocalTranslation())
+	assert.Equal(t, 0., patchedProps.Float64Or("Visibility", 1))
+
+	visibility, _ := patchedProps.Get("Visibility")
+	assert.Equal(t, "Number", visibility.Label)
+
+	translation, _ := patchedProps.Get("Lcl Translation")
+	assert.Equal(t, "Lcl Translation", translation.Type)
+
+	health, ok := patchedProps.Get("Health")
+	if assert.True(t, ok) {
+		assert.True(t, health.UserDefined())
+		assert.Equal(t, int64(100), health.Int())
+	}
+
+	patchedSkin, _ := patchedScene.Object(2)
+	notes, ok := patchedSkin.Properties().Get("Notes")
+	if assert.True(t, ok) {
+		assert.Equal(t, "added", notes.Text())
+	}
+}
+
+func TestProperties70SetsShareOneNewSection(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newObjectNode("Deformer", 1, "Skin", "Skin", NewNodeInt32("Version", 101)),
+			newObjectNode("Deformer", 2, "Blend", "BlendShape"),
+		),
+	)
+	scene := NewScene(fbx)
+	skin, _ := scene.Object(1)
+	blend, _ := scene.Object(2)
+
+	// ******************************** ACT ***********************************
+	patched := patchAndReadBack(t, fbx, []Diff{
+		skin.Properties().SetFloat64("A", 1),
+		skin.Properties().SetFloat64("B", 2),
+		blend.Properties().SetFloat64("A", 3),
+		blend.Properties().SetVector3("MyOffset", vector.NewVector3(1, 2, 3)),
+	})
+
+	// ******************************* ASSERT *********************************
+	for _, uid := range []int64{1, 2} {
+		object, _ := NewScene(patched).Object(uid)
+		assert.Len(t, object.Node().GetNodes("Properties70"), 1, "object %d", uid)
+	}
+
+	patchedSkin, _ := NewScene(patched).Object(1)
+	props := patchedSkin.Properties()
+	assert.Equal(t, []string{"A", "B"}, props.Names())
+	assert.Equal(t, 1., props.Float64Or("A", 0))
+	assert.Equal(t, 2., props.Float64Or("B", 0))
+
+	patchedBlend, _ := NewScene(patched).Object(2)
+	offset, ok := patchedBlend.Properties().Get("MyOffset")
+	if assert.True(t, ok) {
+		assert.Equal(t, "Vector3D", offset.Type)
+		assert.Equal(t, "Vector", offset.Label)
+		assert.Equal(t, Property70Vector3, offset.Kind())
+	}
+}
@@ -33,6 +33,16 @@ func NewPropertyInt64(p int64) *Property {
 	}
 }
 
+// NewPropertyFloat64 creates a property that holds data for a Float64
+func NewPropertyFloat64(p float64) *Property {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, p)
+	return &Property{
+		TypeCode: 'D',
+		Data:     buf.Bytes(),
+	}
+}
+
 // NewPropertyString creates a property that holds data for a string
 func NewPropertyString(s string) *Property {
 	return &Property{
@@ -0,0 +1,86 @@
+package main
+
+// Property70Diff sets an entry of a Properties70 section, replacing the entry
+// with the same name or adding it to the end. Made against an object, the
+// entry goes into the object's Properties70 section, which is added if the
+// object doesn't have one yet.
+type Property70Diff struct {
+	nodeID uint64
+	entry  *Property70
+}
+
+// NewProperty70Diff creates a new Properties70 entry diff
+func NewProperty70Diff(id uint64, entry *Property70) *Property70Diff {
+	return &Property70Diff{
+		nodeID: id,
+		entry:  entry,
+	}
+}
+
+// insertNested adds the child to the end of the node's nested nodes, keeping
+// any null record ending them last
+func insertNested(n *Node, child *Node) {
+	last := len(n.NestedNodes) - 1
+	if last < 0 {
+		n.NestedNodes = []*Node{child, {}}
+		return
+	}
+	if null := n.NestedNodes[last]; null != nil && null.Length == 0 {
+		n.NestedNodes = append(n.NestedNodes[:last], child, null)
+		return
+	}
+	n.NestedNodes = append(n.NestedNodes, child)
+}
+
+// Apply sets the entry if the node's id matches
+func (d Property70Diff) Apply(n *Node) (*Node, bool) {
+	if n.id != d.nodeID {
+		return n, false
+	}
+
+	if n.Name == "Properties70" {
+		return d.set(n), true
+	}
+
+	// Earlier diffs against the same object may have already added a
+	// section, so the entry goes into that one rather than a new one
+	patchedNode := n.ShallowCopy()
+	for i, nested := range patchedNode.NestedNodes {
+		if nested != nil && nested.Name == "Properties70" {
+			patchedNode.NestedNodes[i] = d.set(nested)
+			return patchedNode, true
+		}
+	}
+
+	section := NewNodeParent("Properties70", d.entry.node())
+	addNullRecords(section)
+	insertNested(patchedNode, section)
+	return patchedNode, true
+}
+
+// set returns a copy of the Properties70 section with the entry set. The
+// copy's length is kept up to date, since a section nested under the node a
+// diff was made against doesn't get it's length recomputed.
+func (d Property70Diff) set(section *Node) *Node {
+	patched := section.ShallowCopy()
+
+	replaced := false
+	for i, nested := range patched.NestedNodes {
+		if p, ok := readProperty70(nested); ok && p.Name == d.entry.Name {
+			patched.NestedNodes[i] = d.entry.node()
+			replaced = true
+			break
+		}
+	}
+	if replaced == false {
+		insertNested(patched, d.entry.node())
+	}
+
+	patched.Length = nestedNodesLength(patched.NestedNodes) + patched.PropertyListLen + uint64(len(patched.Name)) + 25
+	return patched
+}
+
+// NodeID is the id of the node we want to apply the dif too
+func (d Property70Diff) NodeID() uint64 {
+	return d.nodeID
+}
@@ -6,6 +6,14 @@ type PropertyDiff struct {
 	property *Property
 }
 
+// NewPropertyDiff creates a new property diff
+func NewPropertyDiff(id uint64, prop *Property) *PropertyDiff {
+	return &PropertyDiff{
+		nodeID:   id,
+		property: prop,
+	}
+}
+
 // Apply will check the node for matching specific criteria and if it passes an
 // entirely new node will be created that contains the proper diff.
 func (d PropertyDiff) Apply(n *Node) (*Node, bool) {
@@ -6,14 +6,19 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 )
 
 // FBXReader builds an FBX file from a reader
 type FBXReader struct {
-	FBX                      *FBX
-	Position                 int64
-	Error                    error
-	Filters                  []NodeFilter
+	FBX      *FBX
+	Position int64
+	Error    error
+	Filters  []NodeFilter
+
+	// Batcher decides how large the jobs sent to the results channel are
+	Batcher *JobBatcher
+
 	stack                    *NodeStack
 	results                  chan<- []*Node
 	matcher                  NodeFilter
@@ -86,11 +91,21 @@ func (fr *FBXReader) ReadFrom(r io.ReadSeeker) (n int64, err error) {
 		}
 	}
 
-	// if fr.currentResultsBufferSize > 0 {
-	// 	fr.results <- fr.currentResultsBuffer
-	// }
+	// Everything after the last node is kept so it can be written back out
+	if fr.Error == nil {
+		footerOffset := uint64(fr.Position)
+		var footer []byte
+		footer, fr.Error = ioutil.ReadAll(r)
+		fr.Position += int64(len(footer))
+		fr.FBX.Footer = NewFooter(footer, footerOffset)
+	}
 
 	if fr.results != nil {
+		if len(fr.currentResultsBuffer) > 0 {
+			fr.results <- fr.currentResultsBuffer
+			fr.currentResultsBuffer = nil
+			fr.currentResultsBufferSize = 0
+		}
 		close(fr.results)
 	}
 
@@ -204,7 +219,7 @@ func (fr *FBXReader) addNodeToResultsChannel(n *Node, size int64) {
 	fr.currentResultsBufferSize += size
 	fr.currentResultsBuffer = append(fr.currentResultsBuffer, n)
 
-	if fr.currentResultsBufferSize > 1000000 {
+	if fr.currentResultsBufferSize > fr.Batcher.Size() {
 		fr.results <- fr.currentResultsBuffer
 		fr.currentResultsBufferSize = 0
 		fr.currentResultsBuffer = make([]*Node, 0)
@@ -297,6 +312,21 @@ func (fr *FBXReader) ReadPropertyFrom(r io.Reader, node *Node) {
 		node.ArrayProperties = append(node.ArrayProperties, arrayProp)
 	}
 
+	// Properties are written arrays first unless told otherwise, so the order
+	// only needs to be kept once an array comes after something that isn't
+	if node.arrayOrder != nil {
+		node.arrayOrder = append(node.arrayOrder, arrayProp != nil)
+	} else if arrayProp != nil && len(node.Properties) > 0 {
+		node.arrayOrder = make([]bool, 0, node.NumProperties)
+		for range node.ArrayProperties[1:] {
+			node.arrayOrder = append(node.arrayOrder, true)
+		}
+		for range node.Properties {
+			node.arrayOrder = append(node.arrayOrder, false)
+		}
+		node.arrayOrder = append(node.arrayOrder, true)
+	}
+
 }
 
 func (fr *FBXReader) readArrayHeader(r io.Reader, a *ArrayProperty) {
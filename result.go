@@ -1,6 +1,12 @@
 package main
 
+// WorkerResult is the sorted diffs from splitting a single job, numbered by
+// the order the job was read in
 type WorkerResult struct {
+	job      int
 	clipped  []Diff
 	retained []Diff
+
+	// through is the id of the last node under the job's last geometry
+	through uint64
 }
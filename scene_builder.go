@@ -0,0 +1,351 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/EliCDavis/vector"
+)
+
+// SceneOptions controls the file a SceneBuilder puts together
+type SceneOptions struct {
+	// Version of the FBX file. Anything before 7500 is written with 32 bit
+	// offsets.
+	Version uint32
+
+	// CreationTime is recorded in the header of the file
+	CreationTime time.Time
+
+	// AxisSystem is written to GlobalSettings, and is what everything added
+	// to the scene is in
+	AxisSystem AxisSystem
+
+	// Compress array properties with zlib
+	Compress bool
+}
+
+// DefaultSceneOptions is a compressed version 7500 file, Y up and in
+// centimeters
+func DefaultSceneOptions() SceneOptions {
+	return SceneOptions{
+		Version:      7500,
+		CreationTime: time.Now(),
+		AxisSystem:   DefaultAxisSystem(),
+		Compress:     true,
+	}
+}
+
+// SceneMesh is the geometry of a polygon mesh to add to a scene, along with
+// any of the optional layer elements
+type SceneMesh struct {
+	Name string
+
+	// Vertices are x, y, z positions one after another
+	Vertices []float64
+
+	// PolygonVertexIndex indexes into the vertices, with the last index of
+	// every polygon stored as -(index + 1)
+	PolygonVertexIndex []int32
+
+	// Normals are an x, y, z direction for every polygon vertex
+	Normals []float64
+
+	// UVs are u, v coordinates, with UVIndex picking which one each polygon
+	// vertex uses
+	UVs     []float64
+	UVIndex []int32
+
+	// Materials picks a material for every polygon, indexing into the
+	// materials connected to the model the geometry is attached to, in the
+	// order they were connected
+	Materials []int32
+}
+
+// SceneBuilder puts together a complete FBX from scratch. Objects added to it
+// are given UIDs and counted up in Definitions, and GlobalSettings, Documents
+// and Connections are filled out, so the file it builds can be imported
+// directly.
+type SceneBuilder struct {
+	options     SceneOptions
+	nextUID     int64
+	objects     []*Node
+	classes     []string
+	counts      map[string]int
+	connections []*Node
+}
+
+// NewSceneBuilder creates a builder for an empty scene
+func NewSceneBuilder(options SceneOptions) *SceneBuilder {
+	return &SceneBuilder{
+		options:     options,
+		nextUID:     firstStreamedUID,
+		objects:     make([]*Node, 0),
+		classes:     make([]string, 0),
+		counts:      make(map[string]int),
+		connections: make([]*Node, 0),
+	}
+}
+
+// sceneGlobalSettings creates the GlobalSettings section describing the axis
+// system
+func sceneGlobalSettings(axis AxisSystem) *Node {
+	return NewNodeParent(
+		"GlobalSettings",
+		NewNodeInt32("Version", 1000),
+		NewNodeParent(
+			"Properties70",
+			newIntPNode("UpAxis", int32(axis.UpAxis)),
+			newIntPNode("UpAxisSign", int32(axis.UpAxisSign)),
+			newIntPNode("FrontAxis", int32(axis.FrontAxis)),
+			newIntPNode("FrontAxisSign", int32(axis.FrontAxisSign)),
+			newIntPNode("CoordAxis", int32(axis.CoordAxis)),
+			newIntPNode("CoordAxisSign", int32(axis.CoordAxisSign)),
+			newDoublePNode("UnitScaleFactor", axis.UnitScaleFactor),
+		),
+	)
+}
+
+// sceneDocuments creates the Documents section, with a single scene whose
+// root everything is attached to
+func sceneDocuments(uid int64) *Node {
+	return NewNodeParent(
+		"Documents",
+		NewNodeInt32("Count", 1),
+		NewNode(
+			"Document",
+			[]*Property{NewPropertyInt64(uid), NewPropertyString(""), NewPropertyString("Scene")},
+			nil,
+			[]*Node{
+				NewNodeParent(
+					"Properties70",
+					NewNode("P", []*Property{
+						NewPropertyString("SourceObject"),
+						NewPropertyString("object"),
+						NewPropertyString(""),
+						NewPropertyString(""),
+					}, nil, nil),
+					NewNode("P", []*Property{
+						NewPropertyString("ActiveAnimStackName"),
+						NewPropertyString("KString"),
+						NewPropertyString(""),
+						NewPropertyString(""),
+						NewPropertyString(""),
+					}, nil, nil),
+				),
+				NewNodeInt64("RootNode", 0),
+			},
+		),
+	)
+}
+
+// sceneDefinitions creates the Definitions section, counting up the objects
+// of each class in the order given
+func sceneDefinitions(classes []string, counts map[string]int) *Node {
+	total := 0
+	types := make([]*Node, 0, len(classes)+2)
+	for _, class := range classes {
+		// Types without any objects are left out, same as
+		// UpdateDefinitionCounts does
+		if counts[class] == 0 {
+			continue
+		}
+		total += counts[class]
+		types = append(types, NewNode("ObjectType", []*Property{NewPropertyString(class)}, nil, []*Node{
+			NewNodeInt32("Count", int32(counts[class])),
+		}))
+	}
+	return NewNodeParent(
+		"Definitions",
+		append([]*Node{NewNodeInt32("Version", 100), NewNodeInt32("Count", int32(total))}, types...)...,
+	)
+}
+
+func sceneFloat64Slice(name string, values []float64, compress bool) *Node {
+	if compress {
+		return NewNodeSingleArrayProperty(name, NewArrayPropertyFloat64CompressedSlice(values))
+	}
+	return NewNodeFloat64Slice(name, values)
+}
+
+func sceneInt32Slice(name string, values []int32, compress bool) *Node {
+	if compress {
+		return NewNodeSingleArrayProperty(name, NewArrayPropertyInt32CompressedSlice(values))
+	}
+	return NewNodeInt32Slice(name, values)
+}
+
+// sceneMeshChildren are the children of the mesh's geometry, with a layer
+// element for every optional part of it that's filled out
+func sceneMeshChildren(mesh SceneMesh, compress bool) []*Node {
+	children := []*Node{
+		NewNodeInt32("GeometryVersion", 124),
+		sceneFloat64Slice("Vertices", mesh.Vertices, compress),
+		sceneInt32Slice("PolygonVertexIndex", mesh.PolygonVertexIndex, compress),
+	}
+	layer := []*Node{NewNodeInt32("Version", 100)}
+	addLayerElement := func(element *Node) {
+		children = append(children, element)
+		layer = append(layer, NewNodeParent(
+			"LayerElement",
+			NewNodeString("Type", element.Name),
+			NewNodeInt32("TypedIndex", 0),
+		))
+	}
+
+	if mesh.Normals != nil {
+		addLayerElement(newLayerElementNode(
+			"LayerElementNormal", "ByPolygonVertex", "Direct",
+			sceneFloat64Slice("Normals", mesh.Normals, compress),
+		))
+	}
+
+	if mesh.UVs != nil {
+		addLayerElement(newLayerElementNode(
+			"LayerElementUV", "ByPolygonVertex", "IndexToDirect",
+			sceneFloat64Slice("UV", mesh.UVs, compress),
+			sceneInt32Slice("UVIndex", mesh.UVIndex, compress),
+		))
+	}
+
+	if mesh.Materials != nil {
+		addLayerElement(newLayerElementNode(
+			"LayerElementMaterial", "ByPolygon", "IndexToDirect",
+			sceneInt32Slice("Materials", mesh.Materials, compress),
+		))
+	}
+
+	if len(layer) > 1 {
+		children = append(children, NewNode("Layer", []*Property{NewPropertyInt32(0)}, nil, layer))
+	}
+
+	return children
+}
+
+// sceneModelChildren are the children of a mesh model placed at the
+// translation
+func sceneModelChildren(translation vector.Vector3) []*Node {
+	return []*Node{
+		NewNodeInt32("Version", 232),
+		NewNodeParent(
+			"Properties70",
+			newVector3PNode("Lcl Translation", translation),
+		),
+	}
+}
+
+// sceneMaterialChildren are the children of a lambert material
+func sceneMaterialChildren(diffuse [3]float64, opacity float64) []*Node {
+	return []*Node{
+		NewNodeInt32("Version", 102),
+		NewNodeString("ShadingModel", "lambert"),
+		NewNodeParent(
+			"Properties70",
+			newColorPNode("DiffuseColor", diffuse),
+			newDoublePNode("Opacity", opacity),
+		),
+	}
+}
+
+// AddObject adds an object of any class to Objects, returning the UID it was
+// given
+func (b *SceneBuilder) AddObject(class, name, subclass string, children ...*Node) int64 {
+	uid := b.nextUID
+	b.nextUID++
+
+	if _, ok := b.counts[class]; ok == false {
+		b.classes = append(b.classes, class)
+	}
+	b.counts[class]++
+
+	b.objects = append(b.objects, newObjectNode(class, uid, name, subclass, children...))
+	return uid
+}
+
+// AddMesh adds the mesh's geometry, returning it's UID. Geometry shows up
+// once it's connected to a model.
+func (b *SceneBuilder) AddMesh(mesh SceneMesh) int64 {
+	return b.AddObject("Geometry", mesh.Name, "Mesh", sceneMeshChildren(mesh, b.options.Compress)...)
+}
+
+// AddModel adds a mesh model placed at the translation and attached to the
+// parent, which is 0 for the root of the scene, returning it's UID
+func (b *SceneBuilder) AddModel(name string, translation vector.Vector3, parent int64) int64 {
+	uid := b.AddObject("Model", name, "Mesh", sceneModelChildren(translation)...)
+	b.Connect(uid, parent)
+	return uid
+}
+
+// AddMaterial adds a lambert material, returning it's UID
+func (b *SceneBuilder) AddMaterial(name string, diffuse [3]float64, opacity float64) int64 {
+	return b.AddObject("Material", name, "", sceneMaterialChildren(diffuse, opacity)...)
+}
+
+// AddTexture adds a texture of the image file, connecting it to the
+// material's property, returning it's UID
+func (b *SceneBuilder) AddTexture(name, fileName string, material int64, property string) int64 {
+	uid := b.AddObject(
+		"Texture", name, "",
+		NewNodeString("FileName", fileName),
+		NewNodeString("RelativeFilename", fileName),
+	)
+	b.ConnectProperty(uid, material, property)
+	return uid
+}
+
+// Connect attaches the child object to the parent object
+func (b *SceneBuilder) Connect(child, parent int64) {
+	b.connections = append(b.connections, newConnectionNode("OO", child, parent))
+}
+
+// ConnectProperty attaches the child object to one of the parent object's
+// properties
+func (b *SceneBuilder) ConnectProperty(child, parent int64, property string) {
+	b.connections = append(b.connections, newConnectionNode("OP", child, parent, property))
+}
+
+// Build puts together the FBX, with every node numbered so diffs can be made
+// against it
+func (b *SceneBuilder) Build() *FBX {
+	classes := append([]string{"GlobalSettings"}, b.classes...)
+	counts := map[string]int{"GlobalSettings": 1}
+	for class, count := range b.counts {
+		counts[class] = count
+	}
+
+	sections := []*Node{
+		sceneGlobalSettings(b.options.AxisSystem),
+		sceneDocuments(b.nextUID),
+		sceneDefinitions(classes, counts),
+		NewNodeParent("Objects", b.objects...),
+		NewNodeParent("Connections", b.connections...),
+	}
+	for _, n := range sections {
+		addNullRecords(n)
+	}
+
+	header := headerNodes(b.options.CreationTime, b.options.Version)
+	nodes := make([]*Node, 0, len(header)+len(sections))
+	nodes = append(nodes, header[1:]...)
+	nodes = append(nodes, sections...)
+
+	// Every file ends with an empty node
+	nodes = append(nodes, &Node{})
+
+	id := header[0].number(0)
+	for _, n := range nodes {
+		id = n.number(id)
+	}
+
+	return &FBX{
+		Header: NewHeader(binaryHeader(b.options.Version)),
+		Top:    header[0],
+		Nodes:  nodes,
+	}
+}
+
+// WriteTo builds the FBX and writes it out, returning how many bytes were
+// written
+func (b *SceneBuilder) WriteTo(w io.Writer) (int64, error) {
+	n, err := NewPatchWriter(b.Build(), nil, func(int, error) {}).Write(w)
+	return int64(n), err
+}
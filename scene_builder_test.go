@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSceneBuilderWritesAnImportableFile(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		options := DefaultSceneOptions()
+		options.Version = version
+		options.AxisSystem = AxisSystem{
+			UpAxis: 2, UpAxisSign: 1,
+			FrontAxis: 1, FrontAxisSign: -1,
+			CoordAxis: 0, CoordAxisSign: 1,
+			UnitScaleFactor: 100,
+		}
+		scene := NewSceneBuilder(options)
+
+		material := scene.AddMaterial("Red", [3]float64{1, 0, 0}, 1)
+		texture := scene.AddTexture("Bricks", "bricks.png", material, "DiffuseColor")
+		geometry := scene.AddMesh(SceneMesh{
+			Name:               "Quad",
+			Vertices:           []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0},
+			PolygonVertexIndex: []int32{0, 1, WrapToIndex(2), 0, 2, WrapToIndex(3)},
+			Normals:            []float64{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1},
+			UVs:                []float64{0, 0, 1, 0, 1, 1, 0, 1},
+			UVIndex:            []int32{0, 1, 2, 0, 2, 3},
+			Materials:          []int32{0, 0},
+		})
+		model := scene.AddModel("Quad", vector.NewVector3(5, 6, 7), 0)
+		scene.Connect(geometry, model)
+		scene.Connect(material, model)
+
+		// ******************************** ACT ***********************************
+		buffer := new(bytes.Buffer)
+		written, writeErr := scene.WriteTo(buffer)
+		fbx, readErr := ReadFrom(bytes.NewReader(buffer.Bytes()))
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, writeErr)
+		assert.NoError(t, readErr)
+		assert.Equal(t, int64(buffer.Len()), written)
+		assert.Equal(t, version, fbx.Header.Version())
+		assert.Equal(t, version, fbx.Footer.Version())
+		assert.Equal(t, options.AxisSystem, NewAxisSystem(fbx))
+
+		if documents := fbx.GetNodes("Documents", "Document"); assert.Len(t, documents, 1) {
+			root := documents[0].GetNodes("RootNode")
+			if assert.Len(t, root, 1) {
+				assert.Equal(t, int64(0), root[0].Properties[0].AsInt64())
+			}
+		}
+
+		definitions := fbx.GetNodes("Definitions")
+		if assert.Len(t, definitions, 1) {
+			counts := map[string]int32{}
+			for _, objectType := range definitions[0].GetNodes("ObjectType") {
+				counts[objectType.Properties[0].AsString()] = objectType.GetNodes("Count")[0].Properties[0].AsInt32()
+			}
+			assert.Equal(t, map[string]int32{"GlobalSettings": 1, "Material": 1, "Texture": 1, "Geometry": 1, "Model": 1}, counts)
+		}
+
+		graph := NewConnectionGraph(fbx)
+		assert.Equal(t, []int64{0}, graph.ParentIDs(model))
+		assert.Equal(t, []int64{model}, graph.ParentIDs(geometry))
+		assert.Equal(t, []int64{material}, graph.ParentIDs(texture, ConnectionProperty("DiffuseColor")))
+
+		objects := fbx.GetNodes("Objects", "Geometry")
+		if assert.Len(t, objects, 1) {
+			mesh, ok := decodeMeshGeometry(objects[0])
+			assert.True(t, ok)
+			assert.Len(t, mesh.polygonVertexIndex, 6)
+			assert.Len(t, mesh.materials, 2)
+		}
+
+		world, ok := NewSceneTransforms(fbx, graph).GeometryWorld(geometry)
+		assert.True(t, ok)
+		assert.Equal(t, vector.NewVector3(5, 6, 7), world.MultiplyPoint(vector.Vector3Zero()))
+	}
+}
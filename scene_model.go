@@ -0,0 +1,532 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/EliCDavis/vector"
+)
+
+// Object is anything found directly under the Objects section. Everything
+// about it is decoded from it's node as it's asked for, and changes to it are
+// made as diffs against the node.
+type Object struct {
+	node *Node
+}
+
+// Node is the node the object is decoded from
+func (o Object) Node() *Node {
+	return o.node
+}
+
+// UID is the unique id other objects refer to the object by, or 0 if it
+// doesn't have one
+func (o Object) UID() int64 {
+	if len(o.node.Properties) == 0 || o.node.Properties[0].TypeCode != 'L' {
+		return 0
+	}
+	return o.node.Properties[0].AsInt64()
+}
+
+// Name of the object, without the class FBX tacks on to the end of it
+func (o Object) Name() string {
+	return objectName(o.node)
+}
+
+// Class is the kind of object it is, like Geometry or Model
+func (o Object) Class() string {
+	return o.node.Name
+}
+
+// Subclass narrows down what kind of object it is, like Mesh or Shape for
+// geometry
+func (o Object) Subclass() string {
+	if len(o.node.Properties) < 3 || o.node.Properties[2].TypeCode != 'S' {
+		return ""
+	}
+	return o.node.Properties[2].AsString()
+}
+
+// Properties are the entries of the object's Properties70 section
+func (o Object) Properties() *Properties70 {
+	return NewProperties70(o.node)
+}
+
+// SetName creates a diff renaming the object
+func (o Object) SetName(name string) Diff {
+	return NewPropertyDiff(o.node.id, NewPropertyString(name+"\x00\x01"+o.Class()))
+}
+
+// Delete creates a diff removing the object from the file. Anything connected
+// to it is left as is.
+func (o Object) Delete() Diff {
+	return NewDeleteNodeDiff(o.node.id)
+}
+
+// child finds the first node directly under the object with the name
+func (o Object) child(name string) *Node {
+	for _, n := range o.node.NestedNodes {
+		if n != nil && n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// stringChild is the string held by the first node with the name directly
+// under the object
+func (o Object) stringChild(name string) string {
+	if n := o.child(name); n != nil {
+		s, _ := n.StringProperty()
+		return s
+	}
+	return ""
+}
+
+// arrayDiff creates a diff replacing the array held by the node, keeping it
+// compressed if it already was
+func arrayDiff(n *Node, compressed func() *ArrayProperty, uncompressed func() *ArrayProperty) Diff {
+	if n == nil {
+		return nil
+	}
+	if len(n.ArrayProperties) > 0 && n.ArrayProperties[0].Encoding == 1 {
+		return NewArrayPropertyDiff(n.id, compressed())
+	}
+	return NewArrayPropertyDiff(n.id, uncompressed())
+}
+
+// Geometry is the shape of something in the scene, which can be a polygon
+// mesh, a blend shape target or a NURBS curve or surface
+type Geometry struct {
+	Object
+}
+
+// IsMesh is whether the geometry is a polygon mesh
+func (g Geometry) IsMesh() bool {
+	return g.Subclass() == "Mesh"
+}
+
+// IsShape is whether the geometry is a blend shape target, which only holds
+// the vertices it moves
+func (g Geometry) IsShape() bool {
+	return g.Subclass() == "Shape"
+}
+
+// IsNurbs is whether the geometry is a NURBS curve or surface
+func (g Geometry) IsNurbs() bool {
+	return strings.HasPrefix(g.Subclass(), "Nurbs")
+}
+
+// Vertices are the x, y, z positions of the geometry's control points. Blend
+// shapes hold offsets for only the vertices listed in Indexes.
+func (g Geometry) Vertices() []float64 {
+	if n := g.child("Vertices"); n != nil {
+		v, _ := n.Float64Slice()
+		return v
+	}
+	return nil
+}
+
+// Indexes are the vertices of the mesh a blend shape moves
+func (g Geometry) Indexes() []int32 {
+	if n := g.child("Indexes"); n != nil {
+		i, _ := n.Int32Slice()
+		return i
+	}
+	return nil
+}
+
+// PolygonVertexIndex indexes into the vertices, with the last index of
+// every polygon stored as -(index + 1)
+func (g Geometry) PolygonVertexIndex() []int32 {
+	if n := g.child("PolygonVertexIndex"); n != nil {
+		i, _ := n.Int32Slice()
+		return i
+	}
+	return nil
+}
+
+// Polygons are the vertices of every polygon of a mesh
+func (g Geometry) Polygons() [][]int32 {
+	polygons := make([][]int32, 0)
+	start := 0
+	index := g.PolygonVertexIndex()
+	for i, v := range index {
+		if v >= 0 {
+			continue
+		}
+		polygon := make([]int32, i-start+1)
+		copy(polygon, index[start:i])
+		polygon[len(polygon)-1] = WrapToIndex(v)
+		polygons = append(polygons, polygon)
+		start = i + 1
+	}
+	return polygons
+}
+
+// Layers are the layer elements of the geometry, like normals, UVs and
+// materials
+func (g Geometry) Layers() []GeometryLayer {
+	layers := make([]GeometryLayer, 0)
+	for _, n := range g.node.NestedNodes {
+		if n != nil && strings.HasPrefix(n.Name, "LayerElement") {
+			layers = append(layers, GeometryLayer{node: n})
+		}
+	}
+	return layers
+}
+
+// Layer finds the first layer element of the type, like LayerElementNormal
+func (g Geometry) Layer(layerType string) (GeometryLayer, bool) {
+	if n := g.child(layerType); n != nil {
+		return GeometryLayer{node: n}, true
+	}
+	return GeometryLayer{}, false
+}
+
+// SetVertices creates a diff replacing the geometry's vertices
+func (g Geometry) SetVertices(vertices []float64) Diff {
+	return arrayDiff(
+		g.child("Vertices"),
+		func() *ArrayProperty { return NewArrayPropertyFloat64CompressedSlice(vertices) },
+		func() *ArrayProperty { return NewArrayPropertyFloat64Slice(vertices) },
+	)
+}
+
+// SetPolygonVertexIndex creates a diff replacing the polygons of a mesh
+func (g Geometry) SetPolygonVertexIndex(index []int32) Diff {
+	return arrayDiff(
+		g.child("PolygonVertexIndex"),
+		func() *ArrayProperty { return NewArrayPropertyInt32CompressedSlice(index) },
+		func() *ArrayProperty { return NewArrayPropertyInt32Slice(index) },
+	)
+}
+
+// GeometryLayer is a single layer element of some geometry, holding data like
+// normals or UVs and describing how it maps onto the geometry
+type GeometryLayer struct {
+	node *Node
+}
+
+// Type is what kind of layer element it is, like LayerElementNormal
+func (l GeometryLayer) Type() string {
+	return l.node.Name
+}
+
+func (l GeometryLayer) stringChild(name string) string {
+	return Object{node: l.node}.stringChild(name)
+}
+
+// Mapping is how the data maps onto the geometry, like ByPolygonVertex
+func (l GeometryLayer) Mapping() string {
+	return l.stringChild("MappingInformationType")
+}
+
+// Reference is whether the data is used directly or through an index
+func (l GeometryLayer) Reference() string {
+	return l.stringChild("ReferenceInformationType")
+}
+
+// Data is the first array of numbers in the layer, like the normals of a
+// LayerElementNormal. Integer data, like material indices, is converted.
+func (l GeometryLayer) Data() []float64 {
+	for _, n := range l.node.NestedNodes {
+		if n == nil || len(n.ArrayProperties) != 1 || strings.HasSuffix(n.Name, "Index") {
+			continue
+		}
+		p := n.ArrayProperties[0]
+		switch p.TypeCode {
+		case 'd':
+			return p.AsFloat64Slice()
+		case 'f':
+			data := make([]float64, 0, p.ArrayLength)
+			for _, v := range p.AsFloat32Slice() {
+				data = append(data, float64(v))
+			}
+			return data
+		case 'i':
+			data := make([]float64, 0, p.ArrayLength)
+			for _, v := range p.AsInt32Slice() {
+				data = append(data, float64(v))
+			}
+			return data
+		}
+	}
+	return nil
+}
+
+// Index picks which element of the data is used when the reference is
+// IndexToDirect
+func (l GeometryLayer) Index() []int32 {
+	for _, n := range l.node.NestedNodes {
+		if n != nil && strings.HasSuffix(n.Name, "Index") {
+			i, _ := n.Int32Slice()
+			return i
+		}
+	}
+	return nil
+}
+
+// Model is something placed in the scene, which geometry, materials and
+// other models are attached to
+type Model struct {
+	Object
+}
+
+// LocalTranslation is where the model is relative to it's parent
+func (m Model) LocalTranslation() vector.Vector3 {
+	return m.Properties().Vector3Or("Lcl Translation", vector.Vector3Zero())
+}
+
+// SetLocalTranslation creates a diff moving the model relative to it's
+// parent
+func (m Model) SetLocalTranslation(v vector.Vector3) Diff {
+	return m.Properties().SetVector3("Lcl Translation", v)
+}
+
+// LocalRotation is the model's euler angles in degrees relative to it's
+// parent
+func (m Model) LocalRotation() vector.Vector3 {
+	return m.Properties().Vector3Or("Lcl Rotation", vector.Vector3Zero())
+}
+
+// SetLocalRotation creates a diff changing the model's euler angles
+func (m Model) SetLocalRotation(v vector.Vector3) Diff {
+	return m.Properties().SetVector3("Lcl Rotation", v)
+}
+
+// LocalScaling is how the model is scaled relative to it's parent
+func (m Model) LocalScaling() vector.Vector3 {
+	return m.Properties().Vector3Or("Lcl Scaling", vector.NewVector3(1, 1, 1))
+}
+
+// SetLocalScaling creates a diff changing how the model is scaled
+func (m Model) SetLocalScaling(v vector.Vector3) Diff {
+	return m.Properties().SetVector3("Lcl Scaling", v)
+}
+
+// Transforms is the model's local transform, along with the geometric
+// transform that's only applied to geometry attached to it
+func (m Model) Transforms() (local Matrix4, geometric Matrix4) {
+	return ModelTransforms(m.node)
+}
+
+// Material describes how the surface of geometry looks
+type Material struct {
+	Object
+}
+
+// ShadingModel is how the material is lit, like lambert or phong
+func (m Material) ShadingModel() string {
+	return m.stringChild("ShadingModel")
+}
+
+// DiffuseColor is the base color of the material, falling back to the older
+// Diffuse entry
+func (m Material) DiffuseColor() [3]float64 {
+	props := m.Properties()
+	return props.ColorOr("DiffuseColor", props.ColorOr("Diffuse", [3]float64{0.8, 0.8, 0.8}))
+}
+
+// Opacity is how opaque the material is, from 0 to 1, falling back to how
+// transparent it is
+func (m Material) Opacity() float64 {
+	props := m.Properties()
+	return props.Float64Or("Opacity", 1-props.Float64Or("TransparencyFactor", 0))
+}
+
+// SetDiffuseColor creates a diff changing the base color of the material
+func (m Material) SetDiffuseColor(c [3]float64) Diff {
+	return m.Properties().SetColor("DiffuseColor", c)
+}
+
+// SetOpacity creates a diff changing how opaque the material is
+func (m Material) SetOpacity(opacity float64) Diff {
+	return m.Properties().SetFloat64("Opacity", opacity)
+}
+
+// Texture is an image applied to a material's property
+type Texture struct {
+	Object
+}
+
+// FileName is the path to the image, preferring the one relative to the FBX
+func (t Texture) FileName() string {
+	return textureFileName(t.node)
+}
+
+// Deformer changes the shape of geometry, like a skin or a blend shape. Skins
+// are made up of clusters, and blend shapes of channels.
+type Deformer struct {
+	Object
+}
+
+// Cluster is the part of a skin deformer that binds vertices to a single
+// bone
+type Cluster struct {
+	Deformer
+}
+
+// Indexes are the vertices the cluster moves
+func (c Cluster) Indexes() []int32 {
+	if n := c.child("Indexes"); n != nil {
+		i, _ := n.Int32Slice()
+		return i
+	}
+	return nil
+}
+
+// Weights are how much the cluster moves each of it's vertices
+func (c Cluster) Weights() []float64 {
+	if n := c.child("Weights"); n != nil {
+		w, _ := n.Float64Slice()
+		return w
+	}
+	return nil
+}
+
+func (c Cluster) matrix(name string) Matrix4 {
+	m := IdentityMatrix4()
+	if n := c.child(name); n != nil {
+		if values, ok := n.Float64Slice(); ok && len(values) == 16 {
+			copy(m[:], values)
+		}
+	}
+	return m
+}
+
+// Transform is the mesh's transform at the time it was bound
+func (c Cluster) Transform() Matrix4 {
+	return c.matrix("Transform")
+}
+
+// TransformLink is the bone's transform at the time it was bound
+func (c Cluster) TransformLink() Matrix4 {
+	return c.matrix("TransformLink")
+}
+
+// Scene is a typed view over every object in an FBX and the connections
+// between them
+type Scene struct {
+	fbx     *FBX
+	graph   *ConnectionGraph
+	objects map[int64]*Node
+}
+
+// NewScene indexes the objects and connections of the FBX
+func NewScene(fbx *FBX) *Scene {
+	return &Scene{
+		fbx:     fbx,
+		graph:   NewConnectionGraph(fbx),
+		objects: fbx.ObjectNodes(),
+	}
+}
+
+// Object finds the object with the UID
+func (s *Scene) Object(uid int64) (Object, bool) {
+	n, ok := s.objects[uid]
+	return Object{node: n}, ok
+}
+
+// objectsOf goes through every object of the class in the order they're
+// found in the file
+func (s *Scene) objectsOf(class string, fn func(Object)) {
+	for _, n := range s.fbx.GetNodes("Objects", class) {
+		fn(Object{node: n})
+	}
+}
+
+// Geometry is every piece of geometry, meshes or not
+func (s *Scene) Geometry() []Geometry {
+	geometry := make([]Geometry, 0)
+	s.objectsOf("Geometry", func(o Object) { geometry = append(geometry, Geometry{o}) })
+	return geometry
+}
+
+// Meshes is every piece of polygon mesh geometry
+func (s *Scene) Meshes() []Geometry {
+	meshes := make([]Geometry, 0)
+	for _, g := range s.Geometry() {
+		if g.IsMesh() {
+			meshes = append(meshes, g)
+		}
+	}
+	return meshes
+}
+
+// Models is every model
+func (s *Scene) Models() []Model {
+	models := make([]Model, 0)
+	s.objectsOf("Model", func(o Object) { models = append(models, Model{o}) })
+	return models
+}
+
+// Materials is every material
+func (s *Scene) Materials() []Material {
+	materials := make([]Material, 0)
+	s.objectsOf("Material", func(o Object) { materials = append(materials, Material{o}) })
+	return materials
+}
+
+// Textures is every texture
+func (s *Scene) Textures() []Texture {
+	textures := make([]Texture, 0)
+	s.objectsOf("Texture", func(o Object) { textures = append(textures, Texture{o}) })
+	return textures
+}
+
+// Deformers is every deformer that isn't a cluster, like skins and blend
+// shapes
+func (s *Scene) Deformers() []Deformer {
+	deformers := make([]Deformer, 0)
+	s.objectsOf("Deformer", func(o Object) {
+		if o.Subclass() != "Cluster" {
+			deformers = append(deformers, Deformer{o})
+		}
+	})
+	return deformers
+}
+
+// Clusters is every cluster of the skin deformer, or of every skin if the
+// deformer is nil
+func (s *Scene) Clusters(skin *Deformer) []Cluster {
+	clusters := make([]Cluster, 0)
+	s.objectsOf("Deformer", func(o Object) {
+		if o.Subclass() != "Cluster" {
+			return
+		}
+		if skin != nil && containsID(s.graph.ParentIDs(o.UID()), skin.UID()) == false {
+			return
+		}
+		clusters = append(clusters, Cluster{Deformer{o}})
+	})
+	return clusters
+}
+
+// Children are the objects attached to the object with the UID
+func (s *Scene) Children(uid int64) []Object {
+	return s.lookup(s.graph.ChildIDs(uid))
+}
+
+// Parents are the objects the object with the UID is attached to
+func (s *Scene) Parents(uid int64) []Object {
+	return s.lookup(s.graph.ParentIDs(uid))
+}
+
+func (s *Scene) lookup(uids []int64) []Object {
+	objects := make([]Object, 0, len(uids))
+	for _, uid := range uids {
+		if n, ok := s.objects[uid]; ok {
+			objects = append(objects, Object{node: n})
+		}
+	}
+	return objects
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
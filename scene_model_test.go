@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildModelScene writes out a scene with a textured mesh, a blend shape, a
+// NURBS surface and a skin, and reads it back in
+func buildModelScene(t *testing.T, compress bool) (*FBX, map[string]int64) {
+	options := DefaultSceneOptions()
+	options.Compress = compress
+	scene := NewSceneBuilder(options)
+
+	uids := make(map[string]int64)
+	uids["material"] = scene.AddMaterial("Red", [3]float64{1, 0, 0}, 0.5)
+	uids["texture"] = scene.AddTexture("Bricks", "textures/bricks.png", uids["material"], "DiffuseColor")
+	uids["mesh"] = scene.AddMesh(SceneMesh{
+		Name:               "Quad",
+		Vertices:           []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0},
+		PolygonVertexIndex: []int32{0, 1, WrapToIndex(2), 0, 2, WrapToIndex(3)},
+		Normals:            []float64{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1},
+		UVs:                []float64{0, 0, 1, 0, 1, 1, 0, 1},
+		UVIndex:            []int32{0, 1, 2, 0, 2, 3},
+	})
+	uids["model"] = scene.AddModel("QuadModel", vector.NewVector3(5, 6, 7), 0)
+	scene.Connect(uids["mesh"], uids["model"])
+	scene.Connect(uids["material"], uids["model"])
+
+	uids["shape"] = scene.AddObject(
+		"Geometry", "Raised", "Shape",
+		NewNodeInt32Slice("Indexes", []int32{2}),
+		NewNodeFloat64Slice("Vertices", []float64{0, 0, 1}),
+	)
+	uids["nurbs"] = scene.AddObject(
+		"Geometry", "Surface", "NurbsSurface",
+		NewNodeFloat64Slice("Points", []float64{0, 0, 0, 1}),
+	)
+
+	uids["skin"] = scene.AddObject("Deformer", "Skin", "Skin")
+	transformLink := IdentityMatrix4()
+	transformLink[12] = 3
+	uids["cluster"] = scene.AddObject(
+		"Deformer", "Bone", "Cluster",
+		NewNodeInt32Slice("Indexes", []int32{0, 1}),
+		NewNodeFloat64Slice("Weights", []float64{1, 0.25}),
+		NewNodeFloat64Slice("TransformLink", transformLink[:]),
+	)
+	scene.Connect(uids["skin"], uids["mesh"])
+	scene.Connect(uids["cluster"], uids["skin"])
+
+	buffer := new(bytes.Buffer)
+	_, err := scene.WriteTo(buffer)
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	fbx, err := ReadFrom(bytes.NewReader(buffer.Bytes()))
+	if assert.NoError(t, err) == false {
+		t.FailNow()
+	}
+	return fbx, uids
+}
+
+func TestSceneDecodesTypedObjects(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx, uids := buildModelScene(t, true)
+
+	// ******************************** ACT ***********************************
+	scene := NewScene(fbx)
+
+	// ******************************* ASSERT *********************************
+	geometry := scene.Geometry()
+	assert.Len(t, geometry, 3)
+	meshes := scene.Meshes()
+	if assert.Len(t, meshes, 1) {
+		mesh := meshes[0]
+		assert.Equal(t, uids["mesh"], mesh.UID())
+		assert.Equal(t, "Quad", mesh.Name())
+		assert.Equal(t, "Geometry", mesh.Class())
+		assert.True(t, mesh.IsMesh())
+		assert.Equal(t, []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}, mesh.Vertices())
+		assert.Equal(t, [][]int32{{0, 1, 2}, {0, 2, 3}}, mesh.Polygons())
+
+		layers := mesh.Layers()
+		if assert.Len(t, layers, 2) {
+			assert.Equal(t, "LayerElementNormal", layers[0].Type())
+			assert.Equal(t, "ByPolygonVertex", layers[0].Mapping())
+			assert.Equal(t, "Direct", layers[0].Reference())
+			assert.Len(t, layers[0].Data(), 18)
+			assert.Nil(t, layers[0].Index())
+		}
+		uv, ok := mesh.Layer("LayerElementUV")
+		assert.True(t, ok)
+		assert.Equal(t, "IndexToDirect", uv.Reference())
+		assert.Equal(t, []float64{0, 0, 1, 0, 1, 1, 0, 1}, uv.Data())
+		assert.Equal(t, []int32{0, 1, 2, 0, 2, 3}, uv.Index())
+	}
+
+	assert.True(t, geometry[1].IsShape())
+	assert.Equal(t, []int32{2}, geometry[1].Indexes())
+	assert.Equal(t, []float64{0, 0, 1}, geometry[1].Vertices())
+	assert.True(t, geometry[2].IsNurbs())
+	assert.False(t, geometry[2].IsMesh())
+
+	models := scene.Models()
+	if assert.Len(t, models, 1) {
+		assert.Equal(t, "QuadModel", models[0].Name())
+		assert.Equal(t, vector.NewVector3(5, 6, 7), models[0].LocalTranslation())
+		assert.Equal(t, vector.NewVector3(1, 1, 1), models[0].LocalScaling())
+	}
+
+	materials := scene.Materials()
+	if assert.Len(t, materials, 1) {
+		assert.Equal(t, "lambert", materials[0].ShadingModel())
+		assert.Equal(t, [3]float64{1, 0, 0}, materials[0].DiffuseColor())
+		assert.Equal(t, 0.5, materials[0].Opacity())
+	}
+
+	textures := scene.Textures()
+	if assert.Len(t, textures, 1) {
+		assert.Equal(t, "textures/bricks.png", textures[0].FileName())
+	}
+
+	deformers := scene.Deformers()
+	if assert.Len(t, deformers, 1) {
+		assert.Equal(t, "Skin", deformers[0].Subclass())
+		clusters := scene.Clusters(&deformers[0])
+		if assert.Len(t, clusters, 1) {
+			assert.Equal(t, []int32{0, 1}, clusters[0].Indexes())
+			assert.Equal(t, []float64{1, 0.25}, clusters[0].Weights())
+			assert.Equal(t, IdentityMatrix4(), clusters[0].Transform())
+			assert.Equal(t, 3., clusters[0].TransformLink()[12])
+		}
+	}
+	assert.Len(t, scene.Clusters(nil), 1)
+
+	children := scene.Children(uids["model"])
+	if assert.Len(t, children, 2) {
+		assert.Equal(t, uids["mesh"], children[0].UID())
+		assert.Equal(t, uids["material"], children[1].UID())
+	}
+	parents := scene.Parents(uids["skin"])
+	if assert.Len(t, parents, 1) {
+		assert.Equal(t, "Quad", parents[0].Name())
+	}
+	_, ok := scene.Object(uids["texture"])
+	assert.True(t, ok)
+}
+
+func TestSceneObjectsBecomeDiffs(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		// ****************************** ARRANGE *********************************
+		fbx, uids := buildModelScene(t, compress)
+		scene := NewScene(fbx)
+		mesh := scene.Meshes()[0]
+		texture, _ := scene.Object(uids["texture"])
+
+		diffs := []Diff{
+			mesh.SetName("Renamed"),
+			mesh.SetVertices([]float64{0, 0, 0, 2, 0, 0, 2, 2, 0}),
+			mesh.SetPolygonVertexIndex([]int32{0, 1, WrapToIndex(2)}),
+			texture.Delete(),
+		}
+		sort.Sort(SortDiff(diffs))
+
+		// ******************************** ACT ***********************************
+		buffer := new(bytes.Buffer)
+		_, err := NewPatchWriter(fbx, diffs, func(int, error) {}).Write(buffer)
+		assert.NoError(t, err)
+		patched, err := ReadFrom(bytes.NewReader(buffer.Bytes()))
+		assert.NoError(t, err)
+
+		// ******************************* ASSERT *********************************
+		patchedMesh := NewScene(patched).Meshes()[0]
+		assert.Equal(t, "Renamed", patchedMesh.Name())
+		assert.Equal(t, []float64{0, 0, 0, 2, 0, 0, 2, 2, 0}, patchedMesh.Vertices())
+		assert.Equal(t, [][]int32{{0, 1, 2}}, patchedMesh.Polygons())
+		assert.Equal(t, compress, patchedMesh.child("Vertices").ArrayProperties[0].Encoding == 1)
+		assert.Len(t, NewScene(patched).Textures(), 0)
+	}
+}
+
+func TestMatchMeshGeometrySkipsShapesAndNurbs(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	file := writeTestFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newTriangleGeometryNode(1),
+			newObjectNode(
+				"Geometry", 2, "Raised", "Shape",
+				NewNodeFloat64Slice("Vertices", []float64{0, 0, 1}),
+				NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3}),
+			),
+			newObjectNode(
+				"Geometry", 3, "Curve", "NurbsCurve",
+				NewNodeFloat64Slice("Vertices", []float64{0, 0, 1}),
+				NewNodeInt32Slice("PolygonVertexIndex", []int32{0, 1, -3}),
+			),
+		),
+	)
+	results := make(chan []*Node, 10)
+	reader := NewReaderWithFilters(MatchMeshGeometry(), results)
+
+	// ******************************** ACT ***********************************
+	reader.ReadFrom(bytes.NewReader(file))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, reader.Error)
+	matched := make([]int64, 0)
+	for batch := range results {
+		for _, n := range batch {
+			matched = append(matched, Object{node: n}.UID())
+		}
+	}
+	assert.Equal(t, []int64{1}, matched)
+}
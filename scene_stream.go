@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxStreamedPolygonVertices is roughly how many polygon vertices go into a
+// single piece of geometry when converting other formats
+const maxStreamedPolygonVertices = 1 << 18
+
+// firstStreamedUID is where UIDs of geometry and models created while
+// streaming start, along with objects added to a SceneBuilder, leaving 0 for
+// the root
+const firstStreamedUID = 1000000
+
+// streamedDocumentUID is the UID of the scene's document, right before any
+// geometry
+const streamedDocumentUID = firstStreamedUID - 1
+
+// firstStreamedMaterialUID is where UIDs of materials and textures created
+// while streaming start, well clear of any geometry
+const firstStreamedMaterialUID = 1 << 40
+
+// streamedGeometry is a piece of mesh geometry converted from some other
+// format
+type streamedGeometry struct {
+	name               string
+	vertices           []float64
+	polygonVertexIndex []int32
+	layerElements      []*Node
+
+	// materials are the indices of the stream's materials used by the
+	// geometry, in the order LayerElementMaterial refers to them
+	materials []int
+}
+
+// streamedMaterial is a material converted from some other format
+type streamedMaterial struct {
+	name       string
+	diffuse    [3]float64
+	opacity    float64
+	diffuseMap string
+}
+
+// newStreamedMaterial creates a material with the same defaults FBX uses
+func newStreamedMaterial(name string) streamedMaterial {
+	return streamedMaterial{
+		name:    name,
+		diffuse: [3]float64{0.8, 0.8, 0.8},
+		opacity: 1,
+	}
+}
+
+// sceneStream builds up an FBX out of mesh geometry coming from some other
+// format. Every piece of geometry is numbered as it's added and handed off to
+// the results channel in batches, the same way the reader does, so it can be
+// split while the rest of the file is still being read. Each piece of
+// geometry gets a model of it's own attached to the root.
+type sceneStream struct {
+	name          string
+	results       chan<- []*Node
+	batcher       *JobBatcher
+	header        []*Node
+	definitionsID uint64
+	objectsID     uint64
+	nextID        uint64
+	geometry      []*Node
+	models        []*Node
+	connections   []*Node
+	materials     []streamedMaterial
+	materialIDs   map[string]int
+
+	currentResultsBuffer     []*Node
+	currentResultsBufferSize int64
+}
+
+func newSceneStream(name string, results chan<- []*Node, batcher *JobBatcher) *sceneStream {
+	s := &sceneStream{
+		name:        name,
+		results:     results,
+		batcher:     batcher,
+		header:      streamHeader(),
+		geometry:    make([]*Node, 0),
+		models:      make([]*Node, 0),
+		connections: make([]*Node, 0),
+		materials:   make([]streamedMaterial, 0),
+		materialIDs: make(map[string]int),
+	}
+
+	for _, n := range s.header {
+		s.nextID = n.number(s.nextID)
+	}
+
+	// Definitions can't be filled out until we know how many objects there
+	// are, so enough ids are reserved up front for it to hold every type.
+	// Types left without objects are dropped from it, leaving ids unused.
+	s.definitionsID = s.nextID
+	reserved := streamDefinitions(1, 1, 1)
+	addNullRecords(reserved)
+	s.objectsID = reserved.number(s.definitionsID)
+	s.nextID = s.objectsID + 1
+	return s
+}
+
+// streamedAxisSystem is the axis system declared for scenes converted from
+// formats without units or axes of their own. Scans are nearly always in
+// meters, so that's what they're taken to be, with Y up.
+func streamedAxisSystem() AxisSystem {
+	axis := DefaultAxisSystem()
+	axis.UnitScaleFactor = 100
+	return axis
+}
+
+// streamHeader is every node that comes before Definitions
+func streamHeader() []*Node {
+	settings := sceneGlobalSettings(streamedAxisSystem())
+	documents := sceneDocuments(streamedDocumentUID)
+	addNullRecords(settings)
+	addNullRecords(documents)
+	return append(headerNodes(time.Now(), 7500), settings, documents)
+}
+
+func streamDefinitions(geometry, materials, textures int) *Node {
+	return sceneDefinitions(
+		[]string{"GlobalSettings", "Geometry", "Model", "Material", "Texture"},
+		map[string]int{
+			"GlobalSettings": 1,
+			"Geometry":       geometry,
+			"Model":          geometry,
+			"Material":       materials,
+			"Texture":        textures,
+		},
+	)
+}
+
+func (s *sceneStream) geometryUID(i int) int64 {
+	return firstStreamedUID + int64(i)*2
+}
+
+func (s *sceneStream) modelUID(i int) int64 {
+	return firstStreamedUID + int64(i)*2 + 1
+}
+
+// material returns the index of the material with the name, registering it
+// the first time it's seen
+func (s *sceneStream) material(m streamedMaterial) int {
+	if i, ok := s.materialIDs[m.name]; ok {
+		return i
+	}
+	s.materials = append(s.materials, m)
+	s.materialIDs[m.name] = len(s.materials) - 1
+	return len(s.materials) - 1
+}
+
+// addGeometry creates a geometry node out of the vertices, polygons and
+// layer elements, queuing it up to be split
+func (s *sceneStream) addGeometry(g streamedGeometry) {
+	i := len(s.geometry)
+
+	name := g.name
+	if name == "" {
+		name = fmt.Sprintf("%s_%d", s.name, i)
+	}
+
+	children := []*Node{
+		NewNodeInt32("GeometryVersion", 124),
+		NewNodeFloat64Slice("Vertices", g.vertices),
+		NewNodeInt32Slice("PolygonVertexIndex", g.polygonVertexIndex),
+	}
+
+	layer := make([]*Node, 0, len(g.layerElements)+1)
+	layer = append(layer, NewNodeInt32("Version", 100))
+	for _, element := range g.layerElements {
+		children = append(children, element)
+		layer = append(layer, NewNodeParent(
+			"LayerElement",
+			NewNodeString("Type", element.Name),
+			NewNodeInt32("TypedIndex", 0),
+		))
+	}
+	if len(g.layerElements) > 0 {
+		children = append(children, NewNode("Layer", []*Property{NewPropertyInt32(0)}, nil, layer))
+	}
+
+	geometry := newObjectNode("Geometry", s.geometryUID(i), name, "Mesh", children...)
+	addNullRecords(geometry)
+	s.nextID = geometry.number(s.nextID)
+	s.geometry = append(s.geometry, geometry)
+
+	// Models are numbered once all the geometry is in
+	s.models = append(s.models, newObjectNode("Model", s.modelUID(i), name, "Mesh", NewNodeInt32("Version", 232)))
+	s.connections = append(
+		s.connections,
+		newConnectionNode("OO", s.modelUID(i), 0),
+		newConnectionNode("OO", s.geometryUID(i), s.modelUID(i)),
+	)
+	for _, m := range g.materials {
+		s.connections = append(s.connections, newConnectionNode("OO", s.materialUID(m), s.modelUID(i)))
+	}
+
+	s.addNodeToResultsChannel(geometry, int64(geometry.Length))
+}
+
+func (s *sceneStream) materialUID(i int) int64 {
+	return firstStreamedMaterialUID + int64(i)*2
+}
+
+func (s *sceneStream) textureUID(i int) int64 {
+	return firstStreamedMaterialUID + int64(i)*2 + 1
+}
+
+func (s *sceneStream) addNodeToResultsChannel(n *Node, size int64) {
+	if s.results == nil {
+		return
+	}
+
+	s.currentResultsBufferSize += size
+	s.currentResultsBuffer = append(s.currentResultsBuffer, n)
+
+	if s.currentResultsBufferSize > s.batcher.Size() {
+		s.results <- s.currentResultsBuffer
+		s.currentResultsBufferSize = 0
+		s.currentResultsBuffer = make([]*Node, 0)
+	}
+}
+
+// finish lets the results channel know there's nothing left to split and
+// returns the completed FBX
+func (s *sceneStream) finish() *FBX {
+	if s.results != nil {
+		if len(s.currentResultsBuffer) > 0 {
+			s.results <- s.currentResultsBuffer
+			s.currentResultsBuffer = nil
+			s.currentResultsBufferSize = 0
+		}
+		close(s.results)
+	}
+
+	objectNodes := make([]*Node, 0, len(s.geometry)*2+len(s.materials))
+	objectNodes = append(objectNodes, s.geometry...)
+
+	for _, model := range s.models {
+		addNullRecords(model)
+		s.nextID = model.number(s.nextID)
+		objectNodes = append(objectNodes, model)
+	}
+
+	textures := 0
+	for i, m := range s.materials {
+		material := newObjectNode(
+			"Material", s.materialUID(i), m.name, "",
+			NewNodeInt32("Version", 102),
+			NewNodeString("ShadingModel", "lambert"),
+			NewNodeParent(
+				"Properties70",
+				newColorPNode("DiffuseColor", m.diffuse),
+				newDoublePNode("Opacity", m.opacity),
+			),
+		)
+		addNullRecords(material)
+		s.nextID = material.number(s.nextID)
+		objectNodes = append(objectNodes, material)
+
+		if m.diffuseMap == "" {
+			continue
+		}
+		texture := newObjectNode(
+			"Texture", s.textureUID(i), m.name+"_diffuse", "",
+			NewNodeString("FileName", m.diffuseMap),
+			NewNodeString("RelativeFilename", m.diffuseMap),
+		)
+		addNullRecords(texture)
+		s.nextID = texture.number(s.nextID)
+		objectNodes = append(objectNodes, texture)
+		s.connections = append(s.connections, newConnectionNode("OP", s.textureUID(i), s.materialUID(i), "DiffuseColor"))
+		textures++
+	}
+
+	definitions := streamDefinitions(len(s.geometry), len(s.materials), textures)
+	addNullRecords(definitions)
+	definitions.number(s.definitionsID)
+
+	// Every child of the objects node was finished as it was created, and the
+	// geometry has already been handed off to be split, so only the objects
+	// node itself gets closed off. Walking back into it's children would race
+	// with the workers reading them.
+	if len(objectNodes) > 0 {
+		end := &Node{}
+		s.nextID = end.number(s.nextID)
+		objectNodes = append(objectNodes, end)
+	}
+
+	// The objects node was numbered before any of it's children were known
+	objects := NewNodeParent("Objects", objectNodes...)
+	objects.id = s.objectsID
+	objects.endingID = s.nextID - 1
+
+	connections := NewNodeParent("Connections", s.connections...)
+	addNullRecords(connections)
+	s.nextID = connections.number(s.nextID)
+
+	// Every file ends with an empty node
+	end := &Node{}
+	end.number(s.nextID)
+
+	nodes := make([]*Node, 0, len(s.header)+3)
+	nodes = append(nodes, s.header[1:]...)
+	nodes = append(nodes, definitions, objects, connections, end)
+
+	return &FBX{
+		Header: NewHeader(binaryHeader(7500)),
+		Top:    s.header[0],
+		Nodes:  nodes,
+	}
+}
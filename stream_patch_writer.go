@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// streamOutput is what a StreamPatchWriter writes to. Everything goes out
+// front to back, with a couple of spots written over once splitting is done.
+type streamOutput interface {
+	io.Writer
+	io.WriterAt
+}
+
+// diffSource blocks until the diffs of every node up to and including the id
+// are known, returning all of the sorted diffs known so far
+type diffSource func(id uint64) []Diff
+
+// StreamPatchWriter writes out the FBX while it's geometry is still being
+// split. Each object under Objects is written as soon as every diff that could
+// change it, or decide whether it's thrown out along with empty geometry, is
+// known. How many objects there are and where Objects ends can't be known
+// until splitting is done, so Definitions is written as it was and Objects
+// without an end, and both are written over once everything else is out.
+// Object types left without any objects keep their entry in Definitions with
+// a count of 0, since removing it would move everything after it.
+type StreamPatchWriter struct {
+	fbx     *FBX
+	graph   *ConnectionGraph
+	objects map[int64]*Node
+
+	// remover is fed every diff as it comes in, having seen the first
+	// removerSaw of them
+	remover    *emptyGeometryRemover
+	removerSaw int
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing with an OffsetError on the first one that's off
+	Verify bool
+}
+
+// NewStreamPatchWriter creates a writer for the FBX, which has to be
+// completely read in even though it's geometry doesn't need to be split yet
+func NewStreamPatchWriter(fbx *FBX, graph *ConnectionGraph) *StreamPatchWriter {
+	return &StreamPatchWriter{
+		fbx:     fbx,
+		graph:   graph,
+		objects: fbx.ObjectNodes(),
+	}
+}
+
+// settledAfter is the id of the last node whose diffs need to be known before
+// the object can be written. That's the object itself along with all of the
+// geometry deciding whether it's thrown out, which for a model is it's
+// geometry and sub models, and for anything else is everything using it.
+func (sw *StreamPatchWriter) settledAfter(n *Node) uint64 {
+	settled := n.endingID
+	uid := Object{node: n}.UID()
+	if uid == 0 {
+		return settled
+	}
+
+	visited := map[int64]bool{uid: true}
+	queue := []int64{uid}
+	for len(queue) > 0 {
+		object, ok := sw.objects[queue[0]]
+		uid := queue[0]
+		queue = queue[1:]
+		if ok == false {
+			continue
+		}
+
+		var next []int64
+		if object.Name == "Model" {
+			for _, child := range sw.graph.ChildIDs(uid, ConnectionType("OO")) {
+				if c, ok := sw.objects[child]; ok && (c.Name == "Geometry" || c.Name == "Model") {
+					next = append(next, child)
+				}
+			}
+		} else {
+			if object.Name == "Geometry" && object.endingID > settled {
+				settled = object.endingID
+			}
+			for _, parent := range sw.graph.ParentIDs(uid) {
+				if p, ok := sw.objects[parent]; ok && containerClasses[p.Name] == false {
+					next = append(next, parent)
+				}
+			}
+		}
+
+		for _, id := range next {
+			if visited[id] == false {
+				visited[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	return settled
+}
+
+// available waits on the diffs of every node up to and including the id,
+// throwing out any geometry they leave empty
+func (sw *StreamPatchWriter) available(diffs diffSource, id uint64) []Diff {
+	known := diffs(id)
+	sw.remover.add(known[sw.removerSaw:])
+	sw.removerSaw = len(known)
+	return known
+}
+
+// finalDiffs waits on every diff, adding everything only known once splitting
+// is done the same way split does, apart from Definitions keeping it's size
+func (sw *StreamPatchWriter) finalDiffs(diffs diffSource) []Diff {
+	all := sw.available(diffs, math.MaxUint64)
+	if len(sw.remover.dead) > 0 {
+		all = combineSorted(all, sw.remover.deletions())
+	}
+	return updateDefinitionCounts(sw.fbx, all, true)
+}
+
+// writeObjects writes everything in Objects as it's diffs come in, leaving
+// where Objects ends to be written over later, and returns the offset it
+// actually ends at
+func (sw *StreamPatchWriter) writeObjects(nw *nodeWriter, objects *Node, currentOffset uint64, diffs diffSource) (uint64, error) {
+	if err := nw.writeHeader(objects, 0); err != nil {
+		return 0, err
+	}
+	currentOffset += nw.headerSize() + uint64(objects.NameLen) + objects.PropertyListLen
+
+	diffIndex := 0
+	for _, child := range objects.NestedNodes {
+		if child == nil {
+			continue
+		}
+
+		available := sw.available(diffs, sw.settledAfter(child))
+
+		var patched *Node
+		patched, diffIndex = child.ApplyDiffs(available, diffIndex)
+		if patched == nil || (child.Length != 0 && sw.remover.isDead(Object{node: child}.UID())) {
+			continue
+		}
+
+		var err error
+		currentOffset, err = nw.writeNode(patched, currentOffset)
+		if err != nil {
+			return 0, within(objects.Name, err)
+		}
+	}
+	return currentOffset, nil
+}
+
+// writeOver encodes the node into the spot it was already written to, which
+// it has to fit exactly
+func (sw *StreamPatchWriter) writeOver(w io.WriterAt, original, patched *Node, offset uint64, legacy bool) error {
+	buffer := new(bytes.Buffer)
+	nw := newNodeWriter(buffer, legacy)
+	nw.verify = sw.Verify
+	if patched == nil || nw.length(patched) != nw.length(original) {
+		return fmt.Errorf("%s can't change size once it's been written", original.Name)
+	}
+	if _, err := nw.writeNode(patched, offset); err != nil {
+		return err
+	}
+	if err := nw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.WriteAt(buffer.Bytes(), int64(offset))
+	return err
+}
+
+// Write writes the patched FBX out as the diffs come in from the source,
+// returning how many bytes were written
+func (sw *StreamPatchWriter) Write(w streamOutput, diffs diffSource) (int64, error) {
+	sw.remover = newEmptyGeometryRemover(sw.fbx, sw.graph)
+	sw.removerSaw = 0
+
+	legacy := sw.fbx.Header.Version() < 7500
+	nw := newNodeWriter(w, legacy)
+	nw.verify = sw.Verify
+
+	written, err := nw.Write(sw.fbx.Header.data)
+	if err != nil {
+		return 0, err
+	}
+	currentOffset := uint64(written)
+
+	nodes := make([]*Node, 0, len(sw.fbx.Nodes)+1)
+	if sw.fbx.Top != nil {
+		nodes = append(nodes, sw.fbx.Top)
+	}
+	nodes = append(nodes, sw.fbx.Nodes...)
+
+	var definitions, objects *Node
+	var definitionsOffset, objectsOffset, objectsEnd uint64
+	var final []Diff
+	finalIndex := 0
+	for _, n := range nodes {
+		switch {
+		case n.Name == "Definitions" && definitions == nil:
+			definitions, definitionsOffset = n, currentOffset
+			currentOffset, err = nw.writeNode(n, currentOffset)
+
+		case n.Name == "Objects" && n.Length != 0 && objects == nil:
+			objects, objectsOffset = n, currentOffset
+			currentOffset, err = sw.writeObjects(nw, n, currentOffset, diffs)
+			objectsEnd = currentOffset
+
+		// Splitting only ever changes Definitions, Objects and Connections,
+		// so nothing else before Objects needs to wait on it
+		case final == nil && objects == nil && n.Name != "Connections":
+			currentOffset, err = nw.writeNode(n, currentOffset)
+
+		default:
+			if final == nil {
+				final = sw.finalDiffs(diffs)
+			}
+			var patched *Node
+			patched, finalIndex = n.ApplyDiffs(final, finalIndex)
+			if patched != nil {
+				currentOffset, err = nw.writeNode(patched, currentOffset)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	footerLength, err := writeFooter(nw, currentOffset, sw.fbx.Footer, sw.fbx.Header.Version())
+	if err == nil {
+		err = nw.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if objects != nil {
+		end := make([]byte, 8)
+		binary.LittleEndian.PutUint64(end, objectsEnd)
+		if legacy {
+			end = end[:4]
+		}
+		if _, err := w.WriteAt(end, int64(objectsOffset)); err != nil {
+			return 0, err
+		}
+	}
+
+	if definitions != nil {
+		if final == nil {
+			final = sw.finalDiffs(diffs)
+		}
+		patched, _ := definitions.ApplyDiffs(final, 0)
+		if err := sw.writeOver(w, definitions, patched, definitionsOffset, legacy); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(currentOffset) + int64(footerLength), nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamedFile is a memoryFile that can also be written to front to back
+type streamedFile struct {
+	memoryFile
+	offset int64
+}
+
+func (f *streamedFile) Write(b []byte) (int, error) {
+	n, err := f.WriteAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// allKnown is a source of diffs that are all known up front
+func allKnown(diffs []Diff) diffSource {
+	return func(uint64) []Diff { return diffs }
+}
+
+func TestStreamPatchWriterMatchesPatchWriter(t *testing.T) {
+	for _, version := range []uint32{7400, 7500} {
+		// ****************************** ARRANGE *********************************
+		config := DefaultGeneratorConfig()
+		config.TrianglesPerGeometry = 200
+		config.Version = version
+		file := new(bytes.Buffer)
+		assert.NoError(t, GenerateFBX(file, config))
+		fbx, err := ReadFrom(bytes.NewReader(file.Bytes()))
+		assert.NoError(t, err)
+
+		graph := NewConnectionGraph(fbx)
+		transforms := NewSceneTransforms(fbx, graph).GeometryTransforms()
+		plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0, 0))
+		retained := make([]Diff, 0)
+		for _, geometry := range fbx.GetNodes("Objects", "Geometry") {
+			local, ok := geometryPlane(geometry, plane, transforms)
+			assert.True(t, ok)
+			r, _ := SplitByPlane(geometry, local)
+			retained = append(retained, r...)
+		}
+
+		expected := new(bytes.Buffer)
+		final := updateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, retained), true)
+		_, err = NewPatchWriter(fbx, final, func(int, error) {}).Write(expected)
+		assert.NoError(t, err)
+
+		out := new(streamedFile)
+		sw := NewStreamPatchWriter(fbx, graph)
+		sw.Verify = true
+
+		// ******************************** ACT ***********************************
+		written, err := sw.Write(out, allKnown(retained))
+
+		// ******************************* ASSERT *********************************
+		assert.NoError(t, err)
+		assert.Equal(t, int64(expected.Len()), written)
+		assert.Equal(t, expected.Bytes(), out.data, "version %d", version)
+	}
+}
+
+func TestStreamPatchWriterWaitsOnWhatDecidesEachObject(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Definitions",
+			NewNodeInt32("Version", 100),
+			NewNodeInt32("Count", 5),
+			newObjectTypeNode("Geometry", 2),
+			newObjectTypeNode("Model", 2),
+			newObjectTypeNode("Material", 1),
+		),
+		NewNodeParent(
+			"Objects",
+			newObjectNode("Model", 2, "Emptied", "Mesh"),
+			newObjectNode("Material", 3, "OnlyEmptied", ""),
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 5, "Kept", "Mesh"),
+			newTriangleGeometryNode(4),
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 2, 0),
+			newConnectionNode("OO", 1, 2),
+			newConnectionNode("OO", 3, 2),
+			newConnectionNode("OO", 5, 0),
+			newConnectionNode("OO", 4, 5),
+		),
+	)
+	graph := NewConnectionGraph(fbx)
+	objects := fbx.ObjectNodes()
+
+	emptied := WorkerResult{
+		job:      0,
+		retained: []Diff{NewArrayPropertyDiff(objects[1].NestedNodes[1].id, NewArrayPropertyInt32Slice([]int32{}))},
+		through:  objects[1].endingID,
+	}
+	kept := WorkerResult{job: 1, retained: []Diff{}, through: objects[4].endingID}
+
+	expected := new(bytes.Buffer)
+	final := updateDefinitionCounts(fbx, RemoveEmptyGeometry(fbx, graph, emptied.retained), true)
+	_, err := NewPatchWriter(fbx, final, func(int, error) {}).Write(expected)
+	assert.NoError(t, err)
+
+	merger := newDiffMerger()
+	diffs := func(id uint64) []Diff {
+		retained, _ := merger.upTo(id)
+		return retained
+	}
+	out := new(streamedFile)
+	done := make(chan error)
+
+	// ******************************** ACT ***********************************
+	go func() {
+		_, err := NewStreamPatchWriter(fbx, graph).Write(out, diffs)
+		done <- err
+	}()
+	merger.add(kept)
+	merger.add(emptied)
+	merger.finish()
+	err = <-done
+	streamed, readErr := ReadFrom(bytes.NewReader(out.data))
+
+	// ******************************* ASSERT *********************************
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Bytes(), out.data)
+	if assert.NoError(t, readErr) == false {
+		return
+	}
+	assert.Len(t, streamed.GetNodes("Objects", "Model"), 1)
+	assert.Len(t, streamed.GetNodes("Objects", "Material"), 0)
+	assert.Len(t, streamed.GetNodes("Connections", "C"), 2)
+
+	counts := make(map[string]int32)
+	for _, objectType := range streamed.GetNodes("Definitions", "ObjectType") {
+		counts[objectType.Properties[0].AsString()] = objectType.GetNodes("Count")[0].Properties[0].AsInt32()
+	}
+	assert.Equal(t, map[string]int32{"Geometry": 1, "Model": 1, "Material": 0}, counts)
+	assert.Equal(t, int32(2), streamed.GetNodes("Definitions", "Count")[0].Properties[0].AsInt32())
+}
+
+func TestStreamPatchWriterSettlesObjectsOnTheGeometryDecidingThem(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := readBackFBX(
+		t,
+		NewNodeParent(
+			"Objects",
+			newObjectNode("Model", 2, "A", "Mesh"),
+			newObjectNode("Material", 3, "Shared", ""),
+			newTriangleGeometryNode(1),
+			newObjectNode("Model", 5, "B", "Mesh"),
+			newTriangleGeometryNode(4),
+		),
+		NewNodeParent(
+			"Connections",
+			newConnectionNode("OO", 2, 0),
+			newConnectionNode("OO", 1, 2),
+			newConnectionNode("OO", 3, 2),
+			newConnectionNode("OO", 5, 0),
+			newConnectionNode("OO", 4, 5),
+			newConnectionNode("OO", 3, 5),
+		),
+	)
+	objects := fbx.ObjectNodes()
+	sw := NewStreamPatchWriter(fbx, NewConnectionGraph(fbx))
+
+	// ******************************** ACT ***********************************
+	modelA := sw.settledAfter(objects[2])
+	shared := sw.settledAfter(objects[3])
+	geometry := sw.settledAfter(objects[1])
+	modelB := sw.settledAfter(objects[5])
+
+	// ******************************* ASSERT *********************************
+	assert.Equal(t, objects[1].endingID, modelA)
+	assert.Equal(t, objects[4].endingID, shared)
+	assert.Equal(t, objects[1].endingID, geometry)
+	assert.Equal(t, objects[4].endingID, modelB)
+}
+
+func TestSplitStreamsFBXFilesWhenAsked(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	config := DefaultGeneratorConfig()
+	config.Geometry = 40
+	config.TrianglesPerGeometry = 100
+
+	dir, err := ioutil.TempDir("", "stream")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	modelName := filepath.Join(dir, "generated.fbx")
+	f, err := os.Create(modelName)
+	assert.NoError(t, err)
+	assert.NoError(t, GenerateFBX(f, config))
+	f.Close()
+
+	create := func(name string) *os.File {
+		f, err := os.Create(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return f
+	}
+	files := map[string]*os.File{}
+	for _, name := range []string{"retained.fbx", "clipped.fbx", "streamed-retained.fbx", "streamed-clipped.fbx"} {
+		files[name] = create(name)
+		defer files[name].Close()
+	}
+	streamed := func(name string) *FBXChunkWriter {
+		w := NewFBXChunkWriter(files[name])
+		w.Stream = true
+		w.Verify = true
+		return w
+	}
+
+	plane := NewPlane(vector.Vector3Zero(), vector.NewVector3(1, 0, 0))
+
+	// ******************************** ACT ***********************************
+	SplitByPlaneIntoChunks(
+		modelName, plane, 2,
+		MultiChunkWriter{NewFBXChunkWriter(files["retained.fbx"]), streamed("streamed-retained.fbx")},
+		MultiChunkWriter{NewFBXChunkWriter(files["clipped.fbx"]), streamed("streamed-clipped.fbx")},
+	)
+
+	// ******************************* ASSERT *********************************
+	read := func(name string) []byte {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return b
+	}
+	assert.NotEmpty(t, read("retained.fbx"))
+	assert.Equal(t, read("retained.fbx"), read("streamed-retained.fbx"))
+	assert.Equal(t, read("clipped.fbx"), read("streamed-clipped.fbx"))
+}
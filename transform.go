@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/EliCDavis/vector"
+)
+
+// ModelTransforms evaluates the local transform of a Model from it's
+// Properties70, returning both the transform inherited by child models and
+// the geometric transform applied only to geometry attached to the model.
+//
+// The local transform follows the FBX SDK's definition:
+//
+//	T * Roff * Rp * Rpre * R * Rpost^-1 * Rp^-1 * Soff * Sp * S * Sp^-1
+func ModelTransforms(model *Node) (local Matrix4, geometric Matrix4) {
+	props := NewProperties70(model)
+
+	zero := vector.Vector3Zero()
+	one := vector.NewVector3(1, 1, 1)
+
+	order := RotationOrderXYZ
+	if o, ok := props.Get("RotationOrder"); ok {
+		order = RotationOrder(o.Int())
+	}
+
+	rotationPivot := props.Vector3Or("RotationPivot", zero)
+	scalingPivot := props.Vector3Or("ScalingPivot", zero)
+
+	local = TranslationMatrix4(props.Vector3Or("Lcl Translation", zero)).
+		Multiply(TranslationMatrix4(props.Vector3Or("RotationOffset", zero))).
+		Multiply(TranslationMatrix4(rotationPivot)).
+		Multiply(EulerRotationMatrix4(props.Vector3Or("PreRotation", zero), RotationOrderXYZ))
+
+	local = local.
+		Multiply(EulerRotationMatrix4(props.Vector3Or("Lcl Rotation", zero), order))
+
+	postRotation, _ := EulerRotationMatrix4(props.Vector3Or("PostRotation", zero), RotationOrderXYZ).Inverse()
+	local = local.
+		Multiply(postRotation).
+		Multiply(TranslationMatrix4(rotationPivot.MultByConstant(-1))).
+		Multiply(TranslationMatrix4(props.Vector3Or("ScalingOffset", zero))).
+		Multiply(TranslationMatrix4(scalingPivot)).
+		Multiply(ScalingMatrix4(props.Vector3Or("Lcl Scaling", one))).
+		Multiply(TranslationMatrix4(scalingPivot.MultByConstant(-1)))
+
+	geometric = TranslationMatrix4(props.Vector3Or("GeometricTranslation", zero)).
+		Multiply(EulerRotationMatrix4(props.Vector3Or("GeometricRotation", zero), RotationOrderXYZ)).
+		Multiply(ScalingMatrix4(props.Vector3Or("GeometricScaling", one)))
+
+	return local, geometric
+}
+
+// SceneTransforms evaluates world transforms of Models and Geometry by
+// walking up the connection hierarchy
+type SceneTransforms struct {
+	objects map[int64]*Node
+	graph   *ConnectionGraph
+	world   map[int64]Matrix4
+}
+
+// NewSceneTransforms creates a new transform evaluator for the FBX. Only
+// Objects/Model nodes and the Connections section need to have been read.
+func NewSceneTransforms(fbx *FBX, graph *ConnectionGraph) *SceneTransforms {
+	return &SceneTransforms{
+		objects: fbx.ObjectNodes(),
+		graph:   graph,
+		world:   make(map[int64]Matrix4),
+	}
+}
+
+func (s *SceneTransforms) parentModel(uid int64) (int64, bool) {
+	for _, parent := range s.graph.ParentIDs(uid, ConnectionType("OO")) {
+		if n, ok := s.objects[parent]; ok && n.Name == "Model" {
+			return parent, true
+		}
+	}
+	return 0, false
+}
+
+// ModelWorld returns the transform taking points from the model's space into
+// world space
+func (s *SceneTransforms) ModelWorld(uid int64) Matrix4 {
+	return s.modelWorld(uid, make(map[int64]bool))
+}
+
+func (s *SceneTransforms) modelWorld(uid int64, visiting map[int64]bool) Matrix4 {
+	if world, ok := s.world[uid]; ok {
+		return world
+	}
+
+	model, ok := s.objects[uid]
+	if ok == false || model.Name != "Model" || visiting[uid] {
+		return IdentityMatrix4()
+	}
+	visiting[uid] = true
+
+	world, _ := ModelTransforms(model)
+	if parent, ok := s.parentModel(uid); ok {
+		world = s.modelWorld(parent, visiting).Multiply(world)
+	}
+
+	s.world[uid] = world
+	return world
+}
+
+// GeometryWorld returns the transform taking the geometry's vertices into
+// world space. Geometry instanced across multiple models uses the first model
+// found. Returns false if the geometry isn't attached to any model.
+func (s *SceneTransforms) GeometryWorld(uid int64) (Matrix4, bool) {
+	model, ok := s.parentModel(uid)
+	if ok == false {
+		return IdentityMatrix4(), false
+	}
+	_, geometric := ModelTransforms(s.objects[model])
+	return s.ModelWorld(model).Multiply(geometric), true
+}
+
+// GeometryTransforms evaluates the world transform of every piece of geometry
+// attached to a model, keyed by the geometry's UID
+func (s *SceneTransforms) GeometryTransforms() map[int64]Matrix4 {
+	transforms := make(map[int64]Matrix4)
+	for uid, n := range s.objects {
+		if n.Name != "Geometry" {
+			continue
+		}
+		if world, ok := s.GeometryWorld(uid); ok {
+			transforms[uid] = world
+		}
+	}
+	return transforms
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/EliCDavis/vector"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertVectorInDelta(t *testing.T, expected, actual vector.Vector3) {
+	assert.InDelta(t, expected.X(), actual.X(), 0.00001)
+	assert.InDelta(t, expected.Y(), actual.Y(), 0.00001)
+	assert.InDelta(t, expected.Z(), actual.Z(), 0.00001)
+}
+
+func TestModelTransformsAppliesScaleThenRotationThenTranslation(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	model := newObjectNode(
+		"Model", 1, "Model", "Mesh",
+		NewNodeParent(
+			"Properties70",
+			newVector3PNode("Lcl Translation", vector.NewVector3(10, 0, 0)),
+			newVector3PNode("Lcl Rotation", vector.NewVector3(0, 90, 0)),
+			newVector3PNode("Lcl Scaling", vector.NewVector3(2, 2, 2)),
+			newVector3PNode("GeometricTranslation", vector.NewVector3(0, 5, 0)),
+		),
+	)
+
+	// ******************************** ACT ***********************************
+	local, geometric := ModelTransforms(model)
+
+	// ******************************* ASSERT *********************************
+	assertVectorInDelta(t, vector.NewVector3(10, 0, -2), local.MultiplyPoint(vector.NewVector3(1, 0, 0)))
+	assertVectorInDelta(t, vector.NewVector3(1, 5, 0), geometric.MultiplyPoint(vector.NewVector3(1, 0, 0)))
+}
+
+func TestGeometryWorldFollowsModelHierarchy(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	fbx := &FBX{
+		Top: NewNodeParent("FBXHeaderExtension"),
+		Nodes: []*Node{
+			NewNodeParent(
+				"Objects",
+				newObjectNode("Model", 1, "Parent", "Null", NewNodeParent(
+					"Properties70",
+					newVector3PNode("Lcl Translation", vector.NewVector3(0, 100, 0)),
+				)),
+				newObjectNode("Model", 2, "Child", "Mesh", NewNodeParent(
+					"Properties70",
+					newVector3PNode("Lcl Scaling", vector.NewVector3(1, 2, 1)),
+				)),
+				newObjectNode("Geometry", 3, "Geometry", "Mesh"),
+			),
+			NewNodeParent(
+				"Connections",
+				newConnectionNode("OO", 1, 0),
+				newConnectionNode("OO", 2, 1),
+				newConnectionNode("OO", 3, 2),
+			),
+		},
+	}
+	transforms := NewSceneTransforms(fbx, NewConnectionGraph(fbx))
+
+	// ******************************** ACT ***********************************
+	all := transforms.GeometryTransforms()
+	world, ok := transforms.GeometryWorld(3)
+	plane, planeOk := NewPlane(vector.NewVector3(0, 101, 0), vector.Vector3Up()).ToLocal(world)
+
+	// ******************************* ASSERT *********************************
+	assert.True(t, ok)
+	assert.True(t, planeOk)
+	assert.Len(t, all, 1)
+	assertVectorInDelta(t, vector.NewVector3(1, 102, 0), world.MultiplyPoint(vector.NewVector3(1, 1, 0)))
+
+	// Local y of 0.6 ends up at 101.2 in world space, above the plane
+	below := vector.NewVector3(0, 0.4, 0)
+	above := vector.NewVector3(0, 0.6, 0)
+	assert.Less(t, plane.normal.Dot(below.Sub(plane.origin)), 0.0)
+	assert.Greater(t, plane.normal.Dot(above.Sub(plane.origin)), 0.0)
+}
+
+func TestInverseIsRelativeToScale(t *testing.T) {
+	for _, scale := range []float64{1000, 1, 0.001, 0.0001} {
+		// ****************************** ARRANGE *********************************
+		m := TranslationMatrix4(vector.NewVector3(1, 2, 3)).Multiply(ScalingMatrix4(vector.NewVector3(scale, scale, scale)))
+
+		// ******************************** ACT ***********************************
+		inverse, ok := m.Inverse()
+
+		// ******************************* ASSERT *********************************
+		if assert.True(t, ok, "scale: %f", scale) {
+			assertVectorInDelta(t, vector.NewVector3(4, 5, 6), inverse.MultiplyPoint(m.MultiplyPoint(vector.NewVector3(4, 5, 6))))
+		}
+	}
+
+	_, ok := ScalingMatrix4(vector.NewVector3(0.001, 0.001, 1e-15)).Inverse()
+	assert.False(t, ok)
+}
+
+func TestGeometryPlaneRejectsCollapsedTransforms(t *testing.T) {
+	// ****************************** ARRANGE *********************************
+	geometry := newTriangleGeometryNode(3)
+	plane := NewPlane(vector.Vector3Zero(), vector.Vector3Up())
+	transforms := map[int64]Matrix4{3: ScalingMatrix4(vector.NewVector3(1, 0, 1))}
+
+	// ******************************** ACT ***********************************
+	_, collapsedOk := geometryPlane(geometry, plane, transforms)
+	_, untransformedOk := geometryPlane(newTriangleGeometryNode(4), plane, transforms)
+
+	// ******************************* ASSERT *********************************
+	assert.False(t, collapsedOk)
+	assert.True(t, untransformedOk)
+}
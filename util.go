@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/EliCDavis/vector"
+)
 
 // CreateTimestampNode creates a node with multiple children with properties
 // that put together
@@ -17,3 +21,30 @@ func CreateTimestampNode(t time.Time) *Node {
 		NewNodeSingleProperty("Millisecond", NewPropertyInt32(0)),
 	)
 }
+
+// newIntPNode creates a Properties70 entry holding an integer
+func newIntPNode(name string, value int32) *Node {
+	return NewProperty70(name, "int", "Integer", "", NewPropertyInt32(value)).node()
+}
+
+// newDoublePNode creates a Properties70 entry holding a single number
+func newDoublePNode(name string, value float64) *Node {
+	return NewProperty70(name, "double", "Number", "", NewPropertyFloat64(value)).node()
+}
+
+// newColorPNode creates a Properties70 entry holding a color
+func newColorPNode(name string, c [3]float64) *Node {
+	return NewProperty70(
+		name, "Color", "", "A",
+		NewPropertyFloat64(c[0]), NewPropertyFloat64(c[1]), NewPropertyFloat64(c[2]),
+	).node()
+}
+
+// newVector3PNode creates a Properties70 entry holding a vector, typed after
+// it's name the way transforms are
+func newVector3PNode(name string, v vector.Vector3) *Node {
+	return NewProperty70(
+		name, name, "", "A",
+		NewPropertyFloat64(v.X()), NewPropertyFloat64(v.Y()), NewPropertyFloat64(v.Z()),
+	).node()
+}
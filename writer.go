@@ -1,35 +1,101 @@
 package main
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"time"
 )
 
+// openNode is a node started with StartNode that hasn't been ended yet
+type openNode struct {
+	name string
+	end  uint64
+}
+
 // Writer is responsible for writing nodes to FBX
 type Writer struct {
-	w             io.Writer
+	w             *nodeWriter
+	version       uint32
 	currentOffset uint64
+	open          []openNode
 	err           error
 	complete      bool
 }
 
-// NewWriter creates a new writer and immediately writes the FBX header and
-// top node
-func NewWriter(w io.Writer) (Writer, error) {
+// WriterOptions controls the file NewWriterWithOptions starts writing
+type WriterOptions struct {
+	// Version of the FBX file. Anything before 7500 is written with 32 bit
+	// offsets.
+	Version uint32
 
-	// Write header
-	n, err := w.Write([]byte{
+	// CreationTime is recorded in the header of the file
+	CreationTime time.Time
+
+	// Verify checks every node's offsets against the bytes actually written
+	// for it, failing with an OffsetError on the first one that's off
+	Verify bool
+}
+
+// binaryHeader is the magic string and version every file we write starts
+// with
+func binaryHeader(version uint32) []byte {
+	header := []byte{
 		75, 97, 121, 100,
 		97, 114, 97, 32,
 		70, 66, 88, 32,
 		66, 105, 110, 97,
 		114, 121, 32, 32,
-		0, 26, 0, 76, 29,
+		0, 26, 0, 0, 0,
 		0, 0,
+	}
+	binary.LittleEndian.PutUint32(header[23:], version)
+	return header
+}
+
+// headerNodes are the nodes that come directly after the binary header,
+// describing who made the file and when
+func headerNodes(creationTime time.Time, version uint32) []*Node {
+	nodes := []*Node{
+		NewNodeParent(
+			"FBXHeaderExtension",
+			NewNodeInt32("FBXHeaderVersion", 1003),
+			NewNodeInt32("FBXVersion", int32(version)),
+			NewNodeInt32("EncryptionType", 0),
+			CreateTimestampNode(creationTime),
+			NewNodeString("Creator", "https://github.com/EliCDavis"),
+		),
+		NewNodeString("CreationTime", creationTime.String()),
+		NewNodeString("Creator", "https://github.com/EliCDavis"),
+	}
+	for _, n := range nodes {
+		addNullRecords(n)
+	}
+	return nodes
+}
+
+// NewWriter creates a new writer and immediately writes the FBX header and
+// top node
+func NewWriter(w io.Writer) (Writer, error) {
+	return NewWriterWithOptions(w, WriterOptions{
+		Version:      7500,
+		CreationTime: time.Now(),
 	})
+}
+
+// NewWriterWithOptions creates a new writer for a specific version of FBX
+// and immediately writes the FBX header and top node
+func NewWriterWithOptions(w io.Writer, options WriterOptions) (Writer, error) {
+
+	// Write header
+	nw := newNodeWriter(w, options.Version < 7500)
+	nw.verify = options.Verify
+	n, err := nw.Write(binaryHeader(options.Version))
 
 	fbxWriter := Writer{
-		w:             w,
+		w:             nw,
+		version:       options.Version,
 		currentOffset: uint64(n),
 		err:           nil,
 		complete:      false,
@@ -39,19 +105,9 @@ func NewWriter(w io.Writer) (Writer, error) {
 		return fbxWriter, err
 	}
 
-	creationTime := time.Now()
-
-	fbxWriter.WriteNode(NewNodeParent(
-		"FBXHeaderExtension",
-		NewNodeInt32("FBXHeaderVersion", 1003),
-		NewNodeInt32("FBXVersion", 7500),
-		NewNodeInt32("EncryptionType", 0),
-		CreateTimestampNode(creationTime),
-		NewNodeString("Creator", "https://github.com/EliCDavis"),
-	))
-
-	fbxWriter.WriteNode(NewNodeString("CreationTime", creationTime.String()))
-	fbxWriter.WriteNode(NewNodeString("Creator", "https://github.com/EliCDavis"))
+	for _, n := range headerNodes(options.CreationTime, options.Version) {
+		fbxWriter.WriteNode(n)
+	}
 
 	return fbxWriter, nil
 }
@@ -62,7 +118,7 @@ func (w *Writer) WriteNode(n *Node) bool {
 		return false
 	}
 
-	newOffset, err := n.Write(w.w, w.currentOffset, false)
+	newOffset, err := w.w.writeNode(n, w.currentOffset)
 	if err != nil {
 		w.err = err
 		return false
@@ -71,19 +127,85 @@ func (w *Writer) WriteNode(n *Node) bool {
 	return true
 }
 
+// Length is how many bytes the node takes up once written by the writer
+func (w *Writer) Length(n *Node) uint64 {
+	return w.w.length(n)
+}
+
+// StartNode writes out the start of a node without properties, for nodes
+// whose nested nodes are too many to hold in memory at once. The nested nodes
+// are then written one at a time with WriteNode, or started themselves, until
+// EndNode is called. Where the node ends has to be known up front, so
+// nestedLength is how many bytes every nested node takes up once written (see
+// Length), not counting the empty node EndNode writes.
+func (w *Writer) StartNode(name string, nestedLength uint64) bool {
+	if w.err != nil || w.complete {
+		return false
+	}
+
+	n := NewNodeParent(name)
+	end := w.currentOffset + w.w.headerSize() + uint64(n.NameLen) + nestedLength + w.w.headerSize()
+	if err := w.w.writeHeader(n, end); err != nil {
+		w.err = err
+		return false
+	}
+	w.currentOffset += w.w.headerSize() + uint64(n.NameLen)
+	w.open = append(w.open, openNode{name: name, end: end})
+	return true
+}
+
+// EndNode ends the node last started with StartNode, failing with an
+// OffsetError if it's nested nodes didn't add up to the length it was
+// started with
+func (w *Writer) EndNode() bool {
+	if w.err != nil || w.complete {
+		return false
+	}
+	if len(w.open) == 0 {
+		w.err = errors.New("no node left to end")
+		return false
+	}
+
+	n := w.open[len(w.open)-1]
+	w.open = w.open[:len(w.open)-1]
+	if w.WriteNode(&Node{}) == false {
+		return false
+	}
+
+	if w.currentOffset != n.end {
+		w.err = &OffsetError{Path: n.name, What: "ends", Expected: n.end, Actual: w.currentOffset}
+		return false
+	}
+	return true
+}
+
+// Complete ends the list of nodes, writes out the footer and flushes
+// everything written out to the underlying writer
 func (w *Writer) Complete() error {
 	if w.err != nil || w.complete {
 		return w.err
 	}
-	n, err := w.w.Write([]byte{
-		0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0,
-	})
-
+	if len(w.open) > 0 {
+		w.complete = true
+		w.err = fmt.Errorf("node %s was started but never ended", w.open[len(w.open)-1].name)
+		return w.err
+	}
+	nullRecord := make([]byte, 25)
+	if w.version < 7500 {
+		nullRecord = nullRecord[:13]
+	}
+	n, err := w.w.Write(nullRecord)
 	w.currentOffset += uint64(n)
+
+	if err == nil {
+		n, err = writeFooter(w.w, w.currentOffset, nil, w.version)
+		w.currentOffset += uint64(n)
+	}
+	if err == nil {
+		err = w.w.Flush()
+	}
+
+	w.complete = true
 	w.err = err
 	return w.err
 }